@@ -48,7 +48,11 @@ long long CgoProxyImpl::GoHttpFilterEncodeData(unsigned long long filter_tag, vo
   return Cgo_GoHttpFilter_EncodeData(filter_tag, buffer, end_stream);
 }
 
-void CgoProxyImpl::GoHttpFilterOnPost(unsigned long long filter_tag, unsigned long long post_tag) {
+long long CgoProxyImpl::GoHttpFilterEncodeTrailers(unsigned long long filter_tag, void* trailers) {
+  return Cgo_GoHttpFilter_EncodeTrailers(filter_tag, trailers);
+}
+
+long long CgoProxyImpl::GoHttpFilterOnPost(unsigned long long filter_tag, unsigned long long post_tag) {
   return Cgo_GoHttpFilter_OnPost(filter_tag, post_tag);
 }
 } // namespace Http
@@ -25,7 +25,8 @@ public:
                                               int end_stream) = 0;
   virtual long long GoHttpFilterEncodeData(unsigned long long filter_tag, void* headers,
                                            int end_stream) = 0;
-  virtual void GoHttpFilterOnPost(unsigned long long filter_tag, unsigned long long post_tag) = 0;
+  virtual long long GoHttpFilterEncodeTrailers(unsigned long long filter_tag, void* trailers) = 0;
+  virtual long long GoHttpFilterOnPost(unsigned long long filter_tag, unsigned long long post_tag) = 0;
 };
 
 class CgoProxyImpl : public CgoProxy {
@@ -45,7 +46,8 @@ public:
                                       int end_stream) override;
   long long GoHttpFilterEncodeData(unsigned long long filter_tag, void* headers,
                                    int end_stream) override;
-  void GoHttpFilterOnPost(unsigned long long filter_tag, unsigned long long post_tag) override;
+  long long GoHttpFilterEncodeTrailers(unsigned long long filter_tag, void* trailers) override;
+  long long GoHttpFilterOnPost(unsigned long long filter_tag, unsigned long long post_tag) override;
 };
 
 using CgoProxyPtr = std::shared_ptr<CgoProxy>;
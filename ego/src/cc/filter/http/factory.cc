@@ -69,7 +69,8 @@ public:
     return [cfg, &context, secret_provider,
             cgo_proxy](Http::FilterChainFactoryCallbacks& callbacks) -> void {
       auto span_group = std::make_unique<Envoy::Http::SpanGroup>();
-      auto filter = new Http::GoHttpFilter(cfg, context.api(), secret_provider, cgo_proxy, std::move(span_group));
+      auto filter = new Http::GoHttpFilter(cfg, context.api(), context.clusterManager(),
+                                           secret_provider, cgo_proxy, std::move(span_group));
       callbacks.addStreamFilter(filter->ref());
     };
   }
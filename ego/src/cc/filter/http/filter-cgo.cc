@@ -8,7 +8,13 @@
 // avoid having too much custom logic in here: the intent is to handle as much
 // of the filter logic as possible in Go!
 
+#include <cstdlib>
+#include <vector>
+
+#include "common/common/assert.h"
+#include "common/common/empty_string.h"
 #include "common/common/lock_guard.h"
+#include "common/stats/symbol_table_impl.h"
 
 #include "ego/src/cc/goc/goc.h"
 #include "ego/src/go/internal/cgo/cgo.h"
@@ -22,23 +28,35 @@ static thread_local struct CgoHttpFilterFactorySlot_ {
   ~CgoHttpFilterFactorySlot_() { Cgo_ReleaseHttpFilterFactorySlot(value); }
 } cgoHttpFilterFactorySlot{Cgo_AcquireHttpFilterFactorySlot()};
 
+// takeCgoError returns and frees an error message allocated by Go
+static std::string takeCgoError(char* err) {
+  if (err == nullptr) {
+    return "unknown error";
+  }
+  std::string msg(err);
+  free(err);
+  return msg;
+}
+
+static Stats::Counter& counterFromString(Stats::Scope& scope, absl::string_view name) {
+  Stats::StatNameManagedStorage storage(name, scope.symbolTable());
+  return scope.counterFromStatName(storage.statName());
+}
+
 GoHttpFilterConfig::GoHttpFilterConfig(const ego::http::Settings& proto,
                                        const Stats::ScopeSharedPtr& scope)
-    : cgoSlot_(cgoHttpFilterFactorySlot.value), filter_(proto.filter()), scope_(scope) {
+    : cgoSlot_(cgoHttpFilterFactorySlot.value), filter_(proto.filter()), scope_(scope),
+      crash_on_errors_(proto.crash_on_errors()), panic_(counterFromString(*scope, "panic")) {
   auto filter = proto.filter();
   auto settings = proto.settings().value();
+  char* err = nullptr;
   cgoTag_ = Cgo_GoHttpFilterFactory_Create(cgoSlot_, const_cast<char*>(filter.c_str()),
                                            filter.size(), const_cast<char*>(settings.c_str()),
-                                           settings.size(), scope.get());
+                                           settings.size(), scope.get(), &err);
 
   if (cgoTag_ == 0) {
-    auto errMsg = std::string(
-        "invoke Cgo_GoHttpFilterFactory_Create failed, check error from factory for detail");
-    if (proto.crash_on_errors()) {
-      throw EnvoyException(errMsg);
-    } else {
-      ENVOY_LOG(error, "[ego_http][{}] {}", proto.filter(), errMsg);
-    }
+    throw EnvoyException(
+        fmt::format("[ego_http][{}] invalid filter config: {}", filter, takeCgoError(err)));
   }
 }
 
@@ -70,11 +88,16 @@ GoHttpRouteSpecificFilterConfig::GoHttpRouteSpecificFilterConfig(
   for (const auto& it : proto.filters()) {
     auto filterName = it.first;
     auto filterConfig = it.second;
+    char* err = nullptr;
     auto cgoTag = Cgo_RouteSpecificFilterConfig_Create(
         cgoSlot_, const_cast<char*>(filterName.c_str()), filterName.size(),
-        const_cast<char*>(filterConfig.value().c_str()), filterConfig.value().size());
-    // handle invalid filter name or configuration
-    ASSERT(0 != cgoTag);
+        const_cast<char*>(filterConfig.value().c_str()), filterConfig.value().size(), &err);
+    if (0 == cgoTag) {
+      // the destructor won't run, so release the filter configs created so far
+      onDestroy_();
+      throw EnvoyException(fmt::format("[ego_http][{}] invalid route specific filter config: {}",
+                                       filterName, takeCgoError(err)));
+    }
 
     filters_.insert(std::pair<std::string, uint64_t>(filterName, cgoTag));
   }
@@ -90,15 +113,17 @@ static thread_local struct CgoHttpFilterSlot_ {
 } cgoHttpFilterSlot{Cgo_AcquireHttpFilterSlot()};
 
 GoHttpFilter::GoHttpFilter(std::shared_ptr<GoHttpFilterConfig> config, Api::Api& api,
+                           Upstream::ClusterManager& cluster_manager,
                            Secret::GenericSecretConfigProviderSharedPtr secret_provider,
                            CgoProxyPtr cgo_proxy, SpanGroupPtr span_group)
     : config_(config), decoderCallbacks_(0), encoderCallbacks_(0), dispatcher_(0), pins_(1),
-      self_(this), api_(api), secret_provider_(secret_provider), cgo_proxy_(cgo_proxy), span_group_(std::move(span_group)) {
+      self_(this), api_(api), secret_provider_(secret_provider), cgo_proxy_(cgo_proxy), span_group_(std::move(span_group)),
+      cluster_manager_(cluster_manager) {
   cgoSlot_ = cgoHttpFilterSlot.value;
   cgoTag_ = cgo_proxy_->GoHttpFilterCreate(this, config->cgoTag_, cgoSlot_);
   // cgoTag_ == 0 means can not create a instance of filter on Go-side
   // we should sendLocalReply here but we don't have decodeCallbacks now
-  // Let handle it on setDecoderFilterCallbacks
+  // Let handle it on decodeHeaders
 };
 
 inline bool GoHttpFilter::cgoSafe() {
@@ -108,24 +133,54 @@ inline bool GoHttpFilter::cgoSafe() {
 
 FilterHeadersStatus GoHttpFilter::decodeHeaders(RequestHeaderMap& headers, bool end_stream) {
   ASSERT(cgoSafe());
+  if (ended_) {
+    if (cgoTag_ == 0) {
+      // the Go filter could not be created, see setDecoderFilterCallbacks
+      decoderCallbacks_->sendLocalReply(Code::InternalServerError, EMPTY_STRING, nullptr,
+                                        absl::nullopt, EMPTY_STRING);
+    }
+    return FilterHeadersStatus::StopIteration;
+  }
+
   // Get x-request-id for logging
   if (headers.RequestId() != nullptr && x_request_id_ == "") {
     x_request_id_ = headers.RequestId()->value().getStringView();
   }
 
-  return Goc_FilterHeadersStatus(
-      cgo_proxy_->GoHttpFilterDecodeHeaders(cgoTag_, &headers, end_stream ? 1 : 0));
+  auto status = cgo_proxy_->GoHttpFilterDecodeHeaders(cgoTag_, &headers, end_stream ? 1 : 0);
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("decodeHeaders");
+    return FilterHeadersStatus::StopIteration;
+  }
+  return Goc_FilterHeadersStatus(status);
 }
 
 FilterDataStatus GoHttpFilter::decodeData(Buffer::Instance& buffer, bool end_stream) {
   ASSERT(cgoSafe());
-  return Goc_FilterDataStatus(
-      cgo_proxy_->GoHttpFilterDecodeData(cgoTag_, &buffer, end_stream ? 1 : 0));
+  if (ended_) {
+    return FilterDataStatus::StopIterationNoBuffer;
+  }
+
+  auto status = cgo_proxy_->GoHttpFilterDecodeData(cgoTag_, &buffer, end_stream ? 1 : 0);
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("decodeData");
+    return FilterDataStatus::StopIterationNoBuffer;
+  }
+  return Goc_FilterDataStatus(status);
 }
 
 FilterTrailersStatus GoHttpFilter::decodeTrailers(RequestTrailerMap& trailers) {
   ASSERT(cgoSafe());
-  return Goc_FilterTrailersStatus(cgo_proxy_->GoHttpFilterDecodeTrailers(cgoTag_, &trailers));
+  if (ended_) {
+    return FilterTrailersStatus::StopIteration;
+  }
+
+  auto status = cgo_proxy_->GoHttpFilterDecodeTrailers(cgoTag_, &trailers);
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("decodeTrailers");
+    return FilterTrailersStatus::StopIteration;
+  }
+  return Goc_FilterTrailersStatus(status);
 }
 
 FilterHeadersStatus GoHttpFilter::encode100ContinueHeaders(ResponseHeaderMap&) {
@@ -135,20 +190,46 @@ FilterHeadersStatus GoHttpFilter::encode100ContinueHeaders(ResponseHeaderMap&) {
 
 FilterHeadersStatus GoHttpFilter::encodeHeaders(ResponseHeaderMap& headers, bool end_stream) {
   ASSERT(cgoSafe());
-  return Goc_FilterHeadersStatus(
-      cgo_proxy_->GoHttpFilterEncodeHeaders(cgoTag_, &headers, end_stream ? 1 : 0));
+  encoding_ = true;
+  if (ended_) {
+    // this is our own local reply
+    return FilterHeadersStatus::Continue;
+  }
+
+  auto status = cgo_proxy_->GoHttpFilterEncodeHeaders(cgoTag_, &headers, end_stream ? 1 : 0);
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("encodeHeaders");
+    return FilterHeadersStatus::StopIteration;
+  }
+  return Goc_FilterHeadersStatus(status);
 }
 
 FilterDataStatus GoHttpFilter::encodeData(Buffer::Instance& buffer, bool end_stream) {
   ASSERT(cgoSafe());
+  if (ended_) {
+    return FilterDataStatus::Continue;
+  }
 
-  return Goc_FilterDataStatus(
-      cgo_proxy_->GoHttpFilterEncodeData(cgoTag_, &buffer, end_stream ? 1 : 0));
+  auto status = cgo_proxy_->GoHttpFilterEncodeData(cgoTag_, &buffer, end_stream ? 1 : 0);
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("encodeData");
+    return FilterDataStatus::StopIterationNoBuffer;
+  }
+  return Goc_FilterDataStatus(status);
 }
 
-FilterTrailersStatus GoHttpFilter::encodeTrailers(ResponseTrailerMap&) {
-  // TODO: call to Go
-  return Envoy::Http::FilterTrailersStatus::Continue;
+FilterTrailersStatus GoHttpFilter::encodeTrailers(ResponseTrailerMap& trailers) {
+  ASSERT(cgoSafe());
+  if (ended_) {
+    return FilterTrailersStatus::Continue;
+  }
+
+  auto status = cgo_proxy_->GoHttpFilterEncodeTrailers(cgoTag_, &trailers);
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("encodeTrailers");
+    return FilterTrailersStatus::StopIteration;
+  }
+  return Goc_FilterTrailersStatus(status);
 }
 
 FilterMetadataStatus GoHttpFilter::encodeMetadata(MetadataMap&) {
@@ -163,6 +244,13 @@ void GoHttpFilter::encodeComplete() {
 void GoHttpFilter::onDestroy() {
   ASSERT(cgoSafe());
 
+  // the stream is gone, so failures in the remaining upcalls can't end it
+  ended_ = true;
+
+  // let Go see the outcome of every outstanding asynchronous request while
+  // the Go filter is still around
+  cancelAsyncRequests();
+
   // best effort to terminate go-routines and other asynchronous activities
   cgo_proxy_->GoHttpFilterOnDestroy(cgoTag_);
   cgoTag_ = 0;
@@ -211,7 +299,54 @@ void GoHttpFilter::onPost(uint64_t postTag) {
   }
 
   ASSERT(cgoSafe());
-  cgo_proxy_->GoHttpFilterOnPost(cgoTag_, postTag);
+  auto status = cgo_proxy_->GoHttpFilterOnPost(cgoTag_, postTag);
+
+  {
+    // Go has seen the outcome of the asynchronous request, if that's what it was
+    Thread::LockGuard lk(async_requests_lock_);
+    async_requests_.erase(postTag);
+  }
+
+  if (GoHttpFilter_FAILED == status) {
+    onGoFailure("onPost");
+  }
+}
+
+void GoHttpFilter::onGoFailure(absl::string_view upcall) {
+  config_->panic_.inc();
+  ENVOY_LOG(error, "[ego_http][{}] [{}] {} failed", config_->filter(), x_request_id_, upcall);
+
+  if (config_->crash_on_errors_) {
+    PANIC(fmt::format("[ego_http][{}] {} failed", config_->filter(), upcall));
+  }
+
+  if (ended_) {
+    return;
+  }
+  ended_ = true;
+
+  if (encoding_) {
+    encoderCallbacks_->resetStream();
+  } else {
+    decoderCallbacks_->sendLocalReply(Code::InternalServerError, EMPTY_STRING, nullptr,
+                                      absl::nullopt, EMPTY_STRING);
+  }
+}
+
+void GoHttpFilter::cancelAsyncRequests() {
+  std::vector<uint64_t> tags;
+  {
+    Thread::LockGuard lk(async_requests_lock_);
+    async_requests_closed_ = true;
+    for (auto& it : async_requests_) {
+      it.second->cancel();
+      tags.push_back(it.first);
+    }
+  }
+
+  for (auto tag : tags) {
+    onPost(tag);
+  }
 }
 
 } // namespace Http
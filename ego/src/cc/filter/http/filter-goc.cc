@@ -41,6 +41,109 @@ void GoHttpFilter::post(uint64_t tag) {
   dispatcher_->post([this, tag, keepalive = ref()]() { onPost(tag); });
 }
 
+const char* GoHttpFilter::asyncSend(uint64_t tag, std::string cluster, RequestMessagePtr&& request,
+                                    std::chrono::milliseconds timeout) {
+  ASSERT(0 < pins_.load());
+
+  {
+    Envoy::Thread::LockGuard lk(async_requests_lock_);
+    if (async_requests_closed_) {
+      return "filter destroyed";
+    }
+    if (!async_requests_
+             .emplace(tag, std::make_unique<AsyncRequest>(*this, tag, std::move(cluster),
+                                                          std::move(request), timeout))
+             .second) {
+      return "duplicate tag";
+    }
+  }
+
+  // the async client must be used from the dispatcher
+  dispatcher_->post([this, tag, keepalive = ref()]() { onAsyncSend(tag); });
+  return nullptr;
+}
+
+const char* GoHttpFilter::asyncResponse(uint64_t tag, ResponseMessage** response) {
+  ASSERT(nullptr != response);
+
+  Envoy::Thread::LockGuard lk(async_requests_lock_);
+  auto it = async_requests_.find(tag);
+  if (it == async_requests_.end()) {
+    return "unknown request";
+  }
+
+  const auto& request = *it->second;
+  if (!request.completed()) {
+    return "request in progress";
+  }
+  if (request.error_) {
+    return request.error_;
+  }
+  *response = request.response_.get();
+  return nullptr;
+}
+
+void GoHttpFilter::onAsyncSend(uint64_t tag) {
+  AsyncRequest* request;
+  {
+    Envoy::Thread::LockGuard lk(async_requests_lock_);
+    auto it = async_requests_.find(tag);
+    if (it == async_requests_.end()) {
+      // already canceled by onDestroy()
+      return;
+    }
+    request = it->second.get();
+  }
+
+  // Entries are only removed in dispatcher context, so request stays valid.
+  // Note that the callbacks may be invoked before start() returns.
+  request->start(cluster_manager_);
+}
+
+void GoHttpFilter::AsyncRequest::start(Upstream::ClusterManager& cluster_manager) {
+  if (nullptr == cluster_manager.get(cluster_)) {
+    error_ = "unknown cluster";
+    complete();
+    return;
+  }
+
+  auto options = AsyncClient::RequestOptions();
+  if (0 < timeout_.count()) {
+    options.setTimeout(timeout_);
+  }
+  request_ =
+      cluster_manager.httpAsyncClientForCluster(cluster_).send(std::move(message_), *this, options);
+}
+
+void GoHttpFilter::AsyncRequest::cancel() {
+  if (request_) {
+    request_->cancel();
+    request_ = nullptr;
+  }
+  if (!completed()) {
+    error_ = "canceled";
+  }
+}
+
+void GoHttpFilter::AsyncRequest::onSuccess(ResponseMessagePtr&& response) {
+  request_ = nullptr;
+  response_ = std::move(response);
+  complete();
+}
+
+void GoHttpFilter::AsyncRequest::onFailure(AsyncClient::FailureReason) {
+  // Reset is the only failure reason so far
+  request_ = nullptr;
+  error_ = "upstream request reset";
+  complete();
+}
+
+void GoHttpFilter::AsyncRequest::complete() {
+  // deliver the outcome via onPost(tag) rather than inline, as we may still be
+  // within start()
+  filter_.post(tag_);
+}
+
 void GoHttpFilter::log(uint32_t level, absl::string_view message) {
   switch (static_cast<spdlog::level::level_enum>(level)) {
   case spdlog::level::trace:
@@ -3,7 +3,7 @@
 // Use of this source code is governed by the Apache License 2.0 that can be
 // found in the LICENSE file
 
-#include "common/common/empty_string.h"
+#include "common/common/assert.h"
 
 #include "filter.h"
 
@@ -22,8 +22,11 @@ void GoHttpFilter::setDecoderFilterCallbacks(StreamDecoderFilterCallbacks& callb
 
   // Handle logic can not create fitler on Go-side
   if (cgoTag_ == 0) {
-    decoderCallbacks_->sendLocalReply(Code::InternalServerError, EMPTY_STRING, nullptr,
-                                      absl::nullopt, EMPTY_STRING);
+    if (config_->crash_on_errors_) {
+      PANIC(fmt::format("[ego_http][{}] can not create Go filter", config_->filter()));
+    }
+    // the local reply is sent from decodeHeaders, once decoding has started
+    ended_ = true;
   }
 }
 
@@ -8,9 +8,12 @@
 #define FILTER_HTTP_GETHEADER_FILTER_H
 
 #include <atomic>
+#include <map>
 
+#include "envoy/http/async_client.h"
 #include "envoy/server/filter_config.h"
 #include "envoy/stats/scope.h"
+#include "envoy/upstream/cluster_manager.h"
 
 #include "common/common/thread.h"
 #include "common/singleton/const_singleton.h"
@@ -47,6 +50,11 @@ private:
 
   // hold the scope_ for using from go side
   Stats::ScopeSharedPtr scope_;
+
+  const bool crash_on_errors_;
+
+  // counts the streams ended because the Go filter failed
+  Stats::Counter& panic_;
 };
 
 class GoHttpRouteSpecificFilterConfig : public Router::RouteSpecificFilterConfig {
@@ -69,6 +77,7 @@ private:
 class GoHttpFilter : public StreamFilter, public Logger::Loggable<Logger::Id::filter> {
 public:
   GoHttpFilter(std::shared_ptr<GoHttpFilterConfig> config, Api::Api& api,
+               Upstream::ClusterManager& cluster_manager,
                Secret::GenericSecretConfigProviderSharedPtr secret_provider, CgoProxyPtr cgo_proxy, SpanGroupPtr span_group);
   ~GoHttpFilter() override{};
 
@@ -91,6 +100,18 @@ public:
   Api::Api& api();
   std::string secret_holder;
 
+  // Sends request to cluster through Envoy's async client. May be called from
+  // any thread while the filter is pinned. The request is started on the
+  // dispatcher, and its outcome is delivered via post(tag). Returns a static
+  // error string if the request can't be scheduled.
+  const char* asyncSend(uint64_t tag, std::string cluster, RequestMessagePtr&& request,
+                        std::chrono::milliseconds timeout);
+
+  // Gets the outcome of the asynchronous request identified by tag. Only valid
+  // from within onPost(tag). Returns a static error string if the request
+  // failed, otherwise response is set.
+  const char* asyncResponse(uint64_t tag, ResponseMessage** response);
+
 public:
   // Http::StreamFilterBase
   void onDestroy() override;
@@ -167,6 +188,49 @@ public:
 
 
 private:
+  // AsyncRequest tracks an asynchronous request started by asyncSend().
+  class AsyncRequest : public AsyncClient::Callbacks {
+  public:
+    AsyncRequest(GoHttpFilter& filter, uint64_t tag, std::string cluster,
+                 RequestMessagePtr&& message, std::chrono::milliseconds timeout)
+        : filter_(filter), tag_(tag), cluster_(std::move(cluster)), message_(std::move(message)),
+          timeout_(timeout) {}
+
+    void start(Upstream::ClusterManager& cluster_manager);
+    void cancel();
+    bool completed() const { return response_ != nullptr || error_ != nullptr; }
+
+    // Http::AsyncClient::Callbacks
+    void onSuccess(ResponseMessagePtr&& response) override;
+    void onFailure(AsyncClient::FailureReason reason) override;
+
+  private:
+    friend class GoHttpFilter;
+    void complete();
+
+    GoHttpFilter& filter_;
+    const uint64_t tag_;
+    const std::string cluster_;
+    RequestMessagePtr message_;
+    const std::chrono::milliseconds timeout_;
+    AsyncClient::Request* request_{};
+    ResponseMessagePtr response_;
+    const char* error_{};
+  };
+  using AsyncRequestPtr = std::unique_ptr<AsyncRequest>;
+
+  void onAsyncSend(uint64_t tag);
+
+  // Ends the stream after an upcall returned GoHttpFilter_FAILED: with a local
+  // reply while the response hasn't started yet, otherwise with a reset.
+  // Aborts Envoy if crash_on_errors is set.
+  void onGoFailure(absl::string_view upcall);
+
+  // Completes all outstanding asynchronous requests with an error and
+  // delivers them to Go. Called from onDestroy() before the Go filter goes
+  // away.
+  void cancelAsyncRequests();
+
   // config containing the few bits interesting on the C++ side of things
   const std::shared_ptr<GoHttpFilterConfig> config_;
 
@@ -219,9 +283,25 @@ private:
   // private x-request-id for logging
   absl::string_view x_request_id_ = "";
 
+  // set once encodeHeaders() was called, i.e., a local reply is too late
+  bool encoding_{};
+
+  // set once the stream was ended by onGoFailure() or the filter is being
+  // destroyed. The Go filter isn't called for encoding the local reply.
+  bool ended_{};
+
   CgoProxyPtr cgo_proxy_;
 
   SpanGroupPtr span_group_;
+
+  Upstream::ClusterManager& cluster_manager_;
+
+  // Outstanding asynchronous requests by tag. Entries are added by asyncSend()
+  // on any thread, so do only access while holding async_requests_lock_.
+  // Requests are started and completed in dispatcher context.
+  Thread::MutexBasicLockable async_requests_lock_;
+  std::map<uint64_t, AsyncRequestPtr> async_requests_;
+  bool async_requests_closed_{};
 };
 
 } // namespace Http
@@ -45,3 +45,39 @@ uint64_t BufferInstance_getRawSlices(void* bufferInstance, uint64_t max, GoBuf*
   }
   return len;
 }
+
+// add() and prepend() have to copy, because Go memory must not be retained by
+// C++ after the downcall returns.
+void BufferInstance_add(void* bufferInstance, GoBuf data) {
+  auto that = static_cast<Envoy::Buffer::Instance*>(bufferInstance);
+  that->add(data.data, data.len);
+}
+
+void BufferInstance_prepend(void* bufferInstance, GoBuf data) {
+  auto that = static_cast<Envoy::Buffer::Instance*>(bufferInstance);
+  that->prepend(absl::string_view(static_cast<const char*>(data.data), data.len));
+}
+
+void BufferInstance_drain(void* bufferInstance, uint64_t size) {
+  auto that = static_cast<Envoy::Buffer::Instance*>(bufferInstance);
+  if (that->length() < size)
+    size = that->length();
+
+  that->drain(size);
+}
+
+// move() hands over the slices of rhs without copying.
+void BufferInstance_move(void* bufferInstance, void* rhs) {
+  auto that = static_cast<Envoy::Buffer::Instance*>(bufferInstance);
+  auto other = static_cast<Envoy::Buffer::Instance*>(rhs);
+  if (that == other)
+    return;
+
+  that->move(*other);
+}
+
+void BufferInstance_replace(void* bufferInstance, GoBuf data) {
+  auto that = static_cast<Envoy::Buffer::Instance*>(bufferInstance);
+  that->drain(that->length());
+  that->add(data.data, data.len);
+}
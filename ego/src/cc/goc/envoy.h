@@ -30,6 +30,11 @@ typedef struct {
 } GoBuf;
 
 // GoHttpFilter
+
+// Returned by the Cgo_GoHttpFilter_* upcalls in place of a filter status if the
+// Go filter panicked or could not be found.
+#define GoHttpFilter_FAILED -1
+
 void GoHttpFilter_pin(void* goHttpFilter);
 void GoHttpFilter_unpin(void* goHttpFilter);
 void GoHttpFilter_post(void* goHttpFilter, uint64_t tag);
@@ -64,9 +69,51 @@ const void * GoHttpFilter_StreamFilterCallbacks_StreamInfo_getRequestHeaders(voi
 int GoHttpFilter_StreamFilterCallbacks_StreamInfo_responseCode(void* goHttpFilter,int encoder);                                                                     
 void GoHttpFilter_StreamFilterCallbacks_StreamInfo_responseCodeDetails(void* goHttpFilter,int encoder, GoStr* value);                                                                          
 
+// Returns 0 if the protocol isn't known yet. Otherwise, returns non-zero.
+int GoHttpFilter_StreamFilterCallbacks_StreamInfo_protocol(void* goHttpFilter, int encoder, GoStr* value);
+// Addresses are returned as strings, e.g. "10.0.0.1:443". Empty if unknown.
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamRemoteAddress(void* goHttpFilter, int encoder, GoStr* value);
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamLocalAddress(void* goHttpFilter, int encoder, GoStr* value);
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_upstreamHost(void* goHttpFilter, int encoder, GoStr* value);
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_routeName(void* goHttpFilter, int encoder, GoStr* value);
+// Returns nanoseconds since the epoch.
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_startTime(void* goHttpFilter, int encoder);
+// Durations are nanoseconds since startTime. Returns -1 if the event hasn't happened yet.
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_lastDownstreamRxByteReceived(void* goHttpFilter, int encoder);
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_firstUpstreamRxByteReceived(void* goHttpFilter, int encoder);
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_firstDownstreamTxByteSent(void* goHttpFilter, int encoder);
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_requestComplete(void* goHttpFilter, int encoder);
+// Returns -1 if the downstream connection doesn't use TLS. Otherwise, returns
+// the size of the serialized ego.http.SslConnectionInfo, which is only written
+// to buf if it fits.
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamSslConnection(void* goHttpFilter, int encoder,
+                                                                              GoBuf buf);
+
+// Returns 0 if there is no such value. Otherwise, returns the size of the
+// serialized google.protobuf.Value, which is only written to buf if it fits.
+size_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_DynamicMetadata_get(void* goHttpFilter, int encoder,
+                                                                         GoStr filterName, GoStr key,
+                                                                         GoBuf buf);
+// Returns 0 if there is no error. Otherwise, returns non-zero.
+int GoHttpFilter_StreamFilterCallbacks_StreamInfo_setDynamicMetadata(void* goHttpFilter, int encoder,
+                                                                     GoStr filterName, GoBuf valueBuf);
+
 // Returns 0 if route isn't existing. Otherwise, returns non-zero.
 int GoHttpFilter_StreamFilterCallbacks_routeExisting(void* goHttpFilter, int encoder);
 
+// Returns 0 if there is no error. Otherwise, returns non-zero.
+int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_clusterName(void* goHttpFilter, int encoder, GoStr* value);
+// Returns 0 if there is no error. Otherwise, returns non-zero.
+int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_routeName(void* goHttpFilter, int encoder, GoStr* value);
+// Returns 0 if there is no error. Otherwise, returns non-zero.
+int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_virtualHost_name(void* goHttpFilter, int encoder, GoStr* value);
+// Returns -1 if there is no such namespace. Otherwise, returns the size of the
+// serialized google.protobuf.Struct, which is only written to buf if it fits.
+// Empty namespaces have a size of 0.
+int64_t GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(void* goHttpFilter, int encoder,
+                                                                     GoStr filterName, GoBuf buf);
+void GoHttpFilter_StreamFilterCallbacks_clearRouteCache(void* goHttpFilter, int encoder);
+
 // Returns 0 if there is no error. Otherwise, returns non-zero.
 int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matcher(void* goHttpFilter, int encoder, GoStr* value);
 // Returns match type as following. If there is an error, returns a negative number.
@@ -83,6 +130,20 @@ int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_match
 //   return 3;
 int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matchType(void* goHttpFilter, int encoder);
 
+// AsyncClient
+// Sends a request to cluster. headersBuf is a serialized ego.http.RequestHeaderMap,
+// including the :method, :path and :authority pseudo headers. A zero timeoutMs
+// means no timeout. The outcome is delivered via post(tag). Returns a static
+// error string if the request can't be scheduled.
+GoError GoHttpFilter_AsyncClient_send(void* goHttpFilter, uint64_t tag, GoStr cluster,
+                                      GoBuf headersBuf, GoBuf body, uint64_t timeoutMs);
+// Gets the outcome of the request sent with tag. Only valid while handling
+// post(tag). Returns a static error string if the request failed. Otherwise,
+// headers points to the response headers and body to the response body, which
+// may be null.
+GoError GoHttpFilter_AsyncClient_response(void* goHttpFilter, uint64_t tag, void** headers,
+                                          void** body);
+
 // GenericSecretConfigProvider
 void GoHttpFilter_GenericSecretConfigProvider_secret(void* goHttpFilter, GoStr* value);
 
@@ -100,12 +161,16 @@ uint64_t BufferInstance_copyOut(void* bufferInstance, size_t start, GoBuf buf);
 uint64_t BufferInstance_length(void* bufferInstance);
 uint64_t BufferInstance_getRawSlicesCount(void* bufferInstance);
 uint64_t BufferInstance_getRawSlices(void* bufferInstance, uint64_t max, GoBuf* dest);
+void BufferInstance_add(void* bufferInstance, GoBuf data);
+void BufferInstance_prepend(void* bufferInstance, GoBuf data);
+void BufferInstance_drain(void* bufferInstance, uint64_t size);
+void BufferInstance_move(void* bufferInstance, void* rhs);
+void BufferInstance_replace(void* bufferInstance, GoBuf data);
 
 // RequestHeaderMap
 void RequestHeaderMap_add(void* requestHeaderMap, GoStr name, GoStr value);
 void RequestHeaderMap_set(void* requestHeaderMap, GoStr name, GoStr value);
 void RequestHeaderMap_append(void* requestHeaderMap, GoStr name, GoStr value);
-size_t RequestHeaderMap_getByPrefix(void* requestHeaderMap, GoStr prefix, GoBuf buf);
 void RequestHeaderMap_remove(void* requestHeaderMap, GoStr name);
 
 /**
@@ -156,8 +221,41 @@ void RequestHeaderMap_Authorization(void* requestHeaderMap, GoStr* value);
  */
 void RequestHeaderMap_get(void* requestHeaderMap, GoStr name, GoStr* value);
 
+/**
+ * Gets all values of the header name, in insertion order. Header values are
+ * stored in values, which must have room for max entries.
+ * Header values are managed by RequestHeaderMap.
+ * Callers should not free memory returned in values.
+ * @param requestHeaderMap pointer to Envoy::Http::RequestHeaderMap object.
+ * @param name header name. It's caller's responsibility to manage name's memory.
+ * @param max capacity of values.
+ * @param values placeholder containing info about header values.
+ * @return number of values stored.
+ */
+uint64_t RequestHeaderMap_getAll(void* requestHeaderMap, GoStr name, uint64_t max, GoStr* values);
+
+/**
+ * Gets all headers, in insertion order. Header keys and values are stored in
+ * keys and values, which must have room for max entries each.
+ * Header keys and values are managed by RequestHeaderMap.
+ * Callers should not free memory returned in keys and values.
+ * @param requestHeaderMap pointer to Envoy::Http::RequestHeaderMap object.
+ * @param max capacity of keys and values.
+ * @param keys placeholder containing info about header keys.
+ * @param values placeholder containing info about header values.
+ * @return number of headers stored.
+ */
+uint64_t RequestHeaderMap_entries(void* requestHeaderMap, uint64_t max, GoStr* keys, GoStr* values);
+uint64_t RequestHeaderMap_size(void* requestHeaderMap);
+uint64_t RequestHeaderMap_byteSize(void* requestHeaderMap);
+
 // RequestTrailerMap
 void RequestTrailerMap_add(void* requestTrailerMap, GoStr name, GoStr value);
+void RequestTrailerMap_get(void* requestTrailerMap, GoStr name, GoStr* value);
+uint64_t RequestTrailerMap_getAll(void* requestTrailerMap, GoStr name, uint64_t max, GoStr* values);
+uint64_t RequestTrailerMap_entries(void* requestTrailerMap, uint64_t max, GoStr* keys, GoStr* values);
+uint64_t RequestTrailerMap_size(void* requestTrailerMap);
+uint64_t RequestTrailerMap_byteSize(void* requestTrailerMap);
 
 // ResponseHeaderMap
 void ResponseHeaderMap_add(void* responseHeaderMap, GoStr name, GoStr value);
@@ -168,6 +266,21 @@ void ResponseHeaderMap_get(void* responseHeaderMap, GoStr name, GoStr* value);
 void ResponseHeaderMap_ContentType(void* responseHeaderMap, GoStr* value);
 void ResponseHeaderMap_Status(void* responseHeaderMap, GoStr* value);
 void ResponseHeaderMap_setStatus(void* responseHeaderMap, int status);
+uint64_t ResponseHeaderMap_getAll(void* responseHeaderMap, GoStr name, uint64_t max, GoStr* values);
+uint64_t ResponseHeaderMap_entries(void* responseHeaderMap, uint64_t max, GoStr* keys, GoStr* values);
+uint64_t ResponseHeaderMap_size(void* responseHeaderMap);
+uint64_t ResponseHeaderMap_byteSize(void* responseHeaderMap);
+
+// ResponseTrailerMap
+void ResponseTrailerMap_add(void* responseTrailerMap, GoStr name, GoStr value);
+void ResponseTrailerMap_set(void* responseTrailerMap, GoStr name, GoStr value);
+void ResponseTrailerMap_append(void* responseTrailerMap, GoStr name, GoStr value);
+void ResponseTrailerMap_remove(void* responseTrailerMap, GoStr name);
+void ResponseTrailerMap_get(void* responseTrailerMap, GoStr name, GoStr* value);
+uint64_t ResponseTrailerMap_getAll(void* responseTrailerMap, GoStr name, uint64_t max, GoStr* values);
+uint64_t ResponseTrailerMap_entries(void* responseTrailerMap, uint64_t max, GoStr* keys, GoStr* values);
+uint64_t ResponseTrailerMap_size(void* responseTrailerMap);
+uint64_t ResponseTrailerMap_byteSize(void* responseTrailerMap);
 
 // Static functions will be call from from Go ("downcalls") without a pointer
 //
@@ -257,3 +257,66 @@ int Goc_Stats_Unit_Value(Envoy::Stats::Histogram::Unit unit) {
     return 0;
   }
 }
+
+namespace {
+
+struct HeaderMapEntries {
+  absl::string_view name;
+  uint64_t max;
+  uint64_t count;
+  GoStr* keys;
+  GoStr* values;
+};
+
+void setGoStr(GoStr* dest, absl::string_view value) {
+  dest->len = value.size();
+  dest->data = const_cast<char*>(value.data());
+}
+
+} // namespace
+
+uint64_t Goc_HeaderMap_entries(const Envoy::Http::HeaderMap& headers, uint64_t max, GoStr* keys,
+                               GoStr* values) {
+  auto entries = HeaderMapEntries{{}, max, 0, keys, values};
+
+  // key() and value() return references, therefore the string views remain
+  // valid until the header map is modified.
+  headers.iterate(
+      [](const Envoy::Http::HeaderEntry& header, void* context) -> Envoy::Http::HeaderMap::Iterate {
+        auto entries = static_cast<HeaderMapEntries*>(context);
+        if (entries->count >= entries->max) {
+          return Envoy::Http::HeaderMap::Iterate::Break;
+        }
+        setGoStr(entries->keys + entries->count, header.key().getStringView());
+        setGoStr(entries->values + entries->count, header.value().getStringView());
+        entries->count++;
+        return Envoy::Http::HeaderMap::Iterate::Continue;
+      },
+      &entries);
+
+  return entries.count;
+}
+
+uint64_t Goc_HeaderMap_getAll(const Envoy::Http::HeaderMap& headers, GoStr name, uint64_t max,
+                              GoStr* values) {
+  // LowerCaseString creates a wrapped lowercase copy of name, which has to
+  // outlive the iteration.
+  auto w_name = Envoy::Http::LowerCaseString(std::string(name.data, name.len));
+  auto entries = HeaderMapEntries{w_name.get(), max, 0, nullptr, values};
+
+  headers.iterate(
+      [](const Envoy::Http::HeaderEntry& header, void* context) -> Envoy::Http::HeaderMap::Iterate {
+        auto entries = static_cast<HeaderMapEntries*>(context);
+        if (entries->count >= entries->max) {
+          return Envoy::Http::HeaderMap::Iterate::Break;
+        }
+        if (header.key().getStringView() == entries->name) {
+          setGoStr(entries->values + entries->count, header.value().getStringView());
+          entries->count++;
+        }
+        return Envoy::Http::HeaderMap::Iterate::Continue;
+      },
+      &entries);
+
+  return entries.count;
+}
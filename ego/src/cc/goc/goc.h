@@ -8,9 +8,12 @@
 #define CGO_GOC_H
 
 #include "envoy/http/filter.h"
+#include "envoy/http/header_map.h"
 #include "envoy/stats/stats.h"
 #include "envoy/stream_info/filter_state.h"
 
+#include "envoy.h"
+
 // FilterStatus conversion
 Envoy::Http::FilterHeadersStatus Goc_FilterHeadersStatus(int status);
 Envoy::Http::FilterTrailersStatus Goc_FilterTrailersStatus(int status);
@@ -24,5 +27,10 @@ Envoy::StreamInfo::FilterState::LifeSpan Goc_FilterStateLifeSpan(int lifeSpan);
 Envoy::Stats::Gauge::ImportMode Goc_Stats_ImportMode(int importMode);
 Envoy::Stats::Histogram::Unit Goc_Stats_Unit(int unit);
 int Goc_Stats_Unit_Value(Envoy::Stats::Histogram::Unit unit);
+// HeaderMap iteration, shared by all header and trailer maps
+uint64_t Goc_HeaderMap_entries(const Envoy::Http::HeaderMap& headers, uint64_t max, GoStr* keys,
+                               GoStr* values);
+uint64_t Goc_HeaderMap_getAll(const Envoy::Http::HeaderMap& headers, GoStr name, uint64_t max,
+                              GoStr* values);
 
 #endif // CGO_GOC_H
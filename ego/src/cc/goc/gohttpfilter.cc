@@ -3,9 +3,13 @@
 // Use of this source code is governed by the Apache License 2.0 that can be
 // found in the LICENSE file
 
+#include "common/buffer/buffer_impl.h"
 #include "common/common/empty_string.h"
 #include "common/config/datasource.h"
 #include "common/http/header_map_impl.h"
+#include "common/http/message_impl.h"
+#include "common/http/utility.h"
+#include "common/protobuf/protobuf.h"
 #include "common/router/string_accessor_impl.h"
 
 #include "ego/src/cc/filter/http/filter.h"
@@ -134,6 +138,215 @@ int GoHttpFilter_StreamFilterCallbacks_StreamInfo_responseCode(void* goHttpFilte
                         .responseCode().value_or(0);
 }
 
+int GoHttpFilter_StreamFilterCallbacks_StreamInfo_protocol(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  auto protocol = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                      ->streamFilterCallbacks(encoder)
+                      ->streamInfo()
+                      .protocol();
+  if (!protocol.has_value()) {
+    return 0;
+  }
+
+  // protocol strings are static
+  const auto& protocol_string = Envoy::Http::Utility::getProtocolString(protocol.value());
+  value->len = protocol_string.size();
+  value->data = const_cast<char*>(protocol_string.data());
+  return 1;
+}
+
+static void Goc_AddressString(const Envoy::Network::Address::InstanceConstSharedPtr& address,
+                              GoStr* value) {
+  if (nullptr == address) {
+    value->len = 0;
+    value->data = nullptr;
+    return;
+  }
+
+  // asString() refers to memory owned by the address
+  const auto& address_string = address->asString();
+  value->len = address_string.size();
+  value->data = const_cast<char*>(address_string.data());
+}
+
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamRemoteAddress(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  Goc_AddressString(static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                        ->streamFilterCallbacks(encoder)
+                        ->streamInfo()
+                        .downstreamRemoteAddress(),
+                    value);
+}
+
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamLocalAddress(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  Goc_AddressString(static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                        ->streamFilterCallbacks(encoder)
+                        ->streamInfo()
+                        .downstreamLocalAddress(),
+                    value);
+}
+
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_upstreamHost(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  auto host = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                  ->streamFilterCallbacks(encoder)
+                  ->streamInfo()
+                  .upstreamHost();
+  if (nullptr == host) {
+    value->len = 0;
+    value->data = nullptr;
+    return;
+  }
+
+  // the host is kept alive by the stream info
+  Goc_AddressString(host->address(), value);
+}
+
+void GoHttpFilter_StreamFilterCallbacks_StreamInfo_routeName(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  const auto& route_name = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                               ->streamFilterCallbacks(encoder)
+                               ->streamInfo()
+                               .getRouteName();
+  value->len = route_name.size();
+  value->data = const_cast<char*>(route_name.data());
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_startTime(void* goHttpFilter, int encoder) {
+  auto start_time = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                        ->streamFilterCallbacks(encoder)
+                        ->streamInfo()
+                        .startTime();
+  return std::chrono::duration_cast<std::chrono::nanoseconds>(start_time.time_since_epoch()).count();
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_lastDownstreamRxByteReceived(void* goHttpFilter, int encoder) {
+  auto duration = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                      ->streamFilterCallbacks(encoder)
+                      ->streamInfo()
+                      .lastDownstreamRxByteReceived();
+  return duration.value_or(std::chrono::nanoseconds(-1)).count();
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_firstUpstreamRxByteReceived(void* goHttpFilter, int encoder) {
+  auto duration = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                      ->streamFilterCallbacks(encoder)
+                      ->streamInfo()
+                      .firstUpstreamRxByteReceived();
+  return duration.value_or(std::chrono::nanoseconds(-1)).count();
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_firstDownstreamTxByteSent(void* goHttpFilter, int encoder) {
+  auto duration = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                      ->streamFilterCallbacks(encoder)
+                      ->streamInfo()
+                      .firstDownstreamTxByteSent();
+  return duration.value_or(std::chrono::nanoseconds(-1)).count();
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_requestComplete(void* goHttpFilter, int encoder) {
+  auto duration = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                      ->streamFilterCallbacks(encoder)
+                      ->streamInfo()
+                      .requestComplete();
+  return duration.value_or(std::chrono::nanoseconds(-1)).count();
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamSslConnection(void* goHttpFilter, int encoder,
+                                                                              GoBuf buf) {
+  ASSERT(nullptr != goHttpFilter);
+
+  auto ssl = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                 ->streamFilterCallbacks(encoder)
+                 ->streamInfo()
+                 .downstreamSslConnection();
+  if (nullptr == ssl) {
+    return -1;
+  }
+
+  auto result = ego::http::SslConnectionInfo{};
+  result.set_peer_certificate_presented(ssl->peerCertificatePresented());
+  for (const auto& san : ssl->uriSanPeerCertificate()) {
+    result.add_uri_san_peer_certificate(san);
+  }
+  for (const auto& san : ssl->dnsSansPeerCertificate()) {
+    result.add_dns_sans_peer_certificate(san);
+  }
+  result.set_subject_peer_certificate(ssl->subjectPeerCertificate());
+
+  // the buffer is too small. Return required size.
+  const auto size = result.ByteSizeLong();
+  if (size > buf.len) {
+    return size;
+  }
+
+  // serialize data.
+  result.SerializePartialToArray(buf.data, buf.len);
+  return size;
+}
+
+size_t GoHttpFilter_StreamFilterCallbacks_StreamInfo_DynamicMetadata_get(void* goHttpFilter, int encoder,
+                                                                         GoStr filterName, GoStr key,
+                                                                         GoBuf buf) {
+  ASSERT(nullptr != goHttpFilter);
+
+  const auto& filter_metadata = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+                                    ->streamFilterCallbacks(encoder)
+                                    ->streamInfo()
+                                    .dynamicMetadata()
+                                    .filter_metadata();
+
+  auto it = filter_metadata.find(std::string(filterName.data, filterName.len));
+  if (it == filter_metadata.end()) {
+    return 0;
+  }
+
+  const auto& fields = it->second.fields();
+  auto field = fields.find(std::string(key.data, key.len));
+  if (field == fields.end()) {
+    return 0;
+  }
+
+  // the buffer is too small. Return required size.
+  const auto size = field->second.ByteSizeLong();
+  if (size > buf.len) {
+    return size;
+  }
+
+  // serialize data.
+  field->second.SerializePartialToArray(buf.data, buf.len);
+  return size;
+}
+
+int GoHttpFilter_StreamFilterCallbacks_StreamInfo_setDynamicMetadata(void* goHttpFilter, int encoder,
+                                                                     GoStr filterName, GoBuf valueBuf) {
+  ASSERT(nullptr != goHttpFilter);
+
+  auto value = ProtobufWkt::Struct{};
+  if (!value.ParseFromArray(valueBuf.data, valueBuf.len)) {
+    // non-zero returned value means errors.
+    return 1;
+  }
+
+  // setDynamicMetadata merges value into the existing namespace
+  static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+      ->streamFilterCallbacks(encoder)
+      ->streamInfo()
+      .setDynamicMetadata(std::string(filterName.data, filterName.len), value);
+  return 0;
+}
+
 int GoHttpFilter_StreamFilterCallbacks_routeExisting(void* goHttpFilter, int encoder) {
   ASSERT(nullptr != goHttpFilter);
 
@@ -142,6 +355,87 @@ int GoHttpFilter_StreamFilterCallbacks_routeExisting(void* goHttpFilter, int enc
   return nullptr == route? 0: 1;
 }
 
+int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_clusterName(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  auto route =
+      static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->streamFilterCallbacks(encoder)->route();
+  if (nullptr == route || nullptr == route->routeEntry()) {
+    return 1;
+  }
+
+  const auto& cluster_name = route->routeEntry()->clusterName();
+  value->len = cluster_name.size();
+  value->data = const_cast<char*>(cluster_name.data());
+  return 0;
+}
+
+int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_routeName(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  auto route =
+      static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->streamFilterCallbacks(encoder)->route();
+  if (nullptr == route || nullptr == route->routeEntry()) {
+    return 1;
+  }
+
+  const auto& route_name = route->routeEntry()->routeName();
+  value->len = route_name.size();
+  value->data = const_cast<char*>(route_name.data());
+  return 0;
+}
+
+int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_virtualHost_name(void* goHttpFilter, int encoder, GoStr* value) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != value);
+
+  auto route =
+      static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->streamFilterCallbacks(encoder)->route();
+  if (nullptr == route || nullptr == route->routeEntry()) {
+    return 1;
+  }
+
+  const auto& name = route->routeEntry()->virtualHost().name();
+  value->len = name.size();
+  value->data = const_cast<char*>(name.data());
+  return 0;
+}
+
+int64_t GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(void* goHttpFilter, int encoder,
+                                                                     GoStr filterName, GoBuf buf) {
+  ASSERT(nullptr != goHttpFilter);
+
+  auto route =
+      static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->streamFilterCallbacks(encoder)->route();
+  if (nullptr == route || nullptr == route->routeEntry()) {
+    return -1;
+  }
+
+  const auto& filter_metadata = route->routeEntry()->metadata().filter_metadata();
+  auto it = filter_metadata.find(std::string(filterName.data, filterName.len));
+  if (it == filter_metadata.end()) {
+    return -1;
+  }
+
+  // the buffer is too small. Return required size.
+  const auto size = it->second.ByteSizeLong();
+  if (size > buf.len) {
+    return size;
+  }
+
+  // serialize data.
+  it->second.SerializePartialToArray(buf.data, buf.len);
+  return size;
+}
+
+void GoHttpFilter_StreamFilterCallbacks_clearRouteCache(void* goHttpFilter, int encoder) {
+  ASSERT(nullptr != goHttpFilter);
+
+  static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->streamFilterCallbacks(encoder)->clearRouteCache();
+}
+
 int GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matcher(void* goHttpFilter, int encoder, GoStr* value) {
   ASSERT(nullptr != goHttpFilter);
   ASSERT(nullptr != value);
@@ -242,6 +536,48 @@ void GoHttpFilter_EncoderCallbacks_continueEncoding(void* goHttpFilter) {
   static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->encoderCallbacks()->continueEncoding();
 }
 
+GoError GoHttpFilter_AsyncClient_send(void* goHttpFilter, uint64_t tag, GoStr cluster,
+                                      GoBuf headersBuf, GoBuf body, uint64_t timeoutMs) {
+  ASSERT(nullptr != goHttpFilter);
+
+  auto headers = ego::http::RequestHeaderMap{};
+  if (!headers.ParseFromArray(headersBuf.data, headersBuf.len)) {
+    return "can't parse request headers";
+  }
+
+  auto request_headers = std::make_unique<Envoy::Http::RequestHeaderMapImpl>();
+  for (const auto& h : headers.headers()) {
+    request_headers->addCopy(Envoy::Http::LowerCaseString(h.key()), h.value());
+  }
+
+  auto request = std::make_unique<Envoy::Http::RequestMessageImpl>(std::move(request_headers));
+  if (0 < body.len) {
+    // OwnedImpl copies body data
+    request->body() = std::make_unique<Envoy::Buffer::OwnedImpl>(body.data, body.len);
+  }
+
+  return static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)
+      ->asyncSend(tag, std::string(cluster.data, cluster.len), std::move(request),
+                  std::chrono::milliseconds(timeoutMs));
+}
+
+GoError GoHttpFilter_AsyncClient_response(void* goHttpFilter, uint64_t tag, void** headers,
+                                          void** body) {
+  ASSERT(nullptr != goHttpFilter);
+  ASSERT(nullptr != headers);
+  ASSERT(nullptr != body);
+
+  Envoy::Http::ResponseMessage* response = nullptr;
+  auto err = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter)->asyncResponse(tag, &response);
+  if (err) {
+    return err;
+  }
+
+  *headers = &response->headers();
+  *body = response->body().get();
+  return nullptr;
+}
+
 void GoHttpFilter_GenericSecretConfigProvider_secret(void* goHttpFilter, GoStr* value) {
   auto filter = static_cast<Envoy::Http::GoHttpFilter*>(goHttpFilter);
   auto secretProvider = filter->genericSecretConfigProvider();
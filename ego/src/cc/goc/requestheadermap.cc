@@ -5,9 +5,8 @@
 
 #include "envoy/http/header_map.h"
 
-#include "absl/strings/match.h"
-#include "ego/src/cc/goc/proto/dto.pb.validate.h"
 #include "envoy.h"
+#include "goc.h"
 
 void RequestHeaderMap_add(void* requestHeaderMap, GoStr name, GoStr value) {
   auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
@@ -66,45 +65,6 @@ void RequestHeaderMap_append(void* requestHeaderMap, GoStr name, GoStr value) {
   that->appendCopy(w_name, w_value);
 }
 
-size_t RequestHeaderMap_getByPrefix(void* requestHeaderMap, GoStr prefix, GoBuf buf) {
-  auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
-
-  auto c_prefix = std::string(prefix.data, prefix.len);
-
-  auto result = ego::http::RequestHeaderMap{};
-  auto args = std::make_pair(c_prefix, &result);
-
-  that->iterate(
-      [](const Envoy::Http::HeaderEntry& header, void* context) -> Envoy::Http::HeaderMap::Iterate {
-        auto key_ret = static_cast<std::pair<std::string, ego::http::RequestHeaderMap*>*>(context);
-        const absl::string_view header_key_view = header.key().getStringView();
-        if (absl::StartsWith(header_key_view, key_ret->first)) {
-          auto entry = std::make_unique<ego::http::HeaderEntry>();
-          entry->set_key(header.key().getStringView().data(), header.key().getStringView().size());
-          entry->set_value(header.value().getStringView().data(),
-                           header.value().getStringView().size());
-          key_ret->second->mutable_headers()->Add(std::move(*entry));
-        }
-        return Envoy::Http::HeaderMap::Iterate::Continue;
-      },
-      &args);
-
-  // no headers matches the prefix
-  if (0 == result.headers_size()) {
-    return 0;
-  }
-
-  // the buffer is too small. Return required size.
-  const auto size = result.ByteSizeLong();
-  if (size > buf.len) {
-    return size;
-  }
-
-  // serialize data.
-  result.SerializePartialToArray(buf.data, buf.len);
-  return size;
-}
-
 void RequestHeaderMap_remove(void* requestHeaderMap, GoStr name) {
   auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
 
@@ -215,3 +175,27 @@ void RequestHeaderMap_get(void* requestHeaderMap, GoStr key, GoStr* value) {
   value->len = valStringView.size();
   value->data = const_cast<char*>(valStringView.data());
 }
+
+uint64_t RequestHeaderMap_getAll(void* requestHeaderMap, GoStr name, uint64_t max, GoStr* values) {
+  ASSERT(nullptr != requestHeaderMap);
+
+  auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
+  return Goc_HeaderMap_getAll(*that, name, max, values);
+}
+
+uint64_t RequestHeaderMap_entries(void* requestHeaderMap, uint64_t max, GoStr* keys, GoStr* values) {
+  ASSERT(nullptr != requestHeaderMap);
+
+  auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
+  return Goc_HeaderMap_entries(*that, max, keys, values);
+}
+
+uint64_t RequestHeaderMap_size(void* requestHeaderMap) {
+  auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
+  return that->size();
+}
+
+uint64_t RequestHeaderMap_byteSize(void* requestHeaderMap) {
+  auto that = static_cast<Envoy::Http::RequestHeaderMap*>(requestHeaderMap);
+  return that->byteSize();
+}
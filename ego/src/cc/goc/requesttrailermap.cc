@@ -6,6 +6,7 @@
 #include "envoy/http/header_map.h"
 
 #include "envoy.h"
+#include "goc.h"
 
 void RequestTrailerMap_add(void* requestTrailerMap, GoStr name, GoStr value) {
 
@@ -25,4 +26,49 @@ void RequestTrailerMap_add(void* requestTrailerMap, GoStr name, GoStr value) {
   // ...addCopy will add another header `w_name` associated with a copy of
   // `w_value`
   that->addCopy(w_name, w_value);
-}
\ No newline at end of file
+}
+
+void RequestTrailerMap_get(void* requestTrailerMap, GoStr key, GoStr* value) {
+  ASSERT(nullptr != requestTrailerMap);
+  ASSERT(nullptr != value);
+
+  auto that = static_cast<Envoy::Http::RequestTrailerMap*>(requestTrailerMap);
+
+  auto c_name = std::string(key.data, key.len);
+  auto w_name = Envoy::Http::LowerCaseString(c_name);
+
+  if (that->get(w_name) == nullptr) {
+    return;
+  }
+
+  auto valStringView = that->get(w_name)->value().getStringView();
+
+  // get() returns a pointer, value() returns a reference,
+  // therefore getStringView().data() should be valid after return
+  value->len = valStringView.size();
+  value->data = const_cast<char*>(valStringView.data());
+}
+
+uint64_t RequestTrailerMap_getAll(void* requestTrailerMap, GoStr name, uint64_t max, GoStr* values) {
+  ASSERT(nullptr != requestTrailerMap);
+
+  auto that = static_cast<Envoy::Http::RequestTrailerMap*>(requestTrailerMap);
+  return Goc_HeaderMap_getAll(*that, name, max, values);
+}
+
+uint64_t RequestTrailerMap_entries(void* requestTrailerMap, uint64_t max, GoStr* keys, GoStr* values) {
+  ASSERT(nullptr != requestTrailerMap);
+
+  auto that = static_cast<Envoy::Http::RequestTrailerMap*>(requestTrailerMap);
+  return Goc_HeaderMap_entries(*that, max, keys, values);
+}
+
+uint64_t RequestTrailerMap_size(void* requestTrailerMap) {
+  auto that = static_cast<Envoy::Http::RequestTrailerMap*>(requestTrailerMap);
+  return that->size();
+}
+
+uint64_t RequestTrailerMap_byteSize(void* requestTrailerMap) {
+  auto that = static_cast<Envoy::Http::RequestTrailerMap*>(requestTrailerMap);
+  return that->byteSize();
+}
@@ -6,8 +6,8 @@
 #include "envoy/http/header_map.h"
 
 #include "absl/strings/match.h"
-#include "ego/src/cc/goc/proto/dto.pb.validate.h"
 #include "envoy.h"
+#include "goc.h"
 
 void ResponseHeaderMap_add(void* responseHeaderMap, GoStr name, GoStr value) {
   auto that = static_cast<Envoy::Http::ResponseHeaderMap*>(responseHeaderMap);
@@ -137,3 +137,27 @@ void ResponseHeaderMap_setStatus(void* responseHeaderMap, int status) {
   auto that = static_cast<Envoy::Http::ResponseHeaderMap*>(responseHeaderMap);
   that->setStatus(status);
 }
+
+uint64_t ResponseHeaderMap_getAll(void* responseHeaderMap, GoStr name, uint64_t max, GoStr* values) {
+  ASSERT(nullptr != responseHeaderMap);
+
+  auto that = static_cast<Envoy::Http::ResponseHeaderMap*>(responseHeaderMap);
+  return Goc_HeaderMap_getAll(*that, name, max, values);
+}
+
+uint64_t ResponseHeaderMap_entries(void* responseHeaderMap, uint64_t max, GoStr* keys, GoStr* values) {
+  ASSERT(nullptr != responseHeaderMap);
+
+  auto that = static_cast<Envoy::Http::ResponseHeaderMap*>(responseHeaderMap);
+  return Goc_HeaderMap_entries(*that, max, keys, values);
+}
+
+uint64_t ResponseHeaderMap_size(void* responseHeaderMap) {
+  auto that = static_cast<Envoy::Http::ResponseHeaderMap*>(responseHeaderMap);
+  return that->size();
+}
+
+uint64_t ResponseHeaderMap_byteSize(void* responseHeaderMap) {
+  auto that = static_cast<Envoy::Http::ResponseHeaderMap*>(responseHeaderMap);
+  return that->byteSize();
+}
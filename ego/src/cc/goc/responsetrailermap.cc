@@ -0,0 +1,121 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+#include "envoy/http/header_map.h"
+
+#include "envoy.h"
+#include "goc.h"
+
+void ResponseTrailerMap_add(void* responseTrailerMap, GoStr name, GoStr value) {
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+
+  // The std::string() constructor will create a copy of name. Unfortunately,
+  // there is no std::string_view, because...
+  auto c_name = std::string(name.data, name.len);
+
+  // ...LowerCaseString creates a wrapped lowercase copy of c_name.
+  auto w_name = Envoy::Http::LowerCaseString(c_name);
+
+  // we are wrapping the trailer value with a lightweight string_view, which
+  // is safe because...
+  auto w_value = absl::string_view(value.data, value.len);
+
+  // ...addCopy will add another trailer `w_name` associated with a copy of
+  // `w_value`
+  that->addCopy(w_name, w_value);
+}
+
+void ResponseTrailerMap_set(void* responseTrailerMap, GoStr name, GoStr value) {
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+
+  // The std::string() constructor will create a copy of name. Unfortunately,
+  // there is no std::string_view, because...
+  auto c_name = std::string(name.data, name.len);
+
+  // ...LowerCaseString creates a wrapped lowercase copy of c_name.
+  auto w_name = Envoy::Http::LowerCaseString(c_name);
+
+  // we are wrapping the trailer value with a lightweight string_view, which
+  // is safe because...
+  auto w_value = absl::string_view(value.data, value.len);
+
+  // ...setCopy will replace all trailers `w_name` with a copy of `w_value`
+  that->setCopy(w_name, w_value);
+}
+
+void ResponseTrailerMap_append(void* responseTrailerMap, GoStr name, GoStr value) {
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+
+  // The std::string() constructor will create a copy of name. Unfortunately,
+  // there is no std::string_view, because...
+  auto c_name = std::string(name.data, name.len);
+
+  // ...LowerCaseString creates a wrapped lowercase copy of c_name.
+  auto w_name = Envoy::Http::LowerCaseString(c_name);
+
+  // we are wrapping the trailer value with a lightweight string_view, which
+  // is safe because...
+  auto w_value = absl::string_view(value.data, value.len);
+
+  // ...appendCopy will append a copy of `w_value` to the trailer `w_name`
+  that->appendCopy(w_name, w_value);
+}
+
+void ResponseTrailerMap_remove(void* responseTrailerMap, GoStr name) {
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+
+  // The std::string() constructor will create a copy of name. Unfortunately,
+  // there is no std::string_view, because...
+  auto c_name = std::string(name.data, name.len);
+
+  // ...LowerCaseString creates a wrapped lowercase copy of c_name.
+  auto w_name = Envoy::Http::LowerCaseString(c_name);
+  that->remove(w_name);
+}
+
+void ResponseTrailerMap_get(void* responseTrailerMap, GoStr key, GoStr* value) {
+  ASSERT(nullptr != responseTrailerMap);
+  ASSERT(nullptr != value);
+
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+
+  auto c_name = std::string(key.data, key.len);
+  auto w_name = Envoy::Http::LowerCaseString(c_name);
+
+  if (that->get(w_name) == nullptr) {
+    return;
+  }
+
+  auto valStringView = that->get(w_name)->value().getStringView();
+
+  // get() returns a pointer, value() returns a reference,
+  // therefore getStringView().data() should be valid after return
+  value->len = valStringView.size();
+  value->data = const_cast<char*>(valStringView.data());
+}
+
+uint64_t ResponseTrailerMap_getAll(void* responseTrailerMap, GoStr name, uint64_t max, GoStr* values) {
+  ASSERT(nullptr != responseTrailerMap);
+
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+  return Goc_HeaderMap_getAll(*that, name, max, values);
+}
+
+uint64_t ResponseTrailerMap_entries(void* responseTrailerMap, uint64_t max, GoStr* keys, GoStr* values) {
+  ASSERT(nullptr != responseTrailerMap);
+
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+  return Goc_HeaderMap_entries(*that, max, keys, values);
+}
+
+uint64_t ResponseTrailerMap_size(void* responseTrailerMap) {
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+  return that->size();
+}
+
+uint64_t ResponseTrailerMap_byteSize(void* responseTrailerMap) {
+  auto that = static_cast<Envoy::Http::ResponseTrailerMap*>(responseTrailerMap);
+  return that->byteSize();
+}
@@ -7,7 +7,9 @@ package envoy
 
 import (
 	"io"
+	"time"
 
+	structpb "github.com/golang/protobuf/ptypes/struct"
 	pb "github.com/grab/ego/ego/src/cc/goc/proto"
 
 	"github.com/grab/ego/ego/src/go/envoy/lifespan"
@@ -28,6 +30,8 @@ type GoHttpFilterConfig interface {
 }
 
 type GoHttpFilter interface {
+	// Post schedules a call to OnPost(tag) in the dispatcher context. Tags
+	// with the most significant bit set are reserved for AsyncClient.
 	Post(uint64)
 	DecoderCallbacks() DecoderFilterCallbacks
 	EncoderCallbacks() EncoderFilterCallbacks
@@ -36,6 +40,7 @@ type GoHttpFilter interface {
 	Log(loglevel.Type, string)
 	ResolveMostSpecificPerGoFilterConfig(name string, route Route) interface{}
 	GenericSecretProvider() GenericSecretConfigProvider
+	AsyncClient() AsyncClient
 }
 
 type StreamFilterCallbacks interface {
@@ -43,6 +48,10 @@ type StreamFilterCallbacks interface {
 	Route() Route
 	RouteExisting() bool
 	ActiveSpan() Span
+
+	// ClearRouteCache makes Envoy pick the route again, e.g. after request
+	// headers used for routing were changed.
+	ClearRouteCache()
 }
 
 type DecoderFilterCallbacks interface {
@@ -77,6 +86,23 @@ type BufferInstance interface {
 
 	// NewReader is a Go convenience function
 	NewReader(start uint64) io.Reader
+
+	// Copy data into the end of the buffer.
+	Add(data []byte)
+
+	// Copy data into the front of the buffer.
+	Prepend(data []byte)
+
+	// Drain up to size bytes from the front of the buffer.
+	Drain(size uint64)
+
+	// Move all data from rhs to the end of this buffer. Slices are handed over
+	// without copying if rhs is backed by an Envoy buffer, too.
+	Move(rhs BufferInstance)
+
+	// Replace is a Go convenience function to drain the buffer and add a copy
+	// of data in a single call.
+	Replace(data []byte)
 }
 
 // RequestHeaderMap is a proxy for Envoy::Http::RequestHeaderMap
@@ -89,6 +115,19 @@ type HeaderMap interface {
 
 type HeaderMapReadOnly interface {
 	Get(name string) volatile.String
+
+	// GetAll returns all values of the header name, in insertion order.
+	GetAll(name string) []volatile.String
+
+	// Iterate calls cb for every header, in insertion order, until cb returns
+	// false. The header map must not be modified from within cb.
+	Iterate(cb func(key, value volatile.String) bool)
+
+	// Size returns the number of headers.
+	Size() uint64
+
+	// ByteSize returns the total length of all header keys and values.
+	ByteSize() uint64
 }
 
 type headerMapUpdatable interface {
@@ -123,7 +162,8 @@ type RequestHeaderMapReadOnly interface {
 	Method() volatile.String
 	Authorization() volatile.String
 	// There is no method with this name in Envoy.
-	// It's a utilitity for Go filters to query headers by prefix.
+	// It's a utilitity for Go filters to query headers by prefix. All values
+	// are returned for headers occurring more than once.
 	GetByPrefix(prefix string) map[string][]string
 }
 
@@ -160,12 +200,62 @@ type responseHeaderMapUpdatable interface {
 	SetStatus(status int)
 }
 
+type ResponseTrailerMap interface {
+	ResponseTrailerMapReadOnly
+	responseTrailerMapUpdatable
+}
+
+type responseTrailerMapUpdatable interface {
+	headerMapUpdatable
+}
+
+type ResponseTrailerMapReadOnly interface {
+	HeaderMapReadOnly
+}
+
 type StreamInfo interface {
 	FilterState() FilterState
 	LastDownstreamTxByteSent() int64
 	GetRequestHeaders() RequestHeaderMapReadOnly
 	ResponseCode() int
 	ResponseCodeDetails() volatile.String
+	DynamicMetadata() DynamicMetadata
+
+	// Protocol returns the downstream protocol, e.g. "HTTP/1.1", or false if
+	// it isn't known yet.
+	Protocol() (string, bool)
+
+	// Addresses are formatted like "10.0.0.1:443". They are empty if unknown.
+	DownstreamRemoteAddress() volatile.String
+	DownstreamLocalAddress() volatile.String
+	UpstreamHost() volatile.String
+
+	RouteName() volatile.String
+
+	// StartTime returns the time the first byte of the request was received.
+	StartTime() time.Time
+
+	// The following durations are relative to StartTime. They return false
+	// if the event hasn't happened yet.
+	LastDownstreamRxByteReceived() (time.Duration, bool)
+	FirstUpstreamRxByteReceived() (time.Duration, bool)
+	FirstDownstreamTxByteSent() (time.Duration, bool)
+	RequestComplete() (time.Duration, bool)
+
+	// DownstreamSslConnection returns a copy of the peer certificate details,
+	// or nil if the downstream connection doesn't use TLS.
+	DownstreamSslConnection() *SslConnectionInfo
+}
+
+// SslConnectionInfo is a copy of the peer certificate details of
+// Envoy::Ssl::ConnectionInfo.
+//
+// See //envoy/include/envoy/ssl/connection.h
+type SslConnectionInfo struct {
+	PeerCertificatePresented bool
+	UriSanPeerCertificate    []string
+	DnsSansPeerCertificate   []string
+	SubjectPeerCertificate   string
 }
 
 type FilterState interface {
@@ -173,12 +263,35 @@ type FilterState interface {
 	GetDataReadOnly(name string) (volatile.String, bool)
 }
 
+// DynamicMetadata is a proxy for the dynamic metadata of
+// Envoy::StreamInfo::StreamInfo. Values are copied in both directions.
+//
+// See //envoy/include/envoy/stream_info/stream_info.h
+type DynamicMetadata interface {
+	// Get returns the value of key in the filter metadata namespace.
+	Get(namespace, key string) (*structpb.Value, bool)
+
+	// Set merges the fields of value into the filter metadata namespace.
+	Set(namespace string, value *structpb.Struct)
+}
+
 type Route interface {
 	RouteEntry() RouteEntry
 }
 
+// RouteEntry is a proxy for Envoy::Router::RouteEntry. Its methods fail if
+// there is no route entry, e.g. for direct responses.
+//
+// See //envoy/include/envoy/router/router.h
 type RouteEntry interface {
 	PathMatchCriterion() PathMatchCriterion
+	ClusterName() (volatile.String, error)
+	RouteName() (volatile.String, error)
+	VirtualHostName() (volatile.String, error)
+
+	// Metadata returns a copy of the route's filter_metadata for namespace,
+	// or false if there is no such namespace. Empty namespaces are empty.
+	Metadata(namespace string) (*structpb.Struct, bool)
 }
 
 type PathMatchType int
@@ -195,6 +308,42 @@ type PathMatchCriterion interface {
 	Matcher() (volatile.String, error)
 }
 
+// AsyncClient is a proxy for Envoy::Http::AsyncClient, which sends requests
+// to upstream clusters and thus benefits from their load balancing, circuit
+// breakers, outlier detection, TLS settings and stats.
+//
+// See //envoy/include/envoy/http/async_client.h
+type AsyncClient interface {
+	// Send starts request to cluster. Like Post(), it may be called from any
+	// go-routine as long as the filter is pinned. The callback is invoked
+	// exactly once in the dispatcher context, via OnPost, unless an error is
+	// returned. Requests still outstanding when the filter is destroyed
+	// complete with an error.
+	Send(cluster string, request *AsyncRequest, callback func(*AsyncResponse, error)) error
+}
+
+// AsyncRequest is a request sent with AsyncClient.
+type AsyncRequest struct {
+	Method    string
+	Path      string
+	Authority string
+	Headers   map[string][]string
+	Body      []byte
+
+	// Timeout of the whole request. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// AsyncResponse is the response received by AsyncClient. It's a copy, so
+// callers are free to retain it.
+type AsyncResponse struct {
+	StatusCode int
+
+	// Headers with lower case names, excluding pseudo headers.
+	Headers map[string][]string
+	Body    []byte
+}
+
 type GenericSecretConfigProvider interface {
 	Secret() volatile.String
 }
@@ -35,6 +35,7 @@ type StreamDecoderFilter interface {
 type StreamEncoderFilter interface {
 	EncodeHeaders(envoy.ResponseHeaderMap, bool) headersstatus.Type
 	EncodeData(envoy.BufferInstance, bool) datastatus.Type
+	EncodeTrailers(envoy.ResponseTrailerMap) trailersstatus.Type
 }
 
 type HttpFilterBase struct {
@@ -94,6 +95,10 @@ func (f *HttpFilterBase) EncodeData(envoy.BufferInstance, bool) datastatus.Type
 	return datastatus.Continue
 }
 
+func (f *HttpFilterBase) EncodeTrailers(trailers envoy.ResponseTrailerMap) trailersstatus.Type {
+	return trailersstatus.Continue
+}
+
 type filterLogger struct {
 	Native envoy.GoHttpFilter
 }
@@ -0,0 +1,132 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+// #include "ego/src/cc/goc/envoy.h"
+import "C"
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/grab/ego/ego/src/cc/goc/proto"
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/envoy/loglevel"
+	"github.com/grab/ego/ego/src/go/volatile"
+)
+
+// asyncClientTagMark is set on all post tags used by asyncClient, so that
+// Cgo_GoHttpFilter_OnPost can tell them apart from those of the Go filter.
+//
+const asyncClientTagMark = uint64(1) << 63
+
+type asyncCall struct {
+	filter   unsafe.Pointer
+	callback func(*envoy.AsyncResponse, error)
+}
+
+// asyncCalls holds the callbacks of all outstanding requests by post tag.
+//
+var asyncCalls = struct {
+	sync.Mutex
+	next  uint64
+	calls map[uint64]asyncCall
+}{calls: make(map[uint64]asyncCall)}
+
+func isAsyncClientTag(tag uint64) bool {
+	return 0 != tag&asyncClientTagMark
+}
+
+func tagAsyncCall(call asyncCall) uint64 {
+	tag := atomic.AddUint64(&asyncCalls.next, 1) | asyncClientTagMark
+	asyncCalls.Lock()
+	asyncCalls.calls[tag] = call
+	asyncCalls.Unlock()
+	return tag
+}
+
+func removeAsyncCall(tag uint64) (asyncCall, bool) {
+	asyncCalls.Lock()
+	defer asyncCalls.Unlock()
+	call, ok := asyncCalls.calls[tag]
+	delete(asyncCalls.calls, tag)
+	return call, ok
+}
+
+// asyncClient implements envoy.AsyncClient
+//
+type asyncClient struct {
+	filter unsafe.Pointer
+}
+
+func (c asyncClient) Send(cluster string, request *envoy.AsyncRequest, callback func(*envoy.AsyncResponse, error)) error {
+	headerMap := pb.RequestHeaderMap{
+		Headers: []*pb.HeaderEntry{
+			{Key: ":method", Value: request.Method},
+			{Key: ":path", Value: request.Path},
+			{Key: ":authority", Value: request.Authority},
+		},
+	}
+	for k, values := range request.Headers {
+		for _, v := range values {
+			headerMap.Headers = append(headerMap.Headers, &pb.HeaderEntry{Key: k, Value: v})
+		}
+	}
+	headerBytes, err := proto.Marshal(&headerMap)
+	if err != nil {
+		return err
+	}
+
+	tag := tagAsyncCall(asyncCall{c.filter, callback})
+	err = CErr(C.GoHttpFilter_AsyncClient_send(c.filter, C.uint64_t(tag), GoStr(cluster),
+		GoBuf(headerBytes), GoBuf(request.Body), C.uint64_t(request.Timeout.Milliseconds())))
+	if err != nil {
+		removeAsyncCall(tag)
+		return err
+	}
+	return nil
+}
+
+// onAsyncClientPost completes the request identified by tag. It is called
+// from Cgo_GoHttpFilter_OnPost, which is the only time the response can be
+// accessed.
+//
+func onAsyncClientPost(tag uint64) {
+	call, ok := removeAsyncCall(tag)
+	if !ok {
+		Log(loglevel.Error, "asyncClient", "unknown request "+strconv.FormatUint(tag, 10))
+		return
+	}
+
+	var headers, body unsafe.Pointer
+	if err := CErr(C.GoHttpFilter_AsyncClient_response(call.filter, C.uint64_t(tag), &headers, &body)); err != nil {
+		call.callback(nil, err)
+		return
+	}
+	call.callback(newAsyncResponse(responseHeaderMap{headers}, body), nil)
+}
+
+func newAsyncResponse(headers responseHeaderMap, body unsafe.Pointer) *envoy.AsyncResponse {
+	response := &envoy.AsyncResponse{Headers: make(map[string][]string)}
+	response.StatusCode, _ = strconv.Atoi(string(headers.Status()))
+	headers.Iterate(func(key, value volatile.String) bool {
+		if !strings.HasPrefix(string(key), ":") {
+			k := key.Copy()
+			response.Headers[k] = append(response.Headers[k], value.Copy())
+		}
+		return true
+	})
+
+	if nil != body {
+		b := bufferInstance{body}
+		response.Body = make([]byte, b.Length())
+		b.CopyOut(0, response.Body)
+	}
+	return response
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"unsafe"
 
+	"github.com/grab/ego/ego/src/go/envoy"
 	"github.com/grab/ego/ego/src/go/volatile"
 )
 
@@ -47,6 +48,35 @@ func (b bufferInstance) Length() uint64 {
 	return uint64(C.BufferInstance_length(b.ptr))
 }
 
+func (b bufferInstance) Add(data []byte) {
+	C.BufferInstance_add(b.ptr, GoBuf(data))
+}
+
+func (b bufferInstance) Prepend(data []byte) {
+	C.BufferInstance_prepend(b.ptr, GoBuf(data))
+}
+
+func (b bufferInstance) Drain(size uint64) {
+	C.BufferInstance_drain(b.ptr, C.uint64_t(size))
+}
+
+func (b bufferInstance) Move(rhs envoy.BufferInstance) {
+	if native, ok := rhs.(bufferInstance); ok {
+		C.BufferInstance_move(b.ptr, native.ptr)
+		return
+	}
+
+	// rhs is not an Envoy buffer, so we have to copy
+	for _, slice := range rhs.GetRawSlices() {
+		b.Add(slice)
+	}
+	rhs.Drain(rhs.Length())
+}
+
+func (b bufferInstance) Replace(data []byte) {
+	C.BufferInstance_replace(b.ptr, GoBuf(data))
+}
+
 func (b bufferInstance) NewReader(start uint64) io.Reader {
 	return &bufferInstanceReader{bufferInstance: b, pos: start}
 }
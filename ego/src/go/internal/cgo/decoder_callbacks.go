@@ -89,6 +89,10 @@ func (c decoderCallbacks) Route() envoy.Route {
 	return &route{c.filter, false}
 }
 
+func (c decoderCallbacks) ClearRouteCache() {
+	C.GoHttpFilter_StreamFilterCallbacks_clearRouteCache(c.filter, GoBool(false))
+}
+
 func (c decoderCallbacks) EncodeHeaders(responseCode int, headers *pb.ResponseHeaderMap, endStream bool) {
 	headerBytes, err := proto.Marshal(headers)
 	if err != nil {
@@ -0,0 +1,35 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+// defaultDtoBufferSize fits most serialized DTOs, so that they are read with
+// a single downcall.
+const defaultDtoBufferSize = 100
+
+// readDto reads a serialized DTO with read, which returns the size of the DTO
+// and only writes it to buf if it fits, or -1 if there is no DTO. A larger
+// buffer is used for a second downcall if the first one was too small.
+//
+func readDto(read func(buf []byte) int64) ([]byte, bool) {
+	data := make([]byte, defaultDtoBufferSize)
+	size := read(data)
+	if 0 > size {
+		return nil, false
+	}
+	if size > int64(len(data)) {
+		newBufferSize := size
+		data = make([]byte, newBufferSize)
+		size = read(data)
+		if 0 > size {
+			return nil, false
+		}
+		if size > newBufferSize {
+			// it's not supposed to happen.
+			panic("Invalid logic")
+		}
+	}
+	return data[:size], true
+}
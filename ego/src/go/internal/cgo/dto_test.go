@@ -0,0 +1,80 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dtoReader returns a read function for readDto serving dto, and counting
+// its calls.
+func dtoReader(dto []byte, calls *int) func(buf []byte) int64 {
+	return func(buf []byte) int64 {
+		*calls++
+		if nil == dto {
+			return -1
+		}
+		if len(dto) <= len(buf) {
+			copy(buf, dto)
+		}
+		return int64(len(dto))
+	}
+}
+
+func TestReadDto(t *testing.T) {
+	calls := 0
+	data, ok := readDto(dtoReader([]byte("small"), &calls))
+	assert.True(t, ok)
+	assert.Equal(t, "small", string(data))
+	assert.Equal(t, 1, calls)
+
+	// empty DTOs are still DTOs
+	calls = 0
+	data, ok = readDto(dtoReader([]byte{}, &calls))
+	assert.True(t, ok)
+	assert.Empty(t, data)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	data, ok = readDto(dtoReader(nil, &calls))
+	assert.False(t, ok)
+	assert.Nil(t, data)
+	assert.Equal(t, 1, calls)
+}
+
+func TestReadDtoResize(t *testing.T) {
+	large := make([]byte, defaultDtoBufferSize+1)
+	for i := range large {
+		large[i] = byte(i)
+	}
+
+	calls := 0
+	data, ok := readDto(dtoReader(large, &calls))
+	assert.True(t, ok)
+	assert.Equal(t, large, data)
+	assert.Equal(t, 2, calls)
+
+	// the DTO is gone by the second downcall
+	calls = 0
+	data, ok = readDto(func(buf []byte) int64 {
+		calls++
+		if 1 == calls {
+			return int64(len(large))
+		}
+		return -1
+	})
+	assert.False(t, ok)
+	assert.Nil(t, data)
+
+	// the DTO grew by the second downcall
+	assert.Panics(t, func() {
+		readDto(func(buf []byte) int64 {
+			return int64(len(buf) + 1)
+		})
+	})
+}
@@ -0,0 +1,54 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+// #include "ego/src/cc/goc/envoy.h"
+import "C"
+import (
+	"unsafe"
+
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+
+	"github.com/grab/ego/ego/src/go/envoy/loglevel"
+)
+
+type dynamicMetadata struct {
+	filter  unsafe.Pointer
+	encoder bool
+}
+
+func (m dynamicMetadata) Get(namespace, key string) (*structpb.Value, bool) {
+	data, ok := readDto(func(buf []byte) int64 {
+		size := int64(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_DynamicMetadata_get(m.filter, GoBool(m.encoder), GoStr(namespace), GoStr(key), GoBuf(buf)))
+		// the value is missing if empty
+		if 0 == size {
+			return -1
+		}
+		return size
+	})
+	if !ok {
+		return nil, false
+	}
+
+	value := &structpb.Value{}
+	if err := proto.Unmarshal(data, value); err != nil {
+		Log(loglevel.Error, "dynamicMetadata", "can't unmarshal value. "+err.Error())
+		return nil, false
+	}
+	return value, true
+}
+
+func (m dynamicMetadata) Set(namespace string, value *structpb.Struct) {
+	data, err := proto.Marshal(value)
+	if err != nil {
+		Log(loglevel.Error, "dynamicMetadata", "can't marshal value. "+err.Error())
+		return
+	}
+	if 0 != C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_setDynamicMetadata(m.filter, GoBool(m.encoder), GoStr(namespace), GoBuf(data)) {
+		Log(loglevel.Error, "dynamicMetadata", "can't setDynamicMetadata")
+	}
+}
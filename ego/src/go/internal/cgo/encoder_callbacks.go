@@ -62,6 +62,10 @@ func (c encoderCallbacks) Route() envoy.Route {
 	return &route{c.filter, true}
 }
 
+func (c encoderCallbacks) ClearRouteCache() {
+	C.GoHttpFilter_StreamFilterCallbacks_clearRouteCache(c.filter, GoBool(true))
+}
+
 func (c encoderCallbacks) ActiveSpan() envoy.Span {
 	return span{filter: c.filter, spanID: 0}
 }
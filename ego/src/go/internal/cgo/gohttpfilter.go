@@ -9,6 +9,7 @@ package main
 import "C"
 import (
 	"fmt"
+	"runtime/debug"
 	"unsafe"
 
 	ego "github.com/grab/ego/ego/src/go"
@@ -20,6 +21,13 @@ import (
 	"github.com/grab/ego/ego/src/go/volatile"
 )
 
+// goHttpFilterFailed is returned by the Cgo_GoHttpFilter_* entry points in
+// place of a filter status if the Go filter panicked or could not be found.
+// The native filter then ends the stream with a local reply or a reset, or
+// aborts Envoy if crash_on_errors is set.
+//
+const goHttpFilterFailed = C.GoHttpFilter_FAILED
+
 type goHttpFilter struct {
 	filter unsafe.Pointer
 }
@@ -45,6 +53,10 @@ func (f goHttpFilter) GenericSecretProvider() envoy.GenericSecretConfigProvider
 	return genericSecretConfigProvider{f.filter}
 }
 
+func (f goHttpFilter) AsyncClient() envoy.AsyncClient {
+	return asyncClient{f.filter}
+}
+
 func (f goHttpFilter) Post(tag uint64) {
 	C.GoHttpFilter_post(f.filter, C.uint64_t(tag))
 }
@@ -87,17 +99,14 @@ func cgo_GoHttpFilter_DecodeHeaders(filterTag uint64, headers unsafe.Pointer, en
 	const tag = "cgo_GoHttpFilter_DecodeHeaders"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
-			// FIXME: emit 500
-			// TODO(tien.nguyen): think about how can call to C side without filter point to call sendLocalReply
-			result = headersstatus.StopIteration
+			logPanic(tag, err)
+			result = headersstatus.Type(goHttpFilterFailed)
 		}
 	}()
 	filter := GetHttpFilter(filterTag)
 	if nil == filter {
 		Log(loglevel.Error, tag, "nil filter")
-		// FIXME: emit 500
-		return headersstatus.StopIteration
+		return headersstatus.Type(goHttpFilterFailed)
 	}
 	return filter.DecodeHeaders(requestHeaderMap{headers}, end_stream != 0)
 }
@@ -115,15 +124,14 @@ func cgo_GoHttpFilter_DecodeData(filterTag uint64, buffer unsafe.Pointer, end_st
 	const tag = "cgo_GoHttpFilter_DecodeData"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
-			result = datastatus.StopIterationNoBuffer
+			logPanic(tag, err)
+			result = datastatus.Type(goHttpFilterFailed)
 		}
 	}()
 	filter := GetHttpFilter(filterTag)
 	if nil == filter {
 		Log(loglevel.Error, tag, "nil filter")
-		// FIXME: emit 500
-		return datastatus.StopIterationNoBuffer
+		return datastatus.Type(goHttpFilterFailed)
 	}
 	return filter.DecodeData(bufferInstance{buffer}, end_stream != 0)
 }
@@ -141,16 +149,14 @@ func cgo_GoHttpFilter_DecodeTrailers(filterTag uint64, trailers unsafe.Pointer)
 	const tag = "cgo_GoHttpFilter_DecodeTrailers"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
-			// FIXME: emit 500
-			result = trailersstatus.StopIteration
+			logPanic(tag, err)
+			result = trailersstatus.Type(goHttpFilterFailed)
 		}
 	}()
 	filter := GetHttpFilter(filterTag)
 	if nil == filter {
 		Log(loglevel.Error, tag, "nil filter")
-		// FIXME: emit 500
-		return trailersstatus.StopIteration
+		return trailersstatus.Type(goHttpFilterFailed)
 	}
 	return filter.DecodeTrailers(requestTrailerMap{trailers})
 }
@@ -160,19 +166,25 @@ func cgo_GoHttpFilter_DecodeTrailers(filterTag uint64, trailers unsafe.Pointer)
 // See //src/cc/filters/http/go/filter-cgo.cc
 //
 //export Cgo_GoHttpFilter_OnPost
-func Cgo_GoHttpFilter_OnPost(filterTag, postTag uint64) {
+func Cgo_GoHttpFilter_OnPost(filterTag, postTag uint64) (result int) {
 	const tag = "Cgo_GoHttpFilter_OnPost"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
+			logPanic(tag, err)
+			result = goHttpFilterFailed
 		}
 	}()
+	if isAsyncClientTag(postTag) {
+		onAsyncClientPost(postTag)
+		return 0
+	}
 	f := GetHttpFilter(filterTag)
 	if nil == f {
 		Log(loglevel.Error, tag, "nil filter")
-		return
+		return goHttpFilterFailed
 	}
 	f.OnPost(postTag)
+	return 0
 }
 
 // Cgo_GoHttpFilter_OnDestroy is the entry point for
@@ -184,7 +196,7 @@ func Cgo_GoHttpFilter_OnDestroy(filterTag uint64) {
 	const tag = "Cgo_GoHttpFilter_OnDestroy"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
+			logPanic(tag, err)
 		}
 	}()
 	f := RemoveHttpFilter(filterTag)
@@ -200,7 +212,7 @@ func Cgo_GoHttpFilter_Create(native unsafe.Pointer, factoryTag uint64, filterSlo
 	const tag = "Cgo_GoHttpFilter_Create"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
+			logPanic(tag, err)
 			result = 0
 		}
 	}()
@@ -232,16 +244,14 @@ func cgo_GoHttpFilter_EncodeHeaders(filterTag uint64, headers unsafe.Pointer, en
 	const tag = "cgo_GoHttpFilter_EncodeHeaders"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
-			// FIXME: emit 500
-			result = headersstatus.StopIteration
+			logPanic(tag, err)
+			result = headersstatus.Type(goHttpFilterFailed)
 		}
 	}()
 	filter := GetHttpFilter(filterTag)
 	if nil == filter {
 		Log(loglevel.Error, tag, "nil filter")
-		// FIXME: emit 500
-		return headersstatus.StopIteration
+		return headersstatus.Type(goHttpFilterFailed)
 	}
 
 	return filter.EncodeHeaders(responseHeaderMap{headers}, end_stream != 0)
@@ -256,20 +266,48 @@ func cgo_GoHttpFilter_EncodeData(filterTag uint64, buffer unsafe.Pointer, end_st
 	const tag = "cgo_GoHttpFilter_EncodeData"
 	defer func() {
 		if err := recover(); err != nil {
-			Log(loglevel.Error, tag, fmt.Sprintf("%v", err))
-			result = datastatus.StopIterationNoBuffer
+			logPanic(tag, err)
+			result = datastatus.Type(goHttpFilterFailed)
 		}
 	}()
 	filter := GetHttpFilter(filterTag)
 	if nil == filter {
 		Log(loglevel.Error, tag, "nil filter")
-		// FIXME: emit 500
-		return datastatus.StopIterationNoBuffer
+		return datastatus.Type(goHttpFilterFailed)
 	}
 
 	return filter.EncodeData(bufferInstance{buffer}, end_stream != 0)
 }
 
+//export Cgo_GoHttpFilter_EncodeTrailers
+func Cgo_GoHttpFilter_EncodeTrailers(filterTag uint64, trailers unsafe.Pointer) int {
+	return int(cgo_GoHttpFilter_EncodeTrailers(filterTag, trailers))
+}
+
+func cgo_GoHttpFilter_EncodeTrailers(filterTag uint64, trailers unsafe.Pointer) (result trailersstatus.Type) {
+	const tag = "cgo_GoHttpFilter_EncodeTrailers"
+	defer func() {
+		if err := recover(); err != nil {
+			logPanic(tag, err)
+			result = trailersstatus.Type(goHttpFilterFailed)
+		}
+	}()
+	filter := GetHttpFilter(filterTag)
+	if nil == filter {
+		Log(loglevel.Error, tag, "nil filter")
+		return trailersstatus.Type(goHttpFilterFailed)
+	}
+
+	return filter.EncodeTrailers(responseTrailerMap{trailers})
+}
+
+// logPanic logs a recovered panic along with the stack of the panicking
+// go-routine.
+//
+func logPanic(tag string, err interface{}) {
+	Log(loglevel.Error, tag, fmt.Sprintf("%v\n%s", err, debug.Stack()))
+}
+
 // httpFilters is a clutch to bridge the "air gap" between the C++ filter object
 // and the go filter state. We share this among all filters, but in case the 16M
 // clutch entries turn out to be insufficient, we can create one clutch per
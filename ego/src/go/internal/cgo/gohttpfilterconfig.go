@@ -30,14 +30,24 @@ func (c goHttpFilterConfig) Scope() envoy.Scope {
 	return c.scope
 }
 
+// setConfigError hands msg over to the native caller, which reports it when
+// rejecting the config and then frees it.
+//
+func setConfigError(errMsg **C.char, msg string) {
+	if nil != errMsg {
+		*errMsg = C.CString(msg)
+	}
+}
+
 //export Cgo_GoHttpFilterFactory_Create
 func Cgo_GoHttpFilterFactory_Create(factorySlot uint64, name *C.char, nameLen C.size_t,
-	settings unsafe.Pointer, settingsLen C.size_t, scopePtr unsafe.Pointer) (result uint64) {
+	settings unsafe.Pointer, settingsLen C.size_t, scopePtr unsafe.Pointer, errMsg **C.char) (result uint64) {
 	log := logger.NewLogger("Cgo_GoHttpFilterFactory_Create", nativeLogger{})
 
 	defer func() {
 		if err := recover(); err != nil {
-			log.Error(fmt.Sprintf("panic recover with error", err))
+			log.Error(fmt.Sprintf("panic recover with error: %v", err))
+			setConfigError(errMsg, fmt.Sprintf("panic: %v", err))
 			result = 0
 		}
 	}()
@@ -45,6 +55,7 @@ func Cgo_GoHttpFilterFactory_Create(factorySlot uint64, name *C.char, nameLen C.
 	factoryFactory := ego.GetHttpFilterFactoryFactory(CStrN(name, nameLen))
 	if nil == factoryFactory {
 		log.Error("can not find factory by name")
+		setConfigError(errMsg, "unknown filter")
 		return 0
 	}
 
@@ -56,11 +67,13 @@ func Cgo_GoHttpFilterFactory_Create(factorySlot uint64, name *C.char, nameLen C.
 	}
 	factory, err := factoryFactory.CreateFilterFactory(cfg)
 	if err != nil {
-		log.Error(fmt.Sprintf("invoke CreateFilterFactory failed with error", err))
+		log.Error(fmt.Sprintf("invoke CreateFilterFactory failed with error: %v", err))
+		setConfigError(errMsg, err.Error())
 		return 0
 	}
 	if nil == factory {
 		log.Error("invoke CreateFilterFactory without error but return nil")
+		setConfigError(errMsg, "nil filter factory")
 		return 0
 	}
 
@@ -72,7 +85,7 @@ func Cgo_GoHttpFilterFactory_OnDestroy(factoryTag uint64) {
 	log := logger.NewLogger("Cgo_GoHttpFilterFactory_OnDestroy", nativeLogger{})
 	defer func() {
 		if err := recover(); err != nil {
-			log.Error(fmt.Sprintf("panic recover with error", err))
+			log.Error(fmt.Sprintf("panic recover with error: %v", err))
 		}
 	}()
 	factory := RemoveHttpFilterFactory(factoryTag)
@@ -128,11 +141,21 @@ func RemoveHttpFilterFactory(tag uint64) ego.HttpFilterFactory {
 }
 
 //export Cgo_RouteSpecificFilterConfig_Create
-func Cgo_RouteSpecificFilterConfig_Create(configSlot uint64, name *C.char, nameLen C.size_t, settings unsafe.Pointer, settingsLen C.size_t) (result uint64) {
+func Cgo_RouteSpecificFilterConfig_Create(configSlot uint64, name *C.char, nameLen C.size_t,
+	settings unsafe.Pointer, settingsLen C.size_t, errMsg **C.char) (result uint64) {
 	log := logger.NewLogger("Cgo_RouteSpecificFilterConfig_Create", nativeLogger{})
 
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(fmt.Sprintf("panic recover with error: %v", err))
+			setConfigError(errMsg, fmt.Sprintf("panic: %v", err))
+			result = 0
+		}
+	}()
+
 	factoryFactory := ego.GetHttpFilterFactoryFactory(CStrN(name, nameLen))
 	if factoryFactory == nil {
+		setConfigError(errMsg, "unknown filter")
 		return 0
 	}
 
@@ -143,21 +166,16 @@ func Cgo_RouteSpecificFilterConfig_Create(configSlot uint64, name *C.char, nameL
 
 	config, err := factoryFactory.CreateRouteSpecificFilterConfig(cfg)
 	if err != nil {
-		log.Error(fmt.Sprintf("invoke CreateRouteSpecificFilterConfig failed with error", err))
+		log.Error(fmt.Sprintf("invoke CreateRouteSpecificFilterConfig failed with error: %v", err))
+		setConfigError(errMsg, err.Error())
 		return 0
 	}
 	if nil == config {
 		log.Error("invoke CreateRouteSpecificFilterConfig without error but return nil")
+		setConfigError(errMsg, "nil route specific filter config")
 		return 0
 	}
 
-	defer func() {
-		if err := recover(); err != nil {
-			log.Error(fmt.Sprintf("panic recover with error", err))
-			result = 0
-		}
-	}()
-
 	return TagRouteSpecificFilterConfig(configSlot, config)
 }
 
@@ -166,7 +184,7 @@ func Cgo_RouteSpecificFilterConfig_dtor(configTag uint64) {
 	log := logger.NewLogger("Cgo_RouteSpecificFilterConfig_dtor", nativeLogger{})
 	defer func() {
 		if err := recover(); err != nil {
-			log.Error(fmt.Sprintf("panic recover with error", err))
+			log.Error(fmt.Sprintf("panic recover with error: %v", err))
 		}
 	}()
 	factory := RemoveRouteSpecificFilterConfig(configTag)
@@ -0,0 +1,72 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+// #include "ego/src/cc/goc/envoy.h"
+import "C"
+import (
+	"strings"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/volatile"
+)
+
+// headerEntries is the batch counterpart of Envoy::Http::HeaderMap::iterate().
+// Instead of calling back into Go for every header, all keys and values are
+// fetched with a single downcall. They refer to the memory of the header map,
+// so cb must not modify the header map while iterating.
+//
+func headerEntries(size C.uint64_t, entries func(max C.uint64_t, keys, values *C.GoStr) C.uint64_t,
+	cb func(key, value volatile.String) bool) {
+
+	if 0 == size {
+		return
+	}
+
+	keys := make([]C.GoStr, size)
+	values := make([]C.GoStr, size)
+	count := int(entries(size, &keys[0], &values[0]))
+	for i := 0; i < count; i++ {
+		if !cb(CStrN(keys[i].data, keys[i].len), CStrN(values[i].data, values[i].len)) {
+			return
+		}
+	}
+}
+
+// headerValues collects all values of a single header. size is an upper bound
+// for the number of values, which saves us a downcall to count them.
+//
+func headerValues(size C.uint64_t, getAll func(max C.uint64_t, values *C.GoStr) C.uint64_t) []volatile.String {
+	if 0 == size {
+		return nil
+	}
+
+	temp := make([]C.GoStr, size)
+	count := int(getAll(size, &temp[0]))
+	if 0 == count {
+		return nil
+	}
+
+	result := make([]volatile.String, count)
+	for i := 0; i < count; i++ {
+		result[i] = CStrN(temp[i].data, temp[i].len)
+	}
+	return result
+}
+
+// headersByPrefix collects copies of all headers starting with prefix
+//
+func headersByPrefix(h envoy.HeaderMapReadOnly, prefix string) map[string][]string {
+	result := make(map[string][]string)
+	h.Iterate(func(key, value volatile.String) bool {
+		if strings.HasPrefix(string(key), prefix) {
+			k := key.Copy()
+			result[k] = append(result[k], value.Copy())
+		}
+		return true
+	})
+	return result
+}
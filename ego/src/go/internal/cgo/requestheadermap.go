@@ -10,9 +10,6 @@ import "C"
 import (
 	"unsafe"
 
-	"github.com/golang/protobuf/proto"
-
-	pb "github.com/grab/ego/ego/src/cc/goc/proto"
 	"github.com/grab/ego/ego/src/go/volatile"
 )
 
@@ -44,32 +41,9 @@ func (h requestHeaderMap) AppendCopy(name, value string) {
 	C.RequestHeaderMap_append(h.ptr, GoStr(name), GoStr(value))
 }
 
+// GetByPrefix is a Go convenience function.
 func (h requestHeaderMap) GetByPrefix(prefix string) map[string][]string {
-	defaultBufferSize := uint64(100)
-	data := make([]byte, defaultBufferSize)
-	size := uint64(C.RequestHeaderMap_getByPrefix(h.ptr, GoStr(prefix), GoBuf(data)))
-	if size > defaultBufferSize {
-		newBufferSize := size
-		data = make([]byte, newBufferSize)
-		size = uint64(C.RequestHeaderMap_getByPrefix(h.ptr, GoStr(prefix), GoBuf(data)))
-		if size > newBufferSize {
-			// it's not supposed to happen.
-			panic("Invalid logic")
-		}
-	}
-	headers := pb.RequestHeaderMap{}
-	if err := proto.Unmarshal(data[:size], &headers); err != nil {
-		// TODO: handle error
-	}
-
-	result := make(map[string][]string)
-	for _, h := range headers.Headers {
-		if _, existing := result[h.Key]; !existing {
-			result[h.Key] = []string{h.Value}
-		}
-	}
-
-	return result
+	return headersByPrefix(h, prefix)
 }
 
 // Remove translates to
@@ -113,3 +87,39 @@ func (h requestHeaderMap) Get(name string) volatile.String {
 	C.RequestHeaderMap_get(h.ptr, GoStr(name), &value)
 	return CStrN(value.data, value.len)
 }
+
+// GetAll translates to
+// Envoy::Http::RequestHeaderMap::get(LowerCaseString) for every matching entry.
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestHeaderMap) GetAll(name string) []volatile.String {
+	return headerValues(C.RequestHeaderMap_size(h.ptr), func(max C.uint64_t, values *C.GoStr) C.uint64_t {
+		return C.RequestHeaderMap_getAll(h.ptr, GoStr(name), max, values)
+	})
+}
+
+// Iterate translates to
+// Envoy::Http::RequestHeaderMap::iterate(ConstIterateCb, void*).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestHeaderMap) Iterate(cb func(key, value volatile.String) bool) {
+	headerEntries(C.RequestHeaderMap_size(h.ptr), func(max C.uint64_t, keys, values *C.GoStr) C.uint64_t {
+		return C.RequestHeaderMap_entries(h.ptr, max, keys, values)
+	}, cb)
+}
+
+// Size translates to
+// Envoy::Http::RequestHeaderMap::size().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestHeaderMap) Size() uint64 {
+	return uint64(C.RequestHeaderMap_size(h.ptr))
+}
+
+// ByteSize translates to
+// Envoy::Http::RequestHeaderMap::byteSize().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestHeaderMap) ByteSize() uint64 {
+	return uint64(C.RequestHeaderMap_byteSize(h.ptr))
+}
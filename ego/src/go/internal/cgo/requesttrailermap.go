@@ -37,12 +37,53 @@ func (h requestTrailerMap) Remove(name string) {
 	panic("Not implemented yet")
 }
 
+// Get translates to
+// Envoy::Http::RequestTrailerMap::Get(LowerCaseString).
+//
+// See //envoy/include/envoy/http/header_map.h
 func (h requestTrailerMap) Get(name string) volatile.String {
-	panic("Not implemented yet")
-	return volatile.String("")
+	var value C.GoStr
+	C.RequestTrailerMap_get(h.ptr, GoStr(name), &value)
+	return CStrN(value.data, value.len)
 }
 
+// GetByPrefix is a Go convenience function.
 func (h requestTrailerMap) GetByPrefix(prefix string) map[string][]string {
-	panic("Not implemented yet")
-	return map[string][]string{}
+	return headersByPrefix(h, prefix)
+}
+
+// GetAll translates to
+// Envoy::Http::RequestTrailerMap::get(LowerCaseString) for every matching entry.
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestTrailerMap) GetAll(name string) []volatile.String {
+	return headerValues(C.RequestTrailerMap_size(h.ptr), func(max C.uint64_t, values *C.GoStr) C.uint64_t {
+		return C.RequestTrailerMap_getAll(h.ptr, GoStr(name), max, values)
+	})
+}
+
+// Iterate translates to
+// Envoy::Http::RequestTrailerMap::iterate(ConstIterateCb, void*).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestTrailerMap) Iterate(cb func(key, value volatile.String) bool) {
+	headerEntries(C.RequestTrailerMap_size(h.ptr), func(max C.uint64_t, keys, values *C.GoStr) C.uint64_t {
+		return C.RequestTrailerMap_entries(h.ptr, max, keys, values)
+	}, cb)
+}
+
+// Size translates to
+// Envoy::Http::RequestTrailerMap::size().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestTrailerMap) Size() uint64 {
+	return uint64(C.RequestTrailerMap_size(h.ptr))
+}
+
+// ByteSize translates to
+// Envoy::Http::RequestTrailerMap::byteSize().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h requestTrailerMap) ByteSize() uint64 {
+	return uint64(C.RequestTrailerMap_byteSize(h.ptr))
 }
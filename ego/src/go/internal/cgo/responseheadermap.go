@@ -87,3 +87,39 @@ func (h responseHeaderMap) Get(name string) volatile.String {
 	C.ResponseHeaderMap_get(h.ptr, GoStr(name), &value)
 	return CStrN(value.data, value.len)
 }
+
+// GetAll translates to
+// Envoy::Http::ResponseHeaderMap::get(LowerCaseString) for every matching entry.
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseHeaderMap) GetAll(name string) []volatile.String {
+	return headerValues(C.ResponseHeaderMap_size(h.ptr), func(max C.uint64_t, values *C.GoStr) C.uint64_t {
+		return C.ResponseHeaderMap_getAll(h.ptr, GoStr(name), max, values)
+	})
+}
+
+// Iterate translates to
+// Envoy::Http::ResponseHeaderMap::iterate(ConstIterateCb, void*).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseHeaderMap) Iterate(cb func(key, value volatile.String) bool) {
+	headerEntries(C.ResponseHeaderMap_size(h.ptr), func(max C.uint64_t, keys, values *C.GoStr) C.uint64_t {
+		return C.ResponseHeaderMap_entries(h.ptr, max, keys, values)
+	}, cb)
+}
+
+// Size translates to
+// Envoy::Http::ResponseHeaderMap::size().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseHeaderMap) Size() uint64 {
+	return uint64(C.ResponseHeaderMap_size(h.ptr))
+}
+
+// ByteSize translates to
+// Envoy::Http::ResponseHeaderMap::byteSize().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseHeaderMap) ByteSize() uint64 {
+	return uint64(C.ResponseHeaderMap_byteSize(h.ptr))
+}
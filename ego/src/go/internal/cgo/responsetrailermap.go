@@ -0,0 +1,96 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+// #include "ego/src/cc/goc/envoy.h"
+import "C"
+import (
+	"unsafe"
+
+	"github.com/grab/ego/ego/src/go/volatile"
+)
+
+// responseTrailerMap implements envoy.ResponseTrailerMap
+//
+type responseTrailerMap struct{ ptr unsafe.Pointer }
+
+// AddCopy translates to
+// Envoy::Http::ResponseTrailerMap::addCopy(LowerCaseString, absl::string_view).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) AddCopy(name, value string) {
+	C.ResponseTrailerMap_add(h.ptr, GoStr(name), GoStr(value))
+}
+
+// SetCopy translates to
+// Envoy::Http::ResponseTrailerMap::setCopy(LowerCaseString, absl::string_view).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) SetCopy(name, value string) {
+	C.ResponseTrailerMap_set(h.ptr, GoStr(name), GoStr(value))
+}
+
+// AppendCopy translates to
+// Envoy::Http::ResponseTrailerMap::appendCopy(LowerCaseString, absl::string_view).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) AppendCopy(name, value string) {
+	C.ResponseTrailerMap_append(h.ptr, GoStr(name), GoStr(value))
+}
+
+// Remove translates to
+// Envoy::Http::ResponseTrailerMap::remove(LowerCaseString).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) Remove(name string) {
+	C.ResponseTrailerMap_remove(h.ptr, GoStr(name))
+}
+
+// Get translates to
+// Envoy::Http::ResponseTrailerMap::Get(LowerCaseString).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) Get(name string) volatile.String {
+	var value C.GoStr
+	C.ResponseTrailerMap_get(h.ptr, GoStr(name), &value)
+	return CStrN(value.data, value.len)
+}
+
+// GetAll translates to
+// Envoy::Http::ResponseTrailerMap::get(LowerCaseString) for every matching entry.
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) GetAll(name string) []volatile.String {
+	return headerValues(C.ResponseTrailerMap_size(h.ptr), func(max C.uint64_t, values *C.GoStr) C.uint64_t {
+		return C.ResponseTrailerMap_getAll(h.ptr, GoStr(name), max, values)
+	})
+}
+
+// Iterate translates to
+// Envoy::Http::ResponseTrailerMap::iterate(ConstIterateCb, void*).
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) Iterate(cb func(key, value volatile.String) bool) {
+	headerEntries(C.ResponseTrailerMap_size(h.ptr), func(max C.uint64_t, keys, values *C.GoStr) C.uint64_t {
+		return C.ResponseTrailerMap_entries(h.ptr, max, keys, values)
+	}, cb)
+}
+
+// Size translates to
+// Envoy::Http::ResponseTrailerMap::size().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) Size() uint64 {
+	return uint64(C.ResponseTrailerMap_size(h.ptr))
+}
+
+// ByteSize translates to
+// Envoy::Http::ResponseTrailerMap::byteSize().
+//
+// See //envoy/include/envoy/http/header_map.h
+func (h responseTrailerMap) ByteSize() uint64 {
+	return uint64(C.ResponseTrailerMap_byteSize(h.ptr))
+}
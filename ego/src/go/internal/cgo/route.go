@@ -11,7 +11,11 @@ import (
 	"fmt"
 	"unsafe"
 
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+
 	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/envoy/loglevel"
 	"github.com/grab/ego/ego/src/go/volatile"
 )
 
@@ -33,13 +37,66 @@ func (e *routeEntry) PathMatchCriterion() envoy.PathMatchCriterion {
 	return &pathMatchCriterion{e.filter, e.encoder}
 }
 
+func (e *routeEntry) ClusterName() (volatile.String, error) {
+	var value C.GoStr
+	errCode := C.GoHttpFilter_StreamFilterCallbacks_route_routeEntry_clusterName(e.filter, GoBool(e.encoder), &value)
+	if errCode != 0 {
+		return volatile.String(""), fmt.Errorf("can't get cluster name. error code %d", errCode)
+	}
+
+	return CStrN(value.data, value.len), nil
+}
+
+func (e *routeEntry) RouteName() (volatile.String, error) {
+	var value C.GoStr
+	errCode := C.GoHttpFilter_StreamFilterCallbacks_route_routeEntry_routeName(e.filter, GoBool(e.encoder), &value)
+	if errCode != 0 {
+		return volatile.String(""), fmt.Errorf("can't get route name. error code %d", errCode)
+	}
+
+	return CStrN(value.data, value.len), nil
+}
+
+func (e *routeEntry) VirtualHostName() (volatile.String, error) {
+	var value C.GoStr
+	errCode := C.GoHttpFilter_StreamFilterCallbacks_route_routeEntry_virtualHost_name(e.filter, GoBool(e.encoder), &value)
+	if errCode != 0 {
+		return volatile.String(""), fmt.Errorf("can't get virtual host name. error code %d", errCode)
+	}
+
+	return CStrN(value.data, value.len), nil
+}
+
+func (e *routeEntry) Metadata(namespace string) (*structpb.Struct, bool) {
+	data, ok := readDto(func(buf []byte) int64 {
+		return CLong(C.GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(e.filter, GoBool(e.encoder), GoStr(namespace), GoBuf(buf)))
+	})
+	if !ok {
+		return nil, false
+	}
+
+	// empty namespaces are empty structs
+	value := &structpb.Struct{}
+	if err := proto.Unmarshal(data, value); err != nil {
+		Log(loglevel.Error, "routeEntry", "can't unmarshal metadata. "+err.Error())
+		return nil, false
+	}
+	return value, true
+}
+
 type pathMatchCriterion struct {
 	filter  unsafe.Pointer
 	encoder bool
 }
 
 func (c *pathMatchCriterion) MatchType() (envoy.PathMatchType, error) {
-	matchType := C.GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matchType(c.filter, GoBool(c.encoder))
+	return pathMatchType(int(C.GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matchType(c.filter, GoBool(c.encoder))))
+}
+
+// pathMatchType translates the match types of the downcall, negative on
+// errors.
+//
+func pathMatchType(matchType int) (envoy.PathMatchType, error) {
 	switch matchType {
 	case 0:
 		return envoy.PathMatchNone, nil
@@ -0,0 +1,33 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+)
+
+func TestPathMatchType(t *testing.T) {
+	for matchType, expected := range map[int]envoy.PathMatchType{
+		0: envoy.PathMatchNone,
+		1: envoy.PathMatchPrefix,
+		2: envoy.PathMatchExact,
+		3: envoy.PathMatchRegex,
+	} {
+		actual, err := pathMatchType(matchType)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	// no route entry
+	_, err := pathMatchType(-1)
+	assert.NotNil(t, err)
+	_, err = pathMatchType(4)
+	assert.NotNil(t, err)
+}
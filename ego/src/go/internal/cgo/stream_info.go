@@ -8,9 +8,13 @@ package main
 // #include "ego/src/cc/goc/envoy.h"
 import "C"
 import (
+	"time"
 	"unsafe"
 
+	"github.com/golang/protobuf/proto"
+	pb "github.com/grab/ego/ego/src/cc/goc/proto"
 	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/envoy/loglevel"
 	"github.com/grab/ego/ego/src/go/volatile"
 )
 
@@ -39,8 +43,103 @@ func (i streamInfo) ResponseCode() int {
 	return int(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_responseCode(i.filter, GoBool(i.encoder)))
 }
 
+func (i streamInfo) DynamicMetadata() envoy.DynamicMetadata {
+	return dynamicMetadata{i.filter, i.encoder}
+}
+
 func (i streamInfo) ResponseCodeDetails() volatile.String {
 	var value C.GoStr
 	C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_responseCodeDetails(i.filter, GoBool(i.encoder), &value)
 	return CStrN(value.data, value.len)
 }
+
+func (i streamInfo) Protocol() (string, bool) {
+	var value C.GoStr
+	if 0 == C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_protocol(i.filter, GoBool(i.encoder), &value) {
+		return "", false
+	}
+	// protocol strings are static, no need to copy
+	return string(CStrN(value.data, value.len)), true
+}
+
+func (i streamInfo) DownstreamRemoteAddress() volatile.String {
+	var value C.GoStr
+	C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamRemoteAddress(i.filter, GoBool(i.encoder), &value)
+	return CStrN(value.data, value.len)
+}
+
+func (i streamInfo) DownstreamLocalAddress() volatile.String {
+	var value C.GoStr
+	C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamLocalAddress(i.filter, GoBool(i.encoder), &value)
+	return CStrN(value.data, value.len)
+}
+
+func (i streamInfo) UpstreamHost() volatile.String {
+	var value C.GoStr
+	C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_upstreamHost(i.filter, GoBool(i.encoder), &value)
+	return CStrN(value.data, value.len)
+}
+
+func (i streamInfo) RouteName() volatile.String {
+	var value C.GoStr
+	C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_routeName(i.filter, GoBool(i.encoder), &value)
+	return CStrN(value.data, value.len)
+}
+
+func (i streamInfo) StartTime() time.Time {
+	return time.Unix(0, CLong(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_startTime(i.filter, GoBool(i.encoder))))
+}
+
+// optionalDuration translates the -1 convention for absent durations.
+//
+func optionalDuration(nanoseconds int64) (time.Duration, bool) {
+	if nanoseconds < 0 {
+		return 0, false
+	}
+	return time.Duration(nanoseconds), true
+}
+
+func (i streamInfo) LastDownstreamRxByteReceived() (time.Duration, bool) {
+	return optionalDuration(CLong(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_lastDownstreamRxByteReceived(i.filter, GoBool(i.encoder))))
+}
+
+func (i streamInfo) FirstUpstreamRxByteReceived() (time.Duration, bool) {
+	return optionalDuration(CLong(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_firstUpstreamRxByteReceived(i.filter, GoBool(i.encoder))))
+}
+
+func (i streamInfo) FirstDownstreamTxByteSent() (time.Duration, bool) {
+	return optionalDuration(CLong(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_firstDownstreamTxByteSent(i.filter, GoBool(i.encoder))))
+}
+
+func (i streamInfo) RequestComplete() (time.Duration, bool) {
+	return optionalDuration(CLong(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_requestComplete(i.filter, GoBool(i.encoder))))
+}
+
+func (i streamInfo) DownstreamSslConnection() *envoy.SslConnectionInfo {
+	data, ok := readDto(func(buf []byte) int64 {
+		return CLong(C.GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamSslConnection(i.filter, GoBool(i.encoder), GoBuf(buf)))
+	})
+	if !ok {
+		return nil
+	}
+
+	info, err := unmarshalSslConnectionInfo(data)
+	if err != nil {
+		Log(loglevel.Error, "streamInfo", "can't unmarshal ssl connection info. "+err.Error())
+		return nil
+	}
+	return info
+}
+
+func unmarshalSslConnectionInfo(data []byte) (*envoy.SslConnectionInfo, error) {
+	info := pb.SslConnectionInfo{}
+	if err := proto.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &envoy.SslConnectionInfo{
+		PeerCertificatePresented: info.PeerCertificatePresented,
+		UriSanPeerCertificate:    info.UriSanPeerCertificate,
+		DnsSansPeerCertificate:   info.DnsSansPeerCertificate,
+		SubjectPeerCertificate:   info.SubjectPeerCertificate,
+	}, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/grab/ego/ego/src/cc/goc/proto"
+	"github.com/grab/ego/ego/src/go/envoy"
+)
+
+func TestOptionalDuration(t *testing.T) {
+	d, ok := optionalDuration(1500000)
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Microsecond, d)
+
+	d, ok = optionalDuration(0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), d)
+
+	_, ok = optionalDuration(-1)
+	assert.False(t, ok)
+}
+
+func TestUnmarshalSslConnectionInfo(t *testing.T) {
+	data, err := proto.Marshal(&pb.SslConnectionInfo{
+		PeerCertificatePresented: true,
+		UriSanPeerCertificate:    []string{"spiffe://mesh.example.com/ns/prod/sa/billing"},
+		DnsSansPeerCertificate:   []string{"billing.example.com", "billing"},
+		SubjectPeerCertificate:   "CN=billing,O=Example",
+	})
+	require.Nil(t, err)
+
+	info, err := unmarshalSslConnectionInfo(data)
+	require.Nil(t, err)
+	assert.Equal(t, &envoy.SslConnectionInfo{
+		PeerCertificatePresented: true,
+		UriSanPeerCertificate:    []string{"spiffe://mesh.example.com/ns/prod/sa/billing"},
+		DnsSansPeerCertificate:   []string{"billing.example.com", "billing"},
+		SubjectPeerCertificate:   "CN=billing,O=Example",
+	}, info)
+
+	// a TLS connection without client certificate serializes to nothing
+	info, err = unmarshalSslConnectionInfo([]byte{})
+	require.Nil(t, err)
+	assert.Equal(t, &envoy.SslConnectionInfo{}, info)
+
+	_, err = unmarshalSslConnectionInfo([]byte{0xff})
+	assert.NotNil(t, err)
+}
@@ -3,19 +3,57 @@
 // Use of this source code is governed by the Apache License 2.0 that can be
 // found in the LICENSE file
 
+#include "common/http/message_impl.h"
+
 #include "test/mocks/http/mocks.h"
+#include "test/mocks/ssl/mocks.h"
+#include "test/mocks/upstream/mocks.h"
 #include "test/test_common/utility.h"
 
 #include "ego/src/cc/filter/http/filter.h"
+#include "ego/src/cc/goc/envoy.h"
+#include "ego/src/cc/goc/proto/dto.pb.h"
 #include "mocks.h"
 
 using testing::_;
+using testing::DoAll;
+using testing::Invoke;
 using testing::NiceMock;
 using testing::Return;
+using testing::ReturnRef;
 
 namespace Envoy {
 namespace Http {
 
+// a valid configuration of the security filter
+const std::string security_config_yaml = R"EOF(
+    filter: security
+    settings:
+      "@type": type.googleapis.com/ego.security.Settings
+      providers:
+        hmac:
+          custom_hmac_provider:
+            request_validation_url: "http://localhost/verify"
+            service_key: key
+            service_token: token
+    )EOF";
+
+TEST(GoHttpFilterConfigTest, InvalidSettings) {
+  auto settings = TestUtility::parseYaml<ego::http::Settings>("filter: security");
+  Stats::ScopeSharedPtr scope = std::make_shared<Stats::IsolatedStoreImpl>();
+
+  EXPECT_THROW_WITH_REGEX(GoHttpFilterConfig(settings, scope), EnvoyException,
+                          "\\[ego_http\\]\\[security\\] invalid filter config: .*Providers");
+}
+
+TEST(GoHttpFilterConfigTest, UnknownFilter) {
+  auto settings = TestUtility::parseYaml<ego::http::Settings>("filter: unknown");
+  Stats::ScopeSharedPtr scope = std::make_shared<Stats::IsolatedStoreImpl>();
+
+  EXPECT_THROW_WITH_MESSAGE(GoHttpFilterConfig(settings, scope), EnvoyException,
+                            "[ego_http][unknown] invalid filter config: unknown filter");
+}
+
 class GoHttpRouteSpecificFilterConfigTest : public testing::Test {
 public:
   void initializeConfig(const std::string& yaml) {
@@ -52,6 +90,21 @@ TEST_F(GoHttpRouteSpecificFilterConfigTest, MultipleFilterConfigurations) {
   EXPECT_NE(cgoTagGetHeader, cgoTagSecurity);
 }
 
+TEST_F(GoHttpRouteSpecificFilterConfigTest, UnknownFilter) {
+  const std::string config_yaml = R"EOF(
+    filters:
+      security:
+        "@type": type.googleapis.com/ego.security.Requirement
+        provider_name: hmac
+      unknown:
+        "@type": type.googleapis.com/ego.security.Requirement
+    )EOF";
+
+  EXPECT_THROW_WITH_MESSAGE(
+      initializeConfig(config_yaml), EnvoyException,
+      "[ego_http][unknown] invalid route specific filter config: unknown filter");
+}
+
 TEST_F(GoHttpRouteSpecificFilterConfigTest, EmptyConfiguration) {
   const std::string config_yaml = R"EOF(
     filters:
@@ -73,7 +126,8 @@ public:
     auto api = Envoy::Api::createApiForTest();
     auto cgo_proxy = std::make_shared<CgoProxyImpl>();
 
-    filter_ = new GoHttpFilter(config, *api, nullptr, cgo_proxy, std::make_unique<SpanGroup>());
+    filter_ = new GoHttpFilter(config, *api, cluster_manager_, nullptr, cgo_proxy,
+                               std::make_unique<SpanGroup>());
     stream_filter_ = filter_->ref();
 
     filter_->setDecoderFilterCallbacks(decoder_callbacks_);
@@ -92,14 +146,12 @@ public:
   Envoy::Http::StreamFilterSharedPtr stream_filter_;
   NiceMock<Envoy::Http::MockStreamDecoderFilterCallbacks> decoder_callbacks_;
   NiceMock<Envoy::Http::MockStreamEncoderFilterCallbacks> encoder_callbacks_;
+  NiceMock<Upstream::MockClusterManager> cluster_manager_;
   Envoy::Stats::ScopeSharedPtr stats_scope_;
 };
 
 TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, ValidConfig) {
-  const std::string config_yaml = R"EOF(
-    filter: security
-    )EOF";
-  initializeFilter(config_yaml);
+  initializeFilter(security_config_yaml);
 
   const std::string route_specific_config_yaml = R"EOF(
     filters:
@@ -118,10 +170,7 @@ TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, ValidConfig) {
 }
 
 TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NotExistingConfig) {
-  const std::string config_yaml = R"EOF(
-    filter: security
-    )EOF";
-  initializeFilter(config_yaml);
+  initializeFilter(security_config_yaml);
 
   const std::string route_specific_config_yaml = R"EOF(
     filters:
@@ -143,10 +192,7 @@ TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NotExistingConfi
 }
 
 TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NullRouteSpecificConfig) {
-  const std::string config_yaml = R"EOF(
-    filter: security
-    )EOF";
-  initializeFilter(config_yaml);
+  initializeFilter(security_config_yaml);
 
   EXPECT_CALL(*decoder_callbacks_.route_, perFilterConfig(GoHttpConstants::get().FilterName))
       .WillOnce(Return(nullptr));
@@ -158,10 +204,7 @@ TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NullRouteSpecifi
 }
 
 TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NullRoute) {
-  const std::string config_yaml = R"EOF(
-    filter: security
-    )EOF";
-  initializeFilter(config_yaml);
+  initializeFilter(security_config_yaml);
 
   EXPECT_CALL(decoder_callbacks_, route).WillOnce(Return(nullptr));
 
@@ -172,10 +215,7 @@ TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NullRoute) {
 }
 
 TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NullRouteEntry) {
-  const std::string config_yaml = R"EOF(
-    filter: security
-    )EOF";
-  initializeFilter(config_yaml);
+  initializeFilter(security_config_yaml);
 
   EXPECT_CALL(*decoder_callbacks_.route_, routeEntry).WillOnce(Return(nullptr));
 
@@ -187,19 +227,18 @@ TEST_F(GoHttpFilterResolveMostSpecificPerGoFilterConfigTagTest, NullRouteEntry)
 
 class GoHttpFilterTest : public testing::Test {
 public:
-  void initializeFilter() {
-    const std::string config_yaml = R"EOF(
-        filter: security
-      )EOF";
-    auto settings = TestUtility::parseYaml<ego::http::Settings>(config_yaml);
+  void initializeFilter(bool crash_on_errors = false, uint64_t cgo_tag = 100) {
+    auto settings = TestUtility::parseYaml<ego::http::Settings>(security_config_yaml);
+    settings.set_crash_on_errors(crash_on_errors);
     stats_scope_ = std::make_shared<Stats::IsolatedStoreImpl>();
     auto config = std::make_shared<GoHttpFilterConfig>(settings, stats_scope_);
     auto api = Envoy::Api::createApiForTest();
 
     cgo_proxy_ = std::make_shared<NiceMock<MockCgoProxy>>();
-    EXPECT_CALL(*cgo_proxy_, GoHttpFilterCreate).WillOnce(Return(100));
+    EXPECT_CALL(*cgo_proxy_, GoHttpFilterCreate).WillOnce(Return(cgo_tag));
 
-    filter_ = new GoHttpFilter(config, *api, nullptr, cgo_proxy_, std::make_unique<SpanGroup>());
+    filter_ = new GoHttpFilter(config, *api, cluster_manager_, nullptr, cgo_proxy_,
+                               std::make_unique<SpanGroup>());
     stream_filter_ = filter_->ref();
 
     filter_->setDecoderFilterCallbacks(decoder_callbacks_);
@@ -212,6 +251,7 @@ public:
   Envoy::Http::StreamFilterSharedPtr stream_filter_;
   NiceMock<Envoy::Http::MockStreamDecoderFilterCallbacks> decoder_callbacks_;
   NiceMock<Envoy::Http::MockStreamEncoderFilterCallbacks> encoder_callbacks_;
+  NiceMock<Upstream::MockClusterManager> cluster_manager_;
   std::shared_ptr<MockCgoProxy> cgo_proxy_;
   Envoy::Stats::ScopeSharedPtr stats_scope_;
 };
@@ -271,6 +311,17 @@ TEST_F(GoHttpFilterTest, EncodeData) {
   cleanUp();
 }
 
+TEST_F(GoHttpFilterTest, EncodeTrailers) {
+  initializeFilter();
+
+  Http::TestResponseTrailerMapImpl response_trailers;
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterEncodeTrailers(_, &response_trailers));
+
+  filter_->encodeTrailers(response_trailers);
+
+  cleanUp();
+}
+
 TEST_F(GoHttpFilterTest, StreamFilterCallbacksWithFalseEncoder) {
   initializeFilter();
 
@@ -307,5 +358,358 @@ TEST_F(GoHttpFilterTest, PostDownCallFlow) {
   cleanUp();
 }
 
+TEST_F(GoHttpFilterTest, DecodeHeadersFailureSendsLocalReply) {
+  initializeFilter();
+
+  Http::TestRequestHeaderMapImpl request_headers;
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterDecodeHeaders(_, &request_headers, false))
+      .WillOnce(Return(GoHttpFilter_FAILED));
+  EXPECT_CALL(decoder_callbacks_, sendLocalReply(Code::InternalServerError, _, _, _, _));
+  EXPECT_CALL(encoder_callbacks_, resetStream()).Times(0);
+
+  EXPECT_EQ(FilterHeadersStatus::StopIteration, filter_->decodeHeaders(request_headers, false));
+
+  // the local reply is encoded without calling Go
+  Http::TestResponseHeaderMapImpl response_headers;
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterEncodeHeaders).Times(0);
+  EXPECT_EQ(FilterHeadersStatus::Continue, filter_->encodeHeaders(response_headers, true));
+
+  auto& store = dynamic_cast<Stats::IsolatedStoreImpl&>(*stats_scope_);
+  EXPECT_EQ(1, TestUtility::findCounter(store, "panic")->value());
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, EncodeDataFailureResetsStream) {
+  initializeFilter();
+
+  Http::TestResponseHeaderMapImpl response_headers;
+  filter_->encodeHeaders(response_headers, false);
+
+  Buffer::OwnedImpl data;
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterEncodeData(_, &data, false))
+      .WillOnce(Return(GoHttpFilter_FAILED));
+  EXPECT_CALL(decoder_callbacks_, sendLocalReply(_, _, _, _, _)).Times(0);
+  EXPECT_CALL(encoder_callbacks_, resetStream());
+
+  EXPECT_EQ(FilterDataStatus::StopIterationNoBuffer, filter_->encodeData(data, false));
+
+  auto& store = dynamic_cast<Stats::IsolatedStoreImpl&>(*stats_scope_);
+  EXPECT_EQ(1, TestUtility::findCounter(store, "panic")->value());
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, DecodeHeadersFailureCrashOnErrors) {
+  initializeFilter(true);
+
+  Http::TestRequestHeaderMapImpl request_headers;
+  ON_CALL(*cgo_proxy_, GoHttpFilterDecodeHeaders(_, &request_headers, false))
+      .WillByDefault(Return(GoHttpFilter_FAILED));
+
+  EXPECT_DEATH(filter_->decodeHeaders(request_headers, false), "decodeHeaders failed");
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, CreateFailureSendsLocalReplyOnDecodeHeaders) {
+  // nothing is sent before decoding starts
+  EXPECT_CALL(decoder_callbacks_, sendLocalReply(_, _, _, _, _)).Times(0);
+  initializeFilter(false, 0);
+  testing::Mock::VerifyAndClearExpectations(&decoder_callbacks_);
+
+  Http::TestRequestHeaderMapImpl request_headers;
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterDecodeHeaders).Times(0);
+  EXPECT_CALL(decoder_callbacks_, sendLocalReply(Code::InternalServerError, _, _, _, _));
+
+  EXPECT_EQ(FilterHeadersStatus::StopIteration, filter_->decodeHeaders(request_headers, false));
+
+  auto& store = dynamic_cast<Stats::IsolatedStoreImpl&>(*stats_scope_);
+  EXPECT_EQ(0, TestUtility::findCounter(store, "panic")->value());
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, OnPostFailureSendsLocalReply) {
+  initializeFilter();
+
+  auto post_tag = 1;
+  EXPECT_CALL(decoder_callbacks_.dispatcher_, post(_)).WillOnce([](std::function<void()> callback) {
+    callback();
+  });
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterOnPost(_, post_tag)).WillOnce(Return(GoHttpFilter_FAILED));
+  EXPECT_CALL(decoder_callbacks_, sendLocalReply(Code::InternalServerError, _, _, _, _));
+
+  filter_->pin();
+  filter_->post(post_tag);
+  filter_->unpin();
+
+  cleanUp();
+}
+
+GoBuf goBuf(char* data, size_t len) {
+  GoBuf buf;
+  buf.data = data;
+  buf.len = buf.cap = len;
+  return buf;
+}
+
+GoStr goStr(const std::string& str) {
+  GoStr value;
+  value.data = const_cast<char*>(str.data());
+  value.len = str.size();
+  return value;
+}
+
+std::string toString(const GoStr& str) { return std::string(str.data, str.len); }
+
+TEST_F(GoHttpFilterTest, RouteEntryNames) {
+  initializeFilter();
+
+  auto& route_entry = decoder_callbacks_.route_->route_entry_;
+  const std::string cluster_name = "auth";
+  const std::string route_name = "hmac";
+  const std::string virtual_host_name = "egodemo";
+  ON_CALL(route_entry, clusterName()).WillByDefault(ReturnRef(cluster_name));
+  ON_CALL(route_entry, routeName()).WillByDefault(ReturnRef(route_name));
+  ON_CALL(route_entry.virtual_host_, name()).WillByDefault(ReturnRef(virtual_host_name));
+
+  EXPECT_EQ(1, GoHttpFilter_StreamFilterCallbacks_routeExisting(filter_, 0));
+  GoStr value;
+  EXPECT_EQ(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_clusterName(filter_, 0, &value));
+  EXPECT_EQ("auth", toString(value));
+  EXPECT_EQ(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_routeName(filter_, 0, &value));
+  EXPECT_EQ("hmac", toString(value));
+  EXPECT_EQ(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_virtualHost_name(filter_, 0, &value));
+  EXPECT_EQ("egodemo", toString(value));
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, RouteEntryPathMatchCriterion) {
+  initializeFilter();
+
+  auto& path_match_criterion = decoder_callbacks_.route_->route_entry_.path_match_criterion_;
+  const std::string matcher = "/hmac";
+  ON_CALL(path_match_criterion, matchType()).WillByDefault(Return(Router::PathMatchType::Prefix));
+  ON_CALL(path_match_criterion, matcher()).WillByDefault(ReturnRef(matcher));
+
+  EXPECT_EQ(1, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matchType(filter_, 0));
+  GoStr value;
+  EXPECT_EQ(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matcher(filter_, 0, &value));
+  EXPECT_EQ("/hmac", toString(value));
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, RouteEntryMetadata) {
+  initializeFilter();
+
+  envoy::config::core::v3::Metadata metadata;
+  (*(*metadata.mutable_filter_metadata())["egodemo"].mutable_fields())["key"].set_string_value("value");
+  (*metadata.mutable_filter_metadata())["empty"];
+  ON_CALL(decoder_callbacks_.route_->route_entry_, metadata()).WillByDefault(ReturnRef(metadata));
+
+  // a buffer too small is left untouched, and the required size returned
+  char small[1] = {'x'};
+  auto size = GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(
+      filter_, 0, goStr("egodemo"), goBuf(small, sizeof(small)));
+  ASSERT_GT(size, static_cast<int64_t>(sizeof(small)));
+  EXPECT_EQ('x', small[0]);
+
+  std::vector<char> data(size);
+  EXPECT_EQ(size, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(
+                      filter_, 0, goStr("egodemo"), goBuf(data.data(), data.size())));
+  ProtobufWkt::Struct value;
+  ASSERT_TRUE(value.ParseFromArray(data.data(), data.size()));
+  EXPECT_EQ("value", value.fields().at("key").string_value());
+
+  // empty namespaces aren't missing ones
+  EXPECT_EQ(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(
+                   filter_, 0, goStr("empty"), goBuf(small, sizeof(small))));
+  EXPECT_EQ(-1, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(
+                    filter_, 0, goStr("missing"), goBuf(small, sizeof(small))));
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, RouteEntryWithoutRoute) {
+  initializeFilter();
+
+  ON_CALL(encoder_callbacks_, route()).WillByDefault(Return(nullptr));
+
+  EXPECT_EQ(0, GoHttpFilter_StreamFilterCallbacks_routeExisting(filter_, 1));
+  GoStr value;
+  EXPECT_NE(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_clusterName(filter_, 1, &value));
+  EXPECT_NE(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_routeName(filter_, 1, &value));
+  EXPECT_NE(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_virtualHost_name(filter_, 1, &value));
+  EXPECT_NE(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matcher(filter_, 1, &value));
+  EXPECT_GT(0, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_pathMatchCriterion_matchType(filter_, 1));
+  char data[100];
+  EXPECT_EQ(-1, GoHttpFilter_StreamFilterCallbacks_route_routeEntry_metadata(
+                    filter_, 1, goStr("egodemo"), goBuf(data, sizeof(data))));
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, StreamInfoDownstreamSslConnection) {
+  initializeFilter();
+
+  auto connection_info = std::make_shared<NiceMock<Ssl::MockConnectionInfo>>();
+  const std::vector<std::string> uri_sans{"spiffe://mesh.example.com/ns/prod/sa/billing"};
+  const std::vector<std::string> dns_sans{"billing.example.com", "billing"};
+  const std::string subject = "CN=billing,O=Example";
+  ON_CALL(*connection_info, peerCertificatePresented()).WillByDefault(Return(true));
+  ON_CALL(*connection_info, uriSanPeerCertificate()).WillByDefault(Return(uri_sans));
+  ON_CALL(*connection_info, dnsSansPeerCertificate()).WillByDefault(Return(dns_sans));
+  ON_CALL(*connection_info, subjectPeerCertificate()).WillByDefault(ReturnRef(subject));
+  ON_CALL(decoder_callbacks_.stream_info_, downstreamSslConnection())
+      .WillByDefault(Return(connection_info));
+
+  // a buffer too small is left untouched, and the required size returned
+  char small[1] = {'x'};
+  auto size = GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamSslConnection(
+      filter_, 0, goBuf(small, sizeof(small)));
+  ASSERT_GT(size, static_cast<int64_t>(sizeof(small)));
+  EXPECT_EQ('x', small[0]);
+
+  std::vector<char> data(size);
+  EXPECT_EQ(size, GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamSslConnection(
+                      filter_, 0, goBuf(data.data(), data.size())));
+
+  ego::http::SslConnectionInfo info;
+  ASSERT_TRUE(info.ParseFromArray(data.data(), data.size()));
+  EXPECT_TRUE(info.peer_certificate_presented());
+  ASSERT_EQ(1, info.uri_san_peer_certificate_size());
+  EXPECT_EQ("spiffe://mesh.example.com/ns/prod/sa/billing", info.uri_san_peer_certificate(0));
+  ASSERT_EQ(2, info.dns_sans_peer_certificate_size());
+  EXPECT_EQ("billing.example.com", info.dns_sans_peer_certificate(0));
+  EXPECT_EQ("billing", info.dns_sans_peer_certificate(1));
+  EXPECT_EQ("CN=billing,O=Example", info.subject_peer_certificate());
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, StreamInfoDownstreamSslConnectionWithoutTls) {
+  initializeFilter();
+
+  ON_CALL(encoder_callbacks_.stream_info_, downstreamSslConnection()).WillByDefault(Return(nullptr));
+
+  char data[100];
+  EXPECT_EQ(-1, GoHttpFilter_StreamFilterCallbacks_StreamInfo_downstreamSslConnection(
+                    filter_, 1, goBuf(data, sizeof(data))));
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, StreamInfoTimings) {
+  initializeFilter();
+
+  ON_CALL(decoder_callbacks_.stream_info_, startTime())
+      .WillByDefault(Return(SystemTime(std::chrono::seconds(1600000000))));
+  ON_CALL(decoder_callbacks_.stream_info_, lastDownstreamRxByteReceived())
+      .WillByDefault(Return(absl::optional<std::chrono::nanoseconds>(std::chrono::milliseconds(5))));
+  ON_CALL(decoder_callbacks_.stream_info_, requestComplete())
+      .WillByDefault(Return(absl::optional<std::chrono::nanoseconds>()));
+
+  EXPECT_EQ(1600000000000000000, GoHttpFilter_StreamFilterCallbacks_StreamInfo_startTime(filter_, 0));
+  EXPECT_EQ(5000000,
+            GoHttpFilter_StreamFilterCallbacks_StreamInfo_lastDownstreamRxByteReceived(filter_, 0));
+  // durations of events which haven't happened yet are -1
+  EXPECT_EQ(-1, GoHttpFilter_StreamFilterCallbacks_StreamInfo_requestComplete(filter_, 0));
+
+  cleanUp();
+}
+
+RequestMessagePtr createAsyncRequest() {
+  auto request = std::make_unique<RequestMessageImpl>(createHeaderMap<RequestHeaderMapImpl>(
+      {{Headers::get().Method, "GET"}, {Headers::get().Path, "/check"}, {Headers::get().Host, "auth"}}));
+  return request;
+}
+
+TEST_F(GoHttpFilterTest, AsyncSendSuccess) {
+  initializeFilter();
+
+  auto post_tag = 1;
+  ON_CALL(decoder_callbacks_.dispatcher_, post(_)).WillByDefault([](std::function<void()> callback) {
+    callback();
+  });
+  EXPECT_CALL(cluster_manager_, httpAsyncClientForCluster("auth"));
+  EXPECT_CALL(cluster_manager_.async_client_, send_(_, _, _))
+      .WillOnce(Invoke([](RequestMessagePtr& message, AsyncClient::Callbacks& callbacks,
+                          const AsyncClient::RequestOptions& options) -> AsyncClient::Request* {
+        EXPECT_EQ("/check", message->headers().Path()->value().getStringView());
+        EXPECT_EQ(std::chrono::milliseconds(500), options.timeout);
+        callbacks.onSuccess(std::make_unique<ResponseMessageImpl>(
+            createHeaderMap<ResponseHeaderMapImpl>({{Headers::get().Status, "200"}})));
+        return nullptr;
+      }));
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterOnPost(_, post_tag))
+      .WillOnce(DoAll(Invoke([this](unsigned long long, unsigned long long post_tag) {
+        ResponseMessage* response = nullptr;
+        EXPECT_EQ(nullptr, filter_->asyncResponse(post_tag, &response));
+        ASSERT_NE(nullptr, response);
+        EXPECT_EQ("200", response->headers().Status()->value().getStringView());
+      }), Return(0)));
+
+  filter_->pin();
+  EXPECT_EQ(nullptr, filter_->asyncSend(post_tag, "auth", createAsyncRequest(),
+                                        std::chrono::milliseconds(500)));
+  filter_->unpin();
+
+  // the outcome is released once Go has seen it
+  ResponseMessage* response = nullptr;
+  EXPECT_STREQ("unknown request", filter_->asyncResponse(post_tag, &response));
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, AsyncSendUnknownCluster) {
+  initializeFilter();
+
+  auto post_tag = 1;
+  ON_CALL(decoder_callbacks_.dispatcher_, post(_)).WillByDefault([](std::function<void()> callback) {
+    callback();
+  });
+  EXPECT_CALL(cluster_manager_, get(_)).WillOnce(Return(nullptr));
+  EXPECT_CALL(cluster_manager_.async_client_, send_(_, _, _)).Times(0);
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterOnPost(_, post_tag))
+      .WillOnce(DoAll(Invoke([this](unsigned long long, unsigned long long post_tag) {
+        ResponseMessage* response = nullptr;
+        EXPECT_STREQ("unknown cluster", filter_->asyncResponse(post_tag, &response));
+      }), Return(0)));
+
+  filter_->pin();
+  EXPECT_EQ(nullptr, filter_->asyncSend(post_tag, "unknown", createAsyncRequest(),
+                                        std::chrono::milliseconds(0)));
+  filter_->unpin();
+
+  cleanUp();
+}
+
+TEST_F(GoHttpFilterTest, AsyncSendCanceledOnDestroy) {
+  initializeFilter();
+
+  auto post_tag = 1;
+  ON_CALL(decoder_callbacks_.dispatcher_, post(_)).WillByDefault([](std::function<void()> callback) {
+    callback();
+  });
+  NiceMock<MockAsyncClientRequest> request(&cluster_manager_.async_client_);
+  EXPECT_CALL(cluster_manager_.async_client_, send_(_, _, _)).WillOnce(Return(&request));
+  EXPECT_CALL(request, cancel());
+  EXPECT_CALL(*cgo_proxy_, GoHttpFilterOnPost(_, post_tag))
+      .WillOnce(DoAll(Invoke([this](unsigned long long, unsigned long long post_tag) {
+        ResponseMessage* response = nullptr;
+        EXPECT_STREQ("canceled", filter_->asyncResponse(post_tag, &response));
+      }), Return(0)));
+
+  filter_->pin();
+  EXPECT_EQ(nullptr, filter_->asyncSend(post_tag, "auth", createAsyncRequest(),
+                                        std::chrono::milliseconds(0)));
+  filter_->unpin();
+
+  cleanUp();
+}
+
 } // namespace Http
 } // namespace Envoy
\ No newline at end of file
@@ -27,7 +27,9 @@ public:
               (unsigned long long filter_tag, void* headers, int end_stream), (override));
   MOCK_METHOD(long long, GoHttpFilterEncodeData,
               (unsigned long long filter_tag, void* headers, int end_stream), (override));
-  MOCK_METHOD(void, GoHttpFilterOnPost,
+  MOCK_METHOD(long long, GoHttpFilterEncodeTrailers,
+              (unsigned long long filter_tag, void* trailers), (override));
+  MOCK_METHOD(long long, GoHttpFilterOnPost,
               (unsigned long long filter_tag, unsigned long long post_tag), (override));
 };
 
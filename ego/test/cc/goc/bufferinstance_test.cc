@@ -0,0 +1,64 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+#include "common/buffer/buffer_impl.h"
+
+#include "test/test_common/utility.h"
+
+#include "ego/src/cc/goc/envoy.h"
+
+class BufferInstanceTest : public testing::Test {
+public:
+  GoBuf goBuf(char* data) {
+    GoBuf buf;
+    buf.data = data;
+    buf.len = buf.cap = strlen(data);
+    return buf;
+  }
+};
+
+TEST_F(BufferInstanceTest, AddAndPrepend) {
+  Envoy::Buffer::OwnedImpl buffer("body");
+
+  char c_tail[] = "-tail";
+  char c_head[] = "head-";
+  BufferInstance_add(&buffer, goBuf(c_tail));
+  BufferInstance_prepend(&buffer, goBuf(c_head));
+
+  EXPECT_EQ("head-body-tail", buffer.toString());
+}
+
+TEST_F(BufferInstanceTest, Drain) {
+  Envoy::Buffer::OwnedImpl buffer("head-body");
+
+  BufferInstance_drain(&buffer, 5);
+  EXPECT_EQ("body", buffer.toString());
+
+  // draining more than available empties the buffer
+  BufferInstance_drain(&buffer, 100);
+  EXPECT_EQ(0, buffer.length());
+}
+
+TEST_F(BufferInstanceTest, Move) {
+  Envoy::Buffer::OwnedImpl buffer("head-");
+  Envoy::Buffer::OwnedImpl rhs("body");
+
+  BufferInstance_move(&buffer, &rhs);
+  EXPECT_EQ("head-body", buffer.toString());
+  EXPECT_EQ(0, rhs.length());
+
+  // moving into itself is a no-op
+  BufferInstance_move(&buffer, &buffer);
+  EXPECT_EQ("head-body", buffer.toString());
+}
+
+TEST_F(BufferInstanceTest, Replace) {
+  Envoy::Buffer::OwnedImpl buffer("{\"secret\":\"s3cr3t\"}");
+
+  char c_body[] = "{\"secret\":\"***\"}";
+  BufferInstance_replace(&buffer, goBuf(c_body));
+
+  EXPECT_EQ("{\"secret\":\"***\"}", buffer.toString());
+}
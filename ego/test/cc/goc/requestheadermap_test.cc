@@ -7,92 +7,73 @@
 
 #include "ego/src/cc/filter/http/filter.h"
 #include "ego/src/cc/goc/envoy.h"
-#include "ego/src/cc/goc/proto/dto.pb.validate.h"
 
-class RequestHeaderMapGetByPrefixTest : public testing::Test {
+class RequestHeaderMapIterationTest : public testing::Test {
 public:
-  void init(char* prefix, char* buffer, size_t buffer_size) {
-    EXPECT_EQ(strlen(prefix), 5);
-    prefix_.len = strlen(prefix);
-    prefix_.data = prefix;
-
-    buffer_.data = buffer;
-    buffer_.len = buffer_size;
-    buffer_.cap = buffer_size;
+  static GoStr goStr(const char* data) {
+    GoStr str;
+    str.data = const_cast<char*>(data);
+    str.len = strlen(data);
+    return str;
   }
 
-  GoBuf buffer_;
-  GoStr prefix_;
+  static std::string toString(const GoStr& str) { return std::string(str.data, str.len); }
 };
 
-TEST_F(RequestHeaderMapGetByPrefixTest, FoundHeaders) {
-  Envoy::Http::TestRequestHeaderMapImpl request_headers{{"x-ego1", "val1"}, {"x-ego2", "val2"}};
-
-  char c_buffer[100];
-  char c_prefix[] = "x-ego";
-  init(c_prefix, c_buffer, 100);
-
-  auto size = RequestHeaderMap_getByPrefix(&request_headers, prefix_, buffer_);
-  EXPECT_LT(0, size);
+TEST_F(RequestHeaderMapIterationTest, Entries) {
+  Envoy::Http::TestRequestHeaderMapImpl request_headers{
+      {"x-ego1", "val1"}, {"x-ego2", "val2"}, {"x-ego1", "val3"}};
 
-  auto result = ego::http::RequestHeaderMap{};
-  auto ok = result.ParseFromArray(buffer_.data, size);
-  ASSERT_TRUE(ok);
+  EXPECT_EQ(3, RequestHeaderMap_size(&request_headers));
 
-  auto expected_result = ego::http::RequestHeaderMap{};
+  GoStr keys[3];
+  GoStr values[3];
+  auto count = RequestHeaderMap_entries(&request_headers, 3, keys, values);
+  ASSERT_EQ(3, count);
 
-  auto x_ego1_header = expected_result.add_headers();
-  x_ego1_header->set_key("x-ego1");
-  x_ego1_header->set_value("val1");
-
-  auto x_ego2_header = expected_result.add_headers();
-  x_ego2_header->set_key("x-ego2");
-  x_ego2_header->set_value("val2");
-
-  ASSERT_EQ(expected_result.SerializePartialAsString(), result.SerializePartialAsString());
+  EXPECT_EQ("x-ego1", toString(keys[0]));
+  EXPECT_EQ("val1", toString(values[0]));
+  EXPECT_EQ("x-ego2", toString(keys[1]));
+  EXPECT_EQ("val2", toString(values[1]));
+  EXPECT_EQ("x-ego1", toString(keys[2]));
+  EXPECT_EQ("val3", toString(values[2]));
 }
 
-TEST_F(RequestHeaderMapGetByPrefixTest, NotFoundHeaders) {
-  Envoy::Http::TestRequestHeaderMapImpl request_headers{};
+TEST_F(RequestHeaderMapIterationTest, EntriesShouldNotWriteOverMax) {
+  Envoy::Http::TestRequestHeaderMapImpl request_headers{{"x-ego1", "val1"}, {"x-ego2", "val2"}};
 
-  char c_buffer[100];
-  char c_prefix[] = "x-ego";
-  init(c_prefix, c_buffer, 100);
+  GoStr keys[2] = {goStr("untouched"), goStr("untouched")};
+  GoStr values[2] = {goStr("untouched"), goStr("untouched")};
+  auto count = RequestHeaderMap_entries(&request_headers, 1, keys, values);
+  ASSERT_EQ(1, count);
 
-  auto size = RequestHeaderMap_getByPrefix(&request_headers, prefix_, buffer_);
-  EXPECT_EQ(0, size);
+  EXPECT_EQ("x-ego1", toString(keys[0]));
+  EXPECT_EQ("untouched", toString(keys[1]));
+  EXPECT_EQ("untouched", toString(values[1]));
 }
 
-TEST_F(RequestHeaderMapGetByPrefixTest, ShouldNotWriteOverBufferSize) {
-  Envoy::Http::TestRequestHeaderMapImpl request_headers{{"x-ego1", "val1"}, {"x-ego2", "val2"}};
+TEST_F(RequestHeaderMapIterationTest, GetAll) {
+  Envoy::Http::TestRequestHeaderMapImpl request_headers{
+      {"x-ego1", "val1"}, {"x-ego2", "val2"}, {"x-ego1", "val3"}};
 
-  const auto buffer_size = 34;
-  char c_buffer[] = "0123456789012345678901234567890123should_not_be_overriden";
+  GoStr values[3];
+  auto count = RequestHeaderMap_getAll(&request_headers, goStr("X-Ego1"), 3, values);
+  ASSERT_EQ(2, count);
 
-  char c_prefix[] = "x-ego";
-  init(c_prefix, c_buffer, buffer_size);
+  EXPECT_EQ("val1", toString(values[0]));
+  EXPECT_EQ("val3", toString(values[1]));
+}
 
-  auto size = RequestHeaderMap_getByPrefix(&request_headers, prefix_, buffer_);
-  EXPECT_EQ(32, size);
+TEST_F(RequestHeaderMapIterationTest, GetAllNotFound) {
+  Envoy::Http::TestRequestHeaderMapImpl request_headers{{"x-ego1", "val1"}};
 
-  // getByPrefix should not write over the buffer size
-  char expected_buffer[] = "0123456789012345678901234567890123should_not_be_overriden";
-  ASSERT_STREQ(expected_buffer + buffer_size, c_buffer + buffer_size);
+  GoStr values[1];
+  auto count = RequestHeaderMap_getAll(&request_headers, goStr("x-ego2"), 1, values);
+  EXPECT_EQ(0, count);
 }
 
-TEST_F(RequestHeaderMapGetByPrefixTest, TooSmallBuffer) {
+TEST_F(RequestHeaderMapIterationTest, ByteSize) {
   Envoy::Http::TestRequestHeaderMapImpl request_headers{{"x-ego1", "val1"}, {"x-ego2", "val2"}};
 
-  const auto buffer_size = 10;
-  char c_buffer[] = "this is a buffer with existing data";
-
-  char c_prefix[] = "x-ego";
-  init(c_prefix, c_buffer, buffer_size);
-
-  auto size = RequestHeaderMap_getByPrefix(&request_headers, prefix_, buffer_);
-  EXPECT_GT(size, buffer_size);
-
-  // getByPrefix should not write to buffer size
-  char expected_buffer[] = "this is a buffer with existing data";
-  ASSERT_STREQ(expected_buffer, c_buffer);
-}
\ No newline at end of file
+  EXPECT_EQ(request_headers.byteSize(), RequestHeaderMap_byteSize(&request_headers));
+}
@@ -5,6 +5,7 @@ package mocks
 import (
 	io "io"
 
+	envoy "github.com/grab/ego/ego/src/go/envoy"
 	volatile "github.com/grab/ego/ego/src/go/volatile"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -14,6 +15,11 @@ type BufferInstance struct {
 	mock.Mock
 }
 
+// Add provides a mock function with given fields: data
+func (_m *BufferInstance) Add(data []byte) {
+	_m.Called(data)
+}
+
 // CopyOut provides a mock function with given fields: start, p
 func (_m *BufferInstance) CopyOut(start uint64, p []byte) int {
 	ret := _m.Called(start, p)
@@ -28,6 +34,11 @@ func (_m *BufferInstance) CopyOut(start uint64, p []byte) int {
 	return r0
 }
 
+// Drain provides a mock function with given fields: size
+func (_m *BufferInstance) Drain(size uint64) {
+	_m.Called(size)
+}
+
 // GetRawSlices provides a mock function with given fields:
 func (_m *BufferInstance) GetRawSlices() []volatile.Bytes {
 	ret := _m.Called()
@@ -58,6 +69,11 @@ func (_m *BufferInstance) Length() uint64 {
 	return r0
 }
 
+// Move provides a mock function with given fields: rhs
+func (_m *BufferInstance) Move(rhs envoy.BufferInstance) {
+	_m.Called(rhs)
+}
+
 // NewReader provides a mock function with given fields: start
 func (_m *BufferInstance) NewReader(start uint64) io.Reader {
 	ret := _m.Called(start)
@@ -73,3 +89,13 @@ func (_m *BufferInstance) NewReader(start uint64) io.Reader {
 
 	return r0
 }
+
+// Prepend provides a mock function with given fields: data
+func (_m *BufferInstance) Prepend(data []byte) {
+	_m.Called(data)
+}
+
+// Replace provides a mock function with given fields: data
+func (_m *BufferInstance) Replace(data []byte) {
+	_m.Called(data)
+}
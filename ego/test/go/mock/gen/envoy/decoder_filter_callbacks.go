@@ -35,6 +35,11 @@ func (_m *DecoderFilterCallbacks) AddDecodedData(buffer envoy.BufferInstance, st
 	_m.Called(buffer, streamingFilter)
 }
 
+// ClearRouteCache provides a mock function with given fields:
+func (_m *DecoderFilterCallbacks) ClearRouteCache() {
+	_m.Called()
+}
+
 // ContinueDecoding provides a mock function with given fields:
 func (_m *DecoderFilterCallbacks) ContinueDecoding() {
 	_m.Called()
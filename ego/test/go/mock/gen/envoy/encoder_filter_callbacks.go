@@ -33,6 +33,11 @@ func (_m *EncoderFilterCallbacks) AddEncodedData(buffer envoy.BufferInstance, st
 	_m.Called(buffer, streamingFilter)
 }
 
+// ClearRouteCache provides a mock function with given fields:
+func (_m *EncoderFilterCallbacks) ClearRouteCache() {
+	_m.Called()
+}
+
 // ContinueEncoding provides a mock function with given fields:
 func (_m *EncoderFilterCallbacks) ContinueEncoding() {
 	_m.Called()
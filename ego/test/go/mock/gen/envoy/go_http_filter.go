@@ -14,6 +14,22 @@ type GoHttpFilter struct {
 	mock.Mock
 }
 
+// AsyncClient provides a mock function with given fields:
+func (_m *GoHttpFilter) AsyncClient() envoy.AsyncClient {
+	ret := _m.Called()
+
+	var r0 envoy.AsyncClient
+	if rf, ok := ret.Get(0).(func() envoy.AsyncClient); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(envoy.AsyncClient)
+		}
+	}
+
+	return r0
+}
+
 // DecoderCallbacks provides a mock function with given fields:
 func (_m *GoHttpFilter) DecoderCallbacks() envoy.DecoderFilterCallbacks {
 	ret := _m.Called()
@@ -12,6 +12,20 @@ type HeaderMapReadOnly struct {
 	mock.Mock
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *HeaderMapReadOnly) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: name
 func (_m *HeaderMapReadOnly) Get(name string) volatile.String {
 	ret := _m.Called(name)
@@ -25,3 +39,38 @@ func (_m *HeaderMapReadOnly) Get(name string) volatile.String {
 
 	return r0
 }
+
+// GetAll provides a mock function with given fields: name
+func (_m *HeaderMapReadOnly) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
+// Iterate provides a mock function with given fields: cb
+func (_m *HeaderMapReadOnly) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
+// Size provides a mock function with given fields:
+func (_m *HeaderMapReadOnly) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
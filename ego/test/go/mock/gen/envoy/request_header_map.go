@@ -36,6 +36,20 @@ func (_m *RequestHeaderMap) Authorization() volatile.String {
 	return r0
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *RequestHeaderMap) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // ContentType provides a mock function with given fields:
 func (_m *RequestHeaderMap) ContentType() volatile.String {
 	ret := _m.Called()
@@ -64,6 +78,22 @@ func (_m *RequestHeaderMap) Get(name string) volatile.String {
 	return r0
 }
 
+// GetAll provides a mock function with given fields: name
+func (_m *RequestHeaderMap) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
 // GetByPrefix provides a mock function with given fields: prefix
 func (_m *RequestHeaderMap) GetByPrefix(prefix string) map[string][]string {
 	ret := _m.Called(prefix)
@@ -80,6 +110,11 @@ func (_m *RequestHeaderMap) GetByPrefix(prefix string) map[string][]string {
 	return r0
 }
 
+// Iterate provides a mock function with given fields: cb
+func (_m *RequestHeaderMap) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
 // Method provides a mock function with given fields:
 func (_m *RequestHeaderMap) Method() volatile.String {
 	ret := _m.Called()
@@ -122,3 +157,17 @@ func (_m *RequestHeaderMap) SetCopy(name string, value string) {
 func (_m *RequestHeaderMap) SetPath(path string) {
 	_m.Called(path)
 }
+
+// Size provides a mock function with given fields:
+func (_m *RequestHeaderMap) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
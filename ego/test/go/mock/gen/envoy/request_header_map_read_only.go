@@ -26,6 +26,20 @@ func (_m *RequestHeaderMapReadOnly) Authorization() volatile.String {
 	return r0
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *RequestHeaderMapReadOnly) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // ContentType provides a mock function with given fields:
 func (_m *RequestHeaderMapReadOnly) ContentType() volatile.String {
 	ret := _m.Called()
@@ -54,6 +68,22 @@ func (_m *RequestHeaderMapReadOnly) Get(name string) volatile.String {
 	return r0
 }
 
+// GetAll provides a mock function with given fields: name
+func (_m *RequestHeaderMapReadOnly) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
 // GetByPrefix provides a mock function with given fields: prefix
 func (_m *RequestHeaderMapReadOnly) GetByPrefix(prefix string) map[string][]string {
 	ret := _m.Called(prefix)
@@ -70,6 +100,11 @@ func (_m *RequestHeaderMapReadOnly) GetByPrefix(prefix string) map[string][]stri
 	return r0
 }
 
+// Iterate provides a mock function with given fields: cb
+func (_m *RequestHeaderMapReadOnly) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
 // Method provides a mock function with given fields:
 func (_m *RequestHeaderMapReadOnly) Method() volatile.String {
 	ret := _m.Called()
@@ -97,3 +132,17 @@ func (_m *RequestHeaderMapReadOnly) Path() volatile.String {
 
 	return r0
 }
+
+// Size provides a mock function with given fields:
+func (_m *RequestHeaderMapReadOnly) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
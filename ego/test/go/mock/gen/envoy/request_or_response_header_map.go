@@ -22,6 +22,20 @@ func (_m *RequestOrResponseHeaderMap) AppendCopy(name string, value string) {
 	_m.Called(name, value)
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *RequestOrResponseHeaderMap) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // ContentType provides a mock function with given fields:
 func (_m *RequestOrResponseHeaderMap) ContentType() volatile.String {
 	ret := _m.Called()
@@ -50,6 +64,27 @@ func (_m *RequestOrResponseHeaderMap) Get(name string) volatile.String {
 	return r0
 }
 
+// GetAll provides a mock function with given fields: name
+func (_m *RequestOrResponseHeaderMap) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
+// Iterate provides a mock function with given fields: cb
+func (_m *RequestOrResponseHeaderMap) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
 // Remove provides a mock function with given fields: name
 func (_m *RequestOrResponseHeaderMap) Remove(name string) {
 	_m.Called(name)
@@ -59,3 +94,17 @@ func (_m *RequestOrResponseHeaderMap) Remove(name string) {
 func (_m *RequestOrResponseHeaderMap) SetCopy(name string, value string) {
 	_m.Called(name, value)
 }
+
+// Size provides a mock function with given fields:
+func (_m *RequestOrResponseHeaderMap) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
@@ -12,6 +12,20 @@ type RequestOrResponseHeaderMapReadOnly struct {
 	mock.Mock
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *RequestOrResponseHeaderMapReadOnly) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // ContentType provides a mock function with given fields:
 func (_m *RequestOrResponseHeaderMapReadOnly) ContentType() volatile.String {
 	ret := _m.Called()
@@ -39,3 +53,38 @@ func (_m *RequestOrResponseHeaderMapReadOnly) Get(name string) volatile.String {
 
 	return r0
 }
+
+// GetAll provides a mock function with given fields: name
+func (_m *RequestOrResponseHeaderMapReadOnly) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
+// Iterate provides a mock function with given fields: cb
+func (_m *RequestOrResponseHeaderMapReadOnly) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
+// Size provides a mock function with given fields:
+func (_m *RequestOrResponseHeaderMapReadOnly) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
@@ -22,6 +22,20 @@ func (_m *ResponseHeaderMap) AppendCopy(name string, value string) {
 	_m.Called(name, value)
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *ResponseHeaderMap) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // ContentType provides a mock function with given fields:
 func (_m *ResponseHeaderMap) ContentType() volatile.String {
 	ret := _m.Called()
@@ -50,6 +64,27 @@ func (_m *ResponseHeaderMap) Get(name string) volatile.String {
 	return r0
 }
 
+// GetAll provides a mock function with given fields: name
+func (_m *ResponseHeaderMap) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
+// Iterate provides a mock function with given fields: cb
+func (_m *ResponseHeaderMap) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
 // Remove provides a mock function with given fields: name
 func (_m *ResponseHeaderMap) Remove(name string) {
 	_m.Called(name)
@@ -65,6 +100,20 @@ func (_m *ResponseHeaderMap) SetStatus(status int) {
 	_m.Called(status)
 }
 
+// Size provides a mock function with given fields:
+func (_m *ResponseHeaderMap) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // Status provides a mock function with given fields:
 func (_m *ResponseHeaderMap) Status() volatile.String {
 	ret := _m.Called()
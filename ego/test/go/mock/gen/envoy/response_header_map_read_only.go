@@ -12,6 +12,20 @@ type ResponseHeaderMapReadOnly struct {
 	mock.Mock
 }
 
+// ByteSize provides a mock function with given fields:
+func (_m *ResponseHeaderMapReadOnly) ByteSize() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // ContentType provides a mock function with given fields:
 func (_m *ResponseHeaderMapReadOnly) ContentType() volatile.String {
 	ret := _m.Called()
@@ -40,6 +54,41 @@ func (_m *ResponseHeaderMapReadOnly) Get(name string) volatile.String {
 	return r0
 }
 
+// GetAll provides a mock function with given fields: name
+func (_m *ResponseHeaderMapReadOnly) GetAll(name string) []volatile.String {
+	ret := _m.Called(name)
+
+	var r0 []volatile.String
+	if rf, ok := ret.Get(0).(func(string) []volatile.String); ok {
+		r0 = rf(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]volatile.String)
+		}
+	}
+
+	return r0
+}
+
+// Iterate provides a mock function with given fields: cb
+func (_m *ResponseHeaderMapReadOnly) Iterate(cb func(volatile.String, volatile.String) bool) {
+	_m.Called(cb)
+}
+
+// Size provides a mock function with given fields:
+func (_m *ResponseHeaderMapReadOnly) Size() uint64 {
+	ret := _m.Called()
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func() uint64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	return r0
+}
+
 // Status provides a mock function with given fields:
 func (_m *ResponseHeaderMapReadOnly) Status() volatile.String {
 	ret := _m.Called()
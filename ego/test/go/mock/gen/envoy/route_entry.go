@@ -3,7 +3,9 @@
 package mocks
 
 import (
+	structpb "github.com/golang/protobuf/ptypes/struct"
 	envoy "github.com/grab/ego/ego/src/go/envoy"
+	volatile "github.com/grab/ego/ego/src/go/volatile"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -12,6 +14,50 @@ type RouteEntry struct {
 	mock.Mock
 }
 
+// ClusterName provides a mock function with given fields:
+func (_m *RouteEntry) ClusterName() (volatile.String, error) {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Metadata provides a mock function with given fields: namespace
+func (_m *RouteEntry) Metadata(namespace string) (*structpb.Struct, bool) {
+	ret := _m.Called(namespace)
+
+	var r0 *structpb.Struct
+	if rf, ok := ret.Get(0).(func(string) *structpb.Struct); ok {
+		r0 = rf(namespace)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*structpb.Struct)
+		}
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func(string) bool); ok {
+		r1 = rf(namespace)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // PathMatchCriterion provides a mock function with given fields:
 func (_m *RouteEntry) PathMatchCriterion() envoy.PathMatchCriterion {
 	ret := _m.Called()
@@ -27,3 +73,45 @@ func (_m *RouteEntry) PathMatchCriterion() envoy.PathMatchCriterion {
 
 	return r0
 }
+
+// RouteName provides a mock function with given fields:
+func (_m *RouteEntry) RouteName() (volatile.String, error) {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// VirtualHostName provides a mock function with given fields:
+func (_m *RouteEntry) VirtualHostName() (volatile.String, error) {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
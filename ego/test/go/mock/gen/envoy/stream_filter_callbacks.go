@@ -28,6 +28,11 @@ func (_m *StreamFilterCallbacks) ActiveSpan() envoy.Span {
 	return r0
 }
 
+// ClearRouteCache provides a mock function with given fields:
+func (_m *StreamFilterCallbacks) ClearRouteCache() {
+	_m.Called()
+}
+
 // Route provides a mock function with given fields:
 func (_m *StreamFilterCallbacks) Route() envoy.Route {
 	ret := _m.Called()
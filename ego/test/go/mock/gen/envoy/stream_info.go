@@ -3,10 +3,11 @@
 package mocks
 
 import (
-	envoy "github.com/grab/ego/ego/src/go/envoy"
-	mock "github.com/stretchr/testify/mock"
+	time "time"
 
+	envoy "github.com/grab/ego/ego/src/go/envoy"
 	volatile "github.com/grab/ego/ego/src/go/volatile"
+	mock "github.com/stretchr/testify/mock"
 )
 
 // StreamInfo is an autogenerated mock type for the StreamInfo type
@@ -14,6 +15,66 @@ type StreamInfo struct {
 	mock.Mock
 }
 
+// DownstreamLocalAddress provides a mock function with given fields:
+func (_m *StreamInfo) DownstreamLocalAddress() volatile.String {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	return r0
+}
+
+// DownstreamRemoteAddress provides a mock function with given fields:
+func (_m *StreamInfo) DownstreamRemoteAddress() volatile.String {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	return r0
+}
+
+// DownstreamSslConnection provides a mock function with given fields:
+func (_m *StreamInfo) DownstreamSslConnection() *envoy.SslConnectionInfo {
+	ret := _m.Called()
+
+	var r0 *envoy.SslConnectionInfo
+	if rf, ok := ret.Get(0).(func() *envoy.SslConnectionInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*envoy.SslConnectionInfo)
+		}
+	}
+
+	return r0
+}
+
+// DynamicMetadata provides a mock function with given fields:
+func (_m *StreamInfo) DynamicMetadata() envoy.DynamicMetadata {
+	ret := _m.Called()
+
+	var r0 envoy.DynamicMetadata
+	if rf, ok := ret.Get(0).(func() envoy.DynamicMetadata); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(envoy.DynamicMetadata)
+		}
+	}
+
+	return r0
+}
+
 // FilterState provides a mock function with given fields:
 func (_m *StreamInfo) FilterState() envoy.FilterState {
 	ret := _m.Called()
@@ -30,6 +91,48 @@ func (_m *StreamInfo) FilterState() envoy.FilterState {
 	return r0
 }
 
+// FirstDownstreamTxByteSent provides a mock function with given fields:
+func (_m *StreamInfo) FirstDownstreamTxByteSent() (time.Duration, bool) {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// FirstUpstreamRxByteReceived provides a mock function with given fields:
+func (_m *StreamInfo) FirstUpstreamRxByteReceived() (time.Duration, bool) {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // GetRequestHeaders provides a mock function with given fields:
 func (_m *StreamInfo) GetRequestHeaders() envoy.RequestHeaderMapReadOnly {
 	ret := _m.Called()
@@ -46,6 +149,27 @@ func (_m *StreamInfo) GetRequestHeaders() envoy.RequestHeaderMapReadOnly {
 	return r0
 }
 
+// LastDownstreamRxByteReceived provides a mock function with given fields:
+func (_m *StreamInfo) LastDownstreamRxByteReceived() (time.Duration, bool) {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // LastDownstreamTxByteSent provides a mock function with given fields:
 func (_m *StreamInfo) LastDownstreamTxByteSent() int64 {
 	ret := _m.Called()
@@ -60,6 +184,48 @@ func (_m *StreamInfo) LastDownstreamTxByteSent() int64 {
 	return r0
 }
 
+// Protocol provides a mock function with given fields:
+func (_m *StreamInfo) Protocol() (string, bool) {
+	ret := _m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// RequestComplete provides a mock function with given fields:
+func (_m *StreamInfo) RequestComplete() (time.Duration, bool) {
+	ret := _m.Called()
+
+	var r0 time.Duration
+	if rf, ok := ret.Get(0).(func() time.Duration); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Duration)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // ResponseCode provides a mock function with given fields:
 func (_m *StreamInfo) ResponseCode() int {
 	ret := _m.Called()
@@ -87,3 +253,45 @@ func (_m *StreamInfo) ResponseCodeDetails() volatile.String {
 
 	return r0
 }
+
+// RouteName provides a mock function with given fields:
+func (_m *StreamInfo) RouteName() volatile.String {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	return r0
+}
+
+// StartTime provides a mock function with given fields:
+func (_m *StreamInfo) StartTime() time.Time {
+	ret := _m.Called()
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func() time.Time); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	return r0
+}
+
+// UpstreamHost provides a mock function with given fields:
+func (_m *StreamInfo) UpstreamHost() volatile.String {
+	ret := _m.Called()
+
+	var r0 volatile.String
+	if rf, ok := ret.Get(0).(func() volatile.String); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(volatile.String)
+	}
+
+	return r0
+}
@@ -6,13 +6,18 @@
 package getheader
 
 import (
+	"time"
+
 	"github.com/golang/protobuf/proto"
 
 	pb "github.com/grab/ego/egofilters/http/getheader/proto"
+	"github.com/grab/ego/egofilters/http/httpclient"
 	ego "github.com/grab/ego/ego/src/go"
 	"github.com/grab/ego/ego/src/go/envoy"
 )
 
+const defaultTimeout = 2 * time.Second
+
 type factory struct {
 }
 
@@ -26,11 +31,27 @@ func (f factory) CreateFilterFactory(native envoy.GoHttpFilterConfig) (ego.HttpF
 		return nil, err
 	}
 
+	// shared by all filters, so that connections are reused
+	client := httpclient.NewHttpClient(clientOptions(&settings))
 	return func(native envoy.GoHttpFilter) ego.HttpFilter {
-		return newGetHeaderFilter(&settings, native)
+		return newGetHeaderFilter(&settings, client, native)
 	}, nil
 }
 
+func clientOptions(settings *pb.Settings) httpclient.ClientOptions {
+	options := httpclient.ClientOptions{
+		Timeout:       time.Duration(settings.TimeoutMs) * time.Millisecond,
+		PerTryTimeout: time.Duration(settings.PerTryTimeoutMs) * time.Millisecond,
+		NumRetries:    int(settings.NumRetries),
+		RetryBackoff:  time.Duration(settings.RetryBackoffBaseIntervalMs) * time.Millisecond,
+		MaxIdleConns:  int(settings.MaxIdleConnections),
+	}
+	if 0 == options.Timeout {
+		options.Timeout = defaultTimeout
+	}
+	return options
+}
+
 // CreateRouteSpecificFilterConfig ...
 func (f factory) CreateRouteSpecificFilterConfig(native envoy.GoHttpFilterConfig) (interface{}, error) {
 	return struct{}{}, nil
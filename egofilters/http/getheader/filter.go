@@ -7,10 +7,12 @@ package getheader
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
-	"time"
 
 	pb "github.com/grab/ego/egofilters/http/getheader/proto"
+	"github.com/grab/ego/egofilters/http/httpclient"
 	ego "github.com/grab/ego/ego/src/go"
 	"github.com/grab/ego/ego/src/go/envoy"
 	"github.com/grab/ego/ego/src/go/envoy/headersstatus"
@@ -20,14 +22,15 @@ import (
 type getHeaderFilter struct {
 	ego.HttpFilterBase
 	settings *pb.Settings
-	// Just a simple keep requestHeaders & response of 3rdParty httpCall for OnPost callback
+	client   httpclient.HttpClient
+	// Just a simple keep requestHeaders & result of 3rdParty httpCall for OnPost callback
 	requestHeaders envoy.RequestHeaderMap
-	httpResponse   *http.Response
+	header         string
 	httpErr        error
 }
 
-func newGetHeaderFilter(settings *pb.Settings, native envoy.GoHttpFilter) ego.HttpFilter {
-	f := &getHeaderFilter{settings: settings}
+func newGetHeaderFilter(settings *pb.Settings, client httpclient.HttpClient, native envoy.GoHttpFilter) ego.HttpFilter {
+	f := &getHeaderFilter{settings: settings, client: client}
 	f.HttpFilterBase.Init(native)
 	return f
 }
@@ -40,19 +43,7 @@ func (f *getHeaderFilter) DecodeHeaders(headers envoy.RequestHeaderMap, endStrea
 	go func() {
 		defer f.Unpin() // must do this for every go-routine. Includes f.Recover()
 
-		f.Context.Done()
-		request, err := http.NewRequestWithContext(f.Context, "GET", f.settings.Src, nil)
-		if err != nil {
-			// Send local repsonse
-			f.httpErr = err
-			f.Native.Post(0)
-			return
-		}
-
-		client := http.Client{
-			Timeout: 2 * time.Second,
-		}
-		f.httpResponse, f.httpErr = client.Do(request)
+		f.header, f.httpErr = f.getHeader()
 
 		// In this demo we only need one http-call at a time
 		// so tag = 0 because we don't need to manage multiple callback
@@ -64,6 +55,23 @@ func (f *getHeaderFilter) DecodeHeaders(headers envoy.RequestHeaderMap, endStrea
 	return headersstatus.StopAllIterationAndWatermark
 }
 
+// getHeader calls the source and returns its header. The body is drained and
+// closed, which ends the timeouts of the call and lets the connection be
+// reused.
+func (f *getHeaderFilter) getHeader() (string, error) {
+	request, err := http.NewRequestWithContext(f.Context, "GET", f.settings.Src, nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := f.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+	return response.Header.Get(f.settings.Hdr), nil
+}
+
 func (f *getHeaderFilter) OnPost(tag uint64) {
 	if f.httpErr != nil {
 		// Send local reply and not forward request to upstream
@@ -74,8 +82,7 @@ func (f *getHeaderFilter) OnPost(tag uint64) {
 		// Don't need to ContinueDecoding here, because we don't want to continue with forward to upstream
 		// If you try to do that OnDestoy may happen before and SendLocalReply will not have decodeCallback
 	} else {
-		respHeader := f.httpResponse.Header.Get(f.settings.Hdr)
-		f.requestHeaders.AddCopy(f.settings.Key, respHeader)
+		f.requestHeaders.AddCopy(f.settings.Key, f.header)
 		f.Native.DecoderCallbacks().ContinueDecoding()
 	}
 }
@@ -0,0 +1,201 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+// Package httpclient provides the HTTP client of the filters calling
+// services, with timeouts, retries and connection pooling.
+package httpclient
+
+import (
+	"bytes"
+	gocontext "context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryBackoff = 25 * time.Millisecond
+	// backoffs are capped at this multiple of the base interval
+	maxRetryBackoffFactor = 10
+)
+
+// HttpClient sends HTTP requests, like http.Client.
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// ClientOptions specifies the timeouts, retries and connection pooling of a
+// HttpClient. There are no timeouts and no retries by default.
+type ClientOptions struct {
+	// Timeout of a call, including all retries.
+	Timeout time.Duration
+	// Timeout of each try. Defaults to Timeout.
+	PerTryTimeout time.Duration
+	// Number of retries on connection errors and 5xx responses.
+	NumRetries int
+	// Base interval of the jittered exponential backoff between retries.
+	// Defaults to 25ms.
+	RetryBackoff time.Duration
+	// Maximum number of idle connections kept per host. Defaults to the one
+	// of http.DefaultTransport.
+	MaxIdleConns int
+}
+
+// TryTimeout returns the timeout of each try.
+func (o ClientOptions) TryTimeout() time.Duration {
+	if 0 != o.PerTryTimeout {
+		return o.PerTryTimeout
+	}
+	return o.Timeout
+}
+
+// NewHttpClient returns a HttpClient with its own transport, so that
+// connections are pooled across all requests sent by the returned client.
+// Create one per upstream and share it.
+func NewHttpClient(options ClientOptions) HttpClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if 0 != options.MaxIdleConns {
+		transport.MaxIdleConns = options.MaxIdleConns
+		transport.MaxIdleConnsPerHost = options.MaxIdleConns
+	}
+	return NewRetryingHttpClient(&http.Client{Transport: transport}, options)
+}
+
+// NewRetryingHttpClient returns a HttpClient enforcing the timeouts and
+// retries of options on client. Request bodies are buffered for retries,
+// unless their GetBody is set, as done by http.NewRequest for in-memory
+// bodies.
+func NewRetryingHttpClient(client HttpClient, options ClientOptions) HttpClient {
+	if 0 == options.RetryBackoff {
+		options.RetryBackoff = defaultRetryBackoff
+	}
+	return &retryingHttpClient{client: client, options: options, sleep: sleep}
+}
+
+type retryingHttpClient struct {
+	client  HttpClient
+	options ClientOptions
+	sleep   func(ctx gocontext.Context, d time.Duration) error
+}
+
+func (c *retryingHttpClient) Do(req *http.Request) (*http.Response, error) {
+	if c.options.NumRetries > 0 {
+		var err error
+		if req, err = rewindableRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := withTimeout(req.Context(), c.options.Timeout)
+	for try := 0; ; try++ {
+		tryCtx, cancelTry := withTimeout(ctx, c.options.PerTryTimeout)
+		resp, err := c.client.Do(req.WithContext(tryCtx))
+
+		retryable := nil != err || resp.StatusCode >= http.StatusInternalServerError
+		if !retryable || try >= c.options.NumRetries || nil != ctx.Err() || !rewindBody(req) {
+			if nil != err {
+				cancelTry()
+				cancel()
+				return nil, err
+			}
+			// the timeouts apply until the body is read
+			resp.Body = &cancelingBody{ReadCloser: resp.Body, cancel: func() {
+				cancelTry()
+				cancel()
+			}}
+			return resp, nil
+		}
+
+		if nil == err {
+			// allows reusing the connection
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancelTry()
+		if err := c.sleep(ctx, c.backoff(try)); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+}
+
+// backoff returns a random interval up to the base interval doubled for each
+// try, capped at maxRetryBackoffFactor times the base interval.
+func (c *retryingHttpClient) backoff(try int) time.Duration {
+	limit := c.options.RetryBackoff * maxRetryBackoffFactor
+	if try < 31 {
+		if interval := c.options.RetryBackoff << uint(try); interval < limit {
+			limit = interval
+		}
+	}
+	return time.Duration(rand.Int63n(int64(limit) + 1))
+}
+
+// rewindableRequest returns req with its body buffered, if it can't be
+// rewound otherwise.
+func rewindableRequest(req *http.Request) (*http.Request, error) {
+	if nil == req.Body || http.NoBody == req.Body || nil != req.GetBody {
+		return req, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req, nil
+}
+
+// rewindBody resets the body of req for another try.
+func rewindBody(req *http.Request) bool {
+	if nil == req.Body || http.NoBody == req.Body {
+		return true
+	}
+	if nil == req.GetBody {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	req.Body = body
+	return true
+}
+
+func withTimeout(ctx gocontext.Context, timeout time.Duration) (gocontext.Context, gocontext.CancelFunc) {
+	if 0 == timeout {
+		return gocontext.WithCancel(ctx)
+	}
+	return gocontext.WithTimeout(ctx, timeout)
+}
+
+func sleep(ctx gocontext.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelingBody cancels the context of the request once the body is closed.
+type cancelingBody struct {
+	io.ReadCloser
+	cancel gocontext.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,212 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package httpclient
+
+import (
+	gocontext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type httpClientFunc func(req *http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func responseWithStatus(statusCode int) *http.Response {
+	return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(strings.NewReader(http.StatusText(statusCode)))}
+}
+
+func TestRetryingHttpClientDo(t *testing.T) {
+	errConnect := errors.New("connection refused")
+	tcs := []struct {
+		name       string
+		numRetries int
+		results    []error
+		statuses   []int
+		body       bool
+
+		expectedTries  int
+		expectedStatus int
+		expectedErr    error
+	}{
+		{
+			name:           "should not retry successful calls",
+			numRetries:     2,
+			statuses:       []int{http.StatusOK},
+			expectedTries:  1,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should not retry client errors",
+			numRetries:     2,
+			statuses:       []int{http.StatusUnauthorized},
+			expectedTries:  1,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "should retry server errors",
+			numRetries:     2,
+			statuses:       []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedTries:  2,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should retry connection errors",
+			numRetries:     2,
+			results:        []error{errConnect, errConnect, nil},
+			statuses:       []int{0, 0, http.StatusOK},
+			body:           true,
+			expectedTries:  3,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "should return the last server error",
+			numRetries:     1,
+			statuses:       []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+			expectedTries:  2,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:          "should return the last connection error",
+			numRetries:    1,
+			results:       []error{errConnect, errConnect},
+			statuses:      []int{0, 0},
+			expectedTries: 2,
+			expectedErr:   errConnect,
+		},
+		{
+			name:          "should not retry without retries",
+			results:       []error{errConnect},
+			statuses:      []int{0},
+			expectedTries: 1,
+			expectedErr:   errConnect,
+		},
+	}
+
+	for _, val := range tcs {
+		tc := val
+		t.Run(tc.name, func(t *testing.T) {
+			tries := 0
+			client := NewRetryingHttpClient(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+				defer func() { tries++ }()
+				if tc.body {
+					// every try gets the whole body
+					body, err := ioutil.ReadAll(req.Body)
+					require.Nil(t, err)
+					assert.Equal(t, "request body", string(body))
+				}
+				if tries < len(tc.results) && nil != tc.results[tries] {
+					return nil, tc.results[tries]
+				}
+				return responseWithStatus(tc.statuses[tries]), nil
+			}), ClientOptions{NumRetries: tc.numRetries})
+			client.(*retryingHttpClient).sleep = func(gocontext.Context, time.Duration) error { return nil }
+
+			var body io.Reader
+			if tc.body {
+				body = strings.NewReader("request body")
+			}
+			req, err := http.NewRequest(http.MethodPost, "https://example.com", body)
+			require.Nil(t, err)
+
+			resp, err := client.Do(req)
+
+			assert.Equal(t, tc.expectedTries, tries)
+			assert.Equal(t, tc.expectedErr, err)
+			if nil == tc.expectedErr {
+				require.NotNil(t, resp)
+				assert.Equal(t, tc.expectedStatus, resp.StatusCode)
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+func TestRetryingHttpClientBuffersBody(t *testing.T) {
+	var bodies []string
+	client := NewRetryingHttpClient(httpClientFunc(func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.Nil(t, err)
+		bodies = append(bodies, string(body))
+		return responseWithStatus(http.StatusServiceUnavailable), nil
+	}), ClientOptions{NumRetries: 2, RetryBackoff: time.Millisecond})
+
+	// like the readers of Envoy buffers, this one can't be rewound
+	req, err := http.NewRequest(http.MethodPost, "https://example.com", ioutil.NopCloser(strings.NewReader("body")))
+	require.Nil(t, err)
+
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, []string{"body", "body", "body"}, bodies)
+}
+
+func TestRetryingHttpClientTimeouts(t *testing.T) {
+	var tries int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first try hangs beyond the per try timeout
+		if 1 == atomic.AddInt32(&tries, 1) {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewHttpClient(ClientOptions{
+		Timeout:       5 * time.Second,
+		PerTryTimeout: 50 * time.Millisecond,
+		NumRetries:    1,
+		RetryBackoff:  time.Millisecond,
+		MaxIdleConns:  4,
+	})
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.Nil(t, err)
+
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	// the body can still be read within the timeouts
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Nil(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tries))
+
+	// the overall timeout stops retrying
+	client = NewHttpClient(ClientOptions{Timeout: 20 * time.Millisecond, NumRetries: 10})
+	atomic.StoreInt32(&tries, 0)
+	req, err = http.NewRequest(http.MethodGet, server.URL, nil)
+	require.Nil(t, err)
+
+	_, err = client.Do(req)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tries))
+}
+
+func TestRetryingHttpClientBackoff(t *testing.T) {
+	client := NewRetryingHttpClient(nil, ClientOptions{}).(*retryingHttpClient)
+
+	for try, limit := range []time.Duration{25, 50, 100, 200, 250, 250} {
+		for i := 0; i < 10; i++ {
+			backoff := client.backoff(try)
+			assert.True(t, backoff >= 0 && backoff <= limit*time.Millisecond, backoff)
+		}
+	}
+	assert.Equal(t, 50*time.Millisecond, ClientOptions{Timeout: 50 * time.Millisecond}.TryTimeout())
+	assert.Equal(t, 10*time.Millisecond, ClientOptions{Timeout: 50 * time.Millisecond, PerTryTimeout: 10 * time.Millisecond}.TryTimeout())
+}
@@ -0,0 +1,75 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package security
+
+import (
+	"io"
+	"sync"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+)
+
+// bodyStream is the request body read by a streaming verifier while it
+// arrives. Chunks are queued until read, so that writing never blocks the
+// dispatcher.
+type bodyStream struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	chunks [][]byte
+	// set once closed, returned after the queued chunks
+	err error
+}
+
+func newBodyStream() *bodyStream {
+	s := &bodyStream{}
+	s.cond = sync.NewCond(&s.lock)
+	return s
+}
+
+// write queues a copy of data.
+func (s *bodyStream) write(data envoy.BufferInstance) {
+	chunk := make([]byte, data.Length())
+	data.CopyOut(0, chunk)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err == nil && len(chunk) > 0 {
+		s.chunks = append(s.chunks, chunk)
+		s.cond.Signal()
+	}
+}
+
+// closeWithError ends the stream. Read returns err after the queued chunks,
+// io.EOF if nil.
+func (s *bodyStream) closeWithError(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err == nil {
+		s.err = err
+		s.cond.Broadcast()
+	}
+}
+
+func (s *bodyStream) Read(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for len(s.chunks) == 0 && s.err == nil {
+		s.cond.Wait()
+	}
+	if len(s.chunks) == 0 {
+		return 0, s.err
+	}
+
+	n := copy(p, s.chunks[0])
+	if s.chunks[0] = s.chunks[0][n:]; len(s.chunks[0]) == 0 {
+		s.chunks = s.chunks[1:]
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package security
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+)
+
+func newBufferInstance(data string) *envoymocks.BufferInstance {
+	buffer := &envoymocks.BufferInstance{}
+	buffer.On("Length").Return(uint64(len(data)))
+	buffer.On("CopyOut", uint64(0), mock.Anything).Run(func(args mock.Arguments) {
+		copy(args[1].([]byte), data)
+	}).Return(len(data))
+	return buffer
+}
+
+func TestBodyStream(t *testing.T) {
+	stream := newBodyStream()
+
+	read := make(chan string)
+	go func() {
+		body, _ := ioutil.ReadAll(stream)
+		read <- string(body)
+	}()
+
+	stream.write(newBufferInstance("chunk1,"))
+	stream.write(newBufferInstance(""))
+	stream.write(newBufferInstance("chunk2"))
+	stream.closeWithError(nil)
+	// ignored once closed
+	stream.write(newBufferInstance("chunk3"))
+
+	assert.Equal(t, "chunk1,chunk2", <-read)
+}
+
+func TestBodyStreamError(t *testing.T) {
+	stream := newBodyStream()
+	errAborted := errors.New("aborted")
+
+	stream.write(newBufferInstance("chunk1"))
+	stream.closeWithError(errAborted)
+	stream.closeWithError(nil)
+
+	p := make([]byte, 4)
+	n, err := stream.Read(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "chun", string(p[:n]))
+	n, err = stream.Read(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "k1", string(p[:n]))
+	_, err = stream.Read(p)
+	assert.Equal(t, errAborted, err)
+	_, err = io.Copy(ioutil.Discard, stream)
+	assert.Equal(t, errAborted, err)
+}
@@ -7,26 +7,40 @@ package security
 
 import (
 	"errors"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 
 	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/envoy/stats"
 
 	pb "github.com/grab/ego/egofilters/http/security/proto"
 	"github.com/grab/ego/egofilters/http/security/verifier"
 )
 
 type securityStats struct {
-	// TODO: add more metrics if needed
 	authOK     envoy.Counter
 	authDenied envoy.Counter
 	authError  envoy.Counter
+	// denied requests which were replayed, also counted as authDenied
+	authReplayed envoy.Counter
+	// requests let through on errors, also counted as authError
+	authFailureModeAllowed envoy.Counter
+	verificationsInFlight  envoy.Gauge
 }
 
 type securityConfig struct {
 	verifiers map[string]verifier.Verifier
 	signers   map[string]verifier.Signer
 	stats     securityStats
+	// names of providers with failure_mode_allow set
+	failureModeAllow map[string]bool
+	// max_request_bytes of providers setting it
+	requestBytesLimits map[string]uint64
+	// nil for unknown providers
+	providerStats map[string]*verifier.ProviderStats
+	// per route and denial reason
+	dynamicStats *dynamicCounters
 }
 
 var (
@@ -50,47 +64,186 @@ func createSecurityConfig(native envoy.GoHttpFilterConfig) (*securityConfig, err
 	verifiers := map[string]verifier.Verifier{}
 	signers := map[string]verifier.Signer{}
 	providers := settings.GetProviders()
-	// TODO: use statsScope to create stats detail for every provider
 	scope := native.Scope()
 	authOK := scope.CounterFromStatName("auth_ok")
 	authDenied := scope.CounterFromStatName("auth_denied")
 	authError := scope.CounterFromStatName("auth_error")
-	if authOK == nil || authDenied == nil || authError == nil {
+	authReplayed := scope.CounterFromStatName("auth_replayed")
+	authFailureModeAllowed := scope.CounterFromStatName("auth_failure_mode_allowed")
+	verificationsInFlight := scope.GaugeFromStatName("verifications_in_flight", stats.Accumulate)
+	if authOK == nil || authDenied == nil || authError == nil || authReplayed == nil || authFailureModeAllowed == nil ||
+		verificationsInFlight == nil {
 		return nil, ErrCannotCreateStats
 	}
 	secStats := securityStats{
-		authOK:     authOK,
-		authDenied: authDenied,
-		authError:  authError,
+		authOK:                 authOK,
+		authDenied:             authDenied,
+		authError:              authError,
+		authReplayed:           authReplayed,
+		authFailureModeAllowed: authFailureModeAllowed,
+		verificationsInFlight:  verificationsInFlight,
 	}
+	failureModeAllow := map[string]bool{}
+	requestBytesLimits := map[string]uint64{}
+	providerStats := map[string]*verifier.ProviderStats{}
 
 	for k, v := range providers {
+		if v.FailureModeAllow {
+			failureModeAllow[k] = true
+		}
+		if 0 != v.MaxRequestBytes {
+			requestBytesLimits[k] = uint64(v.MaxRequestBytes)
+		}
+		var err error
+		if providerStats[k], err = createProviderStats(scope, k); err != nil {
+			return nil, err
+		}
 		switch v.GetProviderType().(type) {
 		case *pb.Provider_CustomHmacProvider:
-			hmacProvider, _ := verifier.CreateCustomHMACProvider(v.GetCustomHmacProvider())
+			var circuitState envoy.Gauge
+			if nil != v.GetCustomHmacProvider().GetHttpClient().GetCircuitBreaker() {
+				if circuitState, err = createCircuitStateGauge(scope, k); err != nil {
+					return nil, err
+				}
+			}
+			hmacProvider, _ := verifier.CreateCustomHMACProvider(v.GetCustomHmacProvider(), circuitState)
 			verifiers[k] = hmacProvider
 			if hmacProvider != nil {
 				signers[k] = hmacProvider
 			}
+		case *pb.Provider_JwtProvider:
+			jwtProvider, err := verifier.CreateJwtProvider(v.GetJwtProvider())
+			if err != nil {
+				return nil, err
+			}
+			verifiers[k] = jwtProvider
+		case *pb.Provider_LocalHmacProvider:
+			hmacProvider, err := verifier.CreateLocalHMACProvider(v.GetLocalHmacProvider())
+			if err != nil {
+				return nil, err
+			}
+			verifiers[k] = hmacProvider
+		case *pb.Provider_IntrospectionProvider:
+			var circuitState envoy.Gauge
+			if nil != v.GetIntrospectionProvider().GetHttpClient().GetCircuitBreaker() {
+				if circuitState, err = createCircuitStateGauge(scope, k); err != nil {
+					return nil, err
+				}
+			}
+			cacheStats, err := createTokenCacheStats(scope, k)
+			if err != nil {
+				return nil, err
+			}
+			introspectionProvider, err := verifier.CreateIntrospectionProvider(v.GetIntrospectionProvider(), circuitState, cacheStats)
+			if err != nil {
+				return nil, err
+			}
+			verifiers[k] = introspectionProvider
+		case *pb.Provider_ApiKeyProvider:
+			apiKeyProvider, err := verifier.CreateApiKeyProvider(v.GetApiKeyProvider())
+			if err != nil {
+				return nil, err
+			}
+			verifiers[k] = apiKeyProvider
+		case *pb.Provider_ClientCertificateProvider:
+			clientCertificateProvider, err := verifier.CreateClientCertificateProvider(v.GetClientCertificateProvider())
+			if err != nil {
+				return nil, err
+			}
+			verifiers[k] = clientCertificateProvider
 		default:
 			return nil, ErrUnsupportedProvider
 		}
 
 	}
+
+	if cacheSettings := settings.GetVerificationCache(); cacheSettings != nil {
+		cacheStats := verifier.CacheStats{
+			Hits:      scope.CounterFromStatName("cache_hit"),
+			Misses:    scope.CounterFromStatName("cache_miss"),
+			Evictions: scope.CounterFromStatName("cache_eviction"),
+		}
+		if cacheStats.Hits == nil || cacheStats.Misses == nil || cacheStats.Evictions == nil {
+			return nil, ErrCannotCreateStats
+		}
+		cache := verifier.NewVerificationCache(
+			int(cacheSettings.MaxEntries),
+			time.Duration(cacheSettings.PositiveTtlSeconds)*time.Second,
+			time.Duration(cacheSettings.NegativeTtlSeconds)*time.Second,
+			cacheStats)
+		for k, v := range verifiers {
+			verifiers[k] = verifier.NewCachingVerifier(k, v, cache)
+		}
+	}
+
 	return &securityConfig{
-		verifiers: verifiers,
-		signers:   signers,
-		stats:     secStats,
+		verifiers:          verifiers,
+		signers:            signers,
+		stats:              secStats,
+		failureModeAllow:   failureModeAllow,
+		requestBytesLimits: requestBytesLimits,
+		providerStats:      providerStats,
+		dynamicStats:       newDynamicCounters(scope),
 	}, nil
 }
 
 func (c *securityConfig) findProvider(requirement *pb.Requirement) (verifier.Verifier, verifier.Signer) {
-	// TODO: only take care of single provider for now. This needs to be
-	//       extended to handle requires_all & require_any to combine multiple
-	//       auth-types.
-	name := requirement.GetProviderName()
-	if name == "" {
-		return nil, nil
+	switch requirement.GetRequiresType().(type) {
+	case *pb.Requirement_ProviderName:
+		name := requirement.GetProviderName()
+		if stats := c.providerStats[name]; stats != nil {
+			return verifier.NewInstrumentedVerifier(c.verifiers[name], stats), verifier.NewInstrumentedSigner(c.signers[name], stats)
+		}
+		return c.verifiers[name], c.signers[name]
+	case *pb.Requirement_RequiresAny:
+		verifiers, signers := c.findProviders(requirement.GetRequiresAny().GetRequirements())
+		return verifier.NewRequiresAny(verifiers), verifier.NewRequirementSigner(signers)
+	case *pb.Requirement_RequiresAll:
+		verifiers, signers := c.findProviders(requirement.GetRequiresAll().GetRequirements())
+		return verifier.NewRequiresAll(verifiers), verifier.NewRequirementSigner(signers)
+	}
+	return nil, nil
+}
+
+// failureModeAllowed reports whether requests are let through if verifying
+// requirement fails with an error.
+func (c *securityConfig) failureModeAllowed(requirement *pb.Requirement) bool {
+	return requirement.FailureModeAllow || c.failureModeAllow[requirement.GetProviderName()]
+}
+
+// maxRequestBytes returns the request body size limit of requirement, which
+// is the smallest limit of its providers. There is no limit if 0.
+func (c *securityConfig) maxRequestBytes(requirement *pb.Requirement) uint64 {
+	var requirements []*pb.Requirement
+	switch requirement.GetRequiresType().(type) {
+	case *pb.Requirement_ProviderName:
+		return c.requestBytesLimits[requirement.GetProviderName()]
+	case *pb.Requirement_RequiresAny:
+		requirements = requirement.GetRequiresAny().GetRequirements()
+	case *pb.Requirement_RequiresAll:
+		requirements = requirement.GetRequiresAll().GetRequirements()
+	}
+
+	var limit uint64
+	for _, r := range requirements {
+		if l := c.maxRequestBytes(r); 0 != l && (0 == limit || l < limit) {
+			limit = l
+		}
+	}
+	return limit
+}
+
+// findProviders resolves the verifiers and signers of nested requirements.
+// Missing providers are left nil, so that they fail.
+func (c *securityConfig) findProviders(requirements []*pb.Requirement) ([]verifier.Verifier, []verifier.Signer) {
+	verifiers := make([]verifier.Verifier, len(requirements))
+	signers := make([]verifier.Signer, len(requirements))
+	for i, requirement := range requirements {
+		if requirement.GetRequiresType() == nil {
+			verifiers[i] = verifier.NotRequired()
+			continue
+		}
+		verifiers[i], signers[i] = c.findProvider(requirement)
 	}
-	return c.verifiers[name], c.signers[name]
+	return verifiers, signers
 }
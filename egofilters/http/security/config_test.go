@@ -14,9 +14,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	envoystats "github.com/grab/ego/ego/src/go/envoy/stats"
 	"github.com/grab/ego/ego/test/go/mock"
 
 	pb "github.com/grab/ego/egofilters/http/security/proto"
+	"github.com/grab/ego/egofilters/http/security/verifier"
+	verifiermocks "github.com/grab/ego/egofilters/mock/gen/http/security/verifier"
 
 	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
 )
@@ -30,6 +33,9 @@ func TestCreateSecurityConfig(t *testing.T) {
 		failedToCreateAuthDeniedCounter bool
 		failedToCreateAuthErrorCounter  bool
 
+		failedToCreateAuthReplayedCounter bool
+		failedToCreateProviderStats       bool
+
 		// verify
 		hasError  bool
 		verifiers map[string]string
@@ -54,6 +60,194 @@ func TestCreateSecurityConfig(t *testing.T) {
 			signers:   map[string]string{"my_custom_hmac_provider": "*verifier.customHMACProvider"},
 		},
 
+		{
+			name: "local HMAC verifier",
+			pbConfig: `
+				providers: <
+					key: "my_local_hmac_provider"
+					value: <
+						local_hmac_provider: <
+							secret_key: "hmac_secret"
+						>
+					>
+				>
+			`,
+
+			verifiers: map[string]string{"my_local_hmac_provider": "*verifier.localHMACProvider"},
+			signers:   map[string]string{},
+		},
+
+		{
+			name: "JWT verifier",
+			pbConfig: `
+				providers: <
+					key: "my_jwt_provider"
+					value: <
+						jwt_provider: <
+							issuer: "https://issuer.example.com"
+							remote_jwks: <
+								uri: "https://issuer.example.com/.well-known/jwks.json"
+							>
+						>
+					>
+				>
+			`,
+
+			verifiers: map[string]string{"my_jwt_provider": "*verifier.jwtProvider"},
+			signers:   map[string]string{},
+		},
+
+		{
+			name: "introspection verifier",
+			pbConfig: `
+				providers: <
+					key: "my_introspection_provider"
+					value: <
+						introspection_provider: <
+							introspection_url: "https://issuer.example.com/oauth2/introspect"
+							client_id: "introspection_client_id"
+							client_secret: "introspection_client_secret"
+							required_scopes: "read"
+						>
+					>
+				>
+			`,
+
+			verifiers: map[string]string{"my_introspection_provider": "*verifier.introspectionProvider"},
+			signers:   map[string]string{},
+		},
+
+		{
+			name: "API key verifier",
+			pbConfig: `
+				providers: <
+					key: "my_api_key_provider"
+					value: <
+						api_key_provider: <
+							header: "x-api-key"
+							key_secret_prefix: "api-key/"
+						>
+					>
+				>
+			`,
+
+			verifiers: map[string]string{"my_api_key_provider": "*verifier.apiKeyProvider"},
+			signers:   map[string]string{},
+		},
+
+		{
+			name: "API key verifier without key source",
+			pbConfig: `
+				providers: <
+					key: "my_api_key_provider"
+					value: <
+						api_key_provider: <
+							key_secret_prefix: "api-key/"
+						>
+					>
+				>
+			`,
+
+			hasError: true,
+		},
+
+		{
+			name: "client certificate verifier",
+			pbConfig: `
+				providers: <
+					key: "my_client_certificate_provider"
+					value: <
+						client_certificate_provider: <
+							allowed_uri_sans: "spiffe://mesh.example.com/ns/*/sa/billing"
+						>
+					>
+				>
+			`,
+
+			verifiers: map[string]string{"my_client_certificate_provider": "*verifier.clientCertificateProvider"},
+			signers:   map[string]string{},
+		},
+
+		{
+			name: "client certificate verifier without allowed identity",
+			pbConfig: `
+				providers: <
+					key: "my_client_certificate_provider"
+					value: <
+						client_certificate_provider: <
+							identity_header: "x-client-identity"
+						>
+					>
+				>
+			`,
+
+			hasError: true,
+		},
+
+		{
+			name: "JWT verifier with invalid local JWKS",
+			pbConfig: `
+				providers: <
+					key: "my_jwt_provider"
+					value: <
+						jwt_provider: <
+							local_jwks: "{\"keys\": []}"
+						>
+					>
+				>
+			`,
+
+			hasError: true,
+		},
+
+		{
+			name: "verification cache",
+			pbConfig: `
+				providers: <
+					key: "my_custom_hmac_provider"
+					value: <
+						custom_hmac_provider: <
+							request_validation_url: "https://custom-auth.example.com/v1/hmacverify"
+							service_key: "service_key"
+							service_token: "service_token"
+						>
+					>
+				>
+				verification_cache: <
+					max_entries: 100
+					positive_ttl_seconds: 10
+				>
+			`,
+
+			verifiers: map[string]string{"my_custom_hmac_provider": "*verifier.cachingVerifier"},
+			signers:   map[string]string{"my_custom_hmac_provider": "*verifier.customHMACProvider"},
+		},
+
+		{
+			name: "HMAC verifier with circuit breaker",
+			pbConfig: `
+				providers: <
+					key: "my_custom_hmac_provider"
+					value: <
+						custom_hmac_provider: <
+							request_validation_url: "https://custom-auth.example.com/v1/hmacverify"
+							service_key: "service_key"
+							service_token: "service_token"
+							http_client: <
+								num_retries: 2
+								circuit_breaker: <
+									failure_threshold: 5
+								>
+							>
+						>
+					>
+				>
+			`,
+
+			verifiers: map[string]string{"my_custom_hmac_provider": "*verifier.customHMACProvider"},
+			signers:   map[string]string{"my_custom_hmac_provider": "*verifier.customHMACProvider"},
+		},
+
 		{
 			name: "Can't create auth ok counter",
 			pbConfig: `
@@ -110,6 +304,44 @@ func TestCreateSecurityConfig(t *testing.T) {
 
 			hasError: true,
 		},
+
+		{
+			name: "Can't create auth replayed counter",
+			pbConfig: `
+				providers: <
+					key: "my_custom_hmac_provider"
+					value: <
+						custom_hmac_provider: <
+							request_validation_url: "https://custom-auth.example.com/v1/hmacverify"
+							service_key: "service_key"
+							service_token: "service_token"
+						>
+					>
+				>
+			`,
+			failedToCreateAuthReplayedCounter: true,
+
+			hasError: true,
+		},
+
+		{
+			name: "Can't create provider stats",
+			pbConfig: `
+				providers: <
+					key: "my_custom_hmac_provider"
+					value: <
+						custom_hmac_provider: <
+							request_validation_url: "https://custom-auth.example.com/v1/hmacverify"
+							service_key: "service_key"
+							service_token: "service_token"
+						>
+					>
+				>
+			`,
+			failedToCreateProviderStats: true,
+
+			hasError: true,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -132,10 +364,22 @@ func TestCreateSecurityConfig(t *testing.T) {
 			authErrorCounter := &envoymocks.Counter{}
 			authErrorCounter.TestData().Set("name", "auth_error")
 
+			authReplayedCounter := &envoymocks.Counter{}
+			authReplayedCounter.TestData().Set("name", "auth_replayed")
+
+			authFailureModeAllowedCounter := &envoymocks.Counter{}
+			authFailureModeAllowedCounter.TestData().Set("name", "auth_failure_mode_allowed")
+
+			verificationsInFlightGauge := &envoymocks.Gauge{}
+			verificationsInFlightGauge.TestData().Set("name", "verifications_in_flight")
+
 			stats := securityStats{
-				authOK:     authOkCounter,
-				authDenied: authDeniedCounter,
-				authError:  authErrorCounter,
+				authOK:                 authOkCounter,
+				authDenied:             authDeniedCounter,
+				authError:              authErrorCounter,
+				authReplayed:           authReplayedCounter,
+				authFailureModeAllowed: authFailureModeAllowedCounter,
+				verificationsInFlight:  verificationsInFlightGauge,
 			}
 
 			if tc.failedToCreateAuthOkCounter {
@@ -155,6 +399,38 @@ func TestCreateSecurityConfig(t *testing.T) {
 				scope.On("CounterFromStatName", "auth_error").Return(authErrorCounter)
 			}
 
+			if tc.failedToCreateAuthReplayedCounter {
+				scope.On("CounterFromStatName", "auth_replayed").Return(nil)
+			} else {
+				scope.On("CounterFromStatName", "auth_replayed").Return(authReplayedCounter)
+			}
+			scope.On("CounterFromStatName", "auth_failure_mode_allowed").Return(authFailureModeAllowedCounter)
+			scope.On("GaugeFromStatName", "verifications_in_flight", envoystats.Accumulate).Return(verificationsInFlightGauge)
+
+			for name := range settings.GetProviders() {
+				prefix := "provider." + name + "."
+				if tc.failedToCreateProviderStats {
+					scope.On("CounterFromStatName", prefix+"auth_ok").Return(nil)
+				} else {
+					scope.On("CounterFromStatName", prefix+"auth_ok").Return(&envoymocks.Counter{})
+				}
+				scope.On("CounterFromStatName", prefix+"auth_denied").Return(&envoymocks.Counter{})
+				scope.On("CounterFromStatName", prefix+"auth_error").Return(&envoymocks.Counter{})
+				scope.On("HistogramFromStatName", prefix+"verify_ms", envoystats.Milliseconds).Return(&envoymocks.Histogram{})
+				scope.On("HistogramFromStatName", prefix+"sign_ms", envoystats.Milliseconds).Return(&envoymocks.Histogram{})
+
+				circuitState := &envoymocks.Gauge{}
+				circuitState.On("Set", uint64(0))
+				scope.On("GaugeFromStatName", prefix+"circuit_state", envoystats.NeverImport).Return(circuitState).Maybe()
+				for _, name := range []string{"token_cache_hit", "token_cache_miss", "token_cache_eviction"} {
+					scope.On("CounterFromStatName", prefix+name).Return(&envoymocks.Counter{}).Maybe()
+				}
+			}
+
+			for _, name := range []string{"cache_hit", "cache_miss", "cache_eviction"} {
+				scope.On("CounterFromStatName", name).Return(&envoymocks.Counter{}).Maybe()
+			}
+
 			gohttpConfig := &mock.GoHttpFilterConfig{ConfigBytes: configBytes, EnvoyScope: scope}
 
 			securityConfig, err := createSecurityConfig(gohttpConfig)
@@ -169,6 +445,7 @@ func TestCreateSecurityConfig(t *testing.T) {
 
 				for name, verifierType := range tc.verifiers {
 					assert.Equal(t, verifierType, fmt.Sprintf("%v", reflect.TypeOf(securityConfig.verifiers[name])))
+					assert.NotNil(t, securityConfig.providerStats[name])
 				}
 
 				for name, verifierType := range tc.signers {
@@ -187,3 +464,149 @@ func TestUnmashalError(t *testing.T) {
 	_, err := createSecurityConfig(gohttpConfig)
 	assert.NotNil(t, err)
 }
+
+func TestFindProvider(t *testing.T) {
+	hmac := &verifiermocks.Verifier{}
+	hmacSigner := &verifiermocks.Signer{}
+	config := &securityConfig{
+		verifiers: map[string]verifier.Verifier{"hmac": hmac},
+		signers:   map[string]verifier.Signer{"hmac": hmacSigner},
+	}
+
+	tcs := []struct {
+		name        string
+		requirement string
+
+		expectedVerifier verifier.Verifier
+		expectedSigner   verifier.Signer
+	}{
+		{
+			name:             "provider name",
+			requirement:      `provider_name: "hmac"`,
+			expectedVerifier: hmac,
+			expectedSigner:   hmacSigner,
+		},
+		{
+			name:        "missing provider",
+			requirement: `provider_name: "jwt"`,
+		},
+		{
+			name:        "empty requirement",
+			requirement: ``,
+		},
+		{
+			name: "requires any",
+			requirement: `
+				requires_any: <
+					requirements: < provider_name: "hmac" >
+					requirements: < provider_name: "jwt" >
+				>
+			`,
+			expectedVerifier: verifier.NewRequiresAny([]verifier.Verifier{hmac, nil}),
+			expectedSigner:   verifier.NewRequirementSigner([]verifier.Signer{hmacSigner}),
+		},
+		{
+			name: "nested requires all",
+			requirement: `
+				requires_all: <
+					requirements: < provider_name: "hmac" >
+					requirements: <
+						requires_any: <
+							requirements: < provider_name: "hmac" >
+							requirements: < >
+						>
+					>
+				>
+			`,
+			expectedVerifier: verifier.NewRequiresAll([]verifier.Verifier{
+				hmac,
+				verifier.NewRequiresAny([]verifier.Verifier{hmac, verifier.NotRequired()}),
+			}),
+			expectedSigner: verifier.NewRequirementSigner([]verifier.Signer{
+				hmacSigner,
+				verifier.NewRequirementSigner([]verifier.Signer{hmacSigner}),
+			}),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			requirement := &pb.Requirement{}
+			require.Nil(t, proto.UnmarshalText(tc.requirement, requirement))
+
+			actualVerifier, actualSigner := config.findProvider(requirement)
+
+			if tc.expectedVerifier == nil {
+				assert.Nil(t, actualVerifier)
+			} else {
+				assert.Equal(t, tc.expectedVerifier, actualVerifier)
+			}
+			if tc.expectedSigner == nil {
+				assert.Nil(t, actualSigner)
+			} else {
+				assert.Equal(t, tc.expectedSigner, actualSigner)
+			}
+		})
+	}
+}
+
+func TestFindProviderInstrumented(t *testing.T) {
+	hmac := &verifiermocks.Verifier{}
+	hmacSigner := &verifiermocks.Signer{}
+	config := &securityConfig{
+		verifiers:     map[string]verifier.Verifier{"hmac": hmac},
+		signers:       map[string]verifier.Signer{"hmac": hmacSigner},
+		providerStats: map[string]*verifier.ProviderStats{"hmac": {}},
+	}
+
+	actualVerifier, actualSigner := config.findProvider(&pb.Requirement{
+		RequiresType: &pb.Requirement_ProviderName{ProviderName: "hmac"},
+	})
+	assert.Equal(t, "*verifier.instrumentedVerifier", fmt.Sprintf("%v", reflect.TypeOf(actualVerifier)))
+	assert.Equal(t, "*verifier.instrumentedSigner", fmt.Sprintf("%v", reflect.TypeOf(actualSigner)))
+}
+
+func TestMaxRequestBytes(t *testing.T) {
+	config := &securityConfig{
+		requestBytesLimits: map[string]uint64{"hmac": 1024, "jwt": 512},
+	}
+
+	tcs := []struct {
+		requirement string
+		limit       uint64
+	}{
+		{requirement: `provider_name: "hmac"`, limit: 1024},
+		{requirement: `provider_name: "unlimited"`, limit: 0},
+		{requirement: ``, limit: 0},
+		{requirement: `requires_any: < requirements: < provider_name: "hmac" > requirements: < provider_name: "unlimited" > >`, limit: 1024},
+		{requirement: `requires_all: < requirements: < provider_name: "hmac" > requirements: < requires_any: < requirements: < provider_name: "jwt" > requirements: < > > > >`, limit: 512},
+	}
+
+	for _, tc := range tcs {
+		requirement := &pb.Requirement{}
+		require.Nil(t, proto.UnmarshalText(tc.requirement, requirement))
+		assert.Equal(t, tc.limit, config.maxRequestBytes(requirement), tc.requirement)
+	}
+}
+
+func TestFailureModeAllowed(t *testing.T) {
+	config := &securityConfig{
+		failureModeAllow: map[string]bool{"hmac": true},
+	}
+
+	tcs := []struct {
+		requirement string
+		allowed     bool
+	}{
+		{requirement: `provider_name: "hmac"`, allowed: true},
+		{requirement: `provider_name: "jwt"`, allowed: false},
+		{requirement: `provider_name: "jwt" failure_mode_allow: true`, allowed: true},
+		{requirement: `requires_any: < requirements: < provider_name: "hmac" > requirements: < provider_name: "jwt" > >`, allowed: false},
+	}
+
+	for _, tc := range tcs {
+		requirement := &pb.Requirement{}
+		require.Nil(t, proto.UnmarshalText(tc.requirement, requirement))
+		assert.Equal(t, tc.allowed, config.failureModeAllowed(requirement), tc.requirement)
+	}
+}
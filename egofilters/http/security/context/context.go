@@ -9,4 +9,5 @@ import "github.com/grab/ego/ego/src/go/envoy"
 
 type Context interface {
 	ActiveSpan() envoy.Span
+	AsyncClient() envoy.AsyncClient
 }
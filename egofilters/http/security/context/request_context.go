@@ -9,6 +9,8 @@ import (
 	gocontext "context"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/grab/ego/ego/src/go/envoy"
 	"github.com/grab/ego/ego/src/go/logger"
@@ -26,6 +28,28 @@ const (
 
 const (
 	FilterStatePrefix = "egodemo.security.ctx.session."
+	// DynamicMetadataNamespace is the filter metadata namespace under which
+	// FilterState entries are published for access logs, RBAC, etc.
+	DynamicMetadataNamespace = "egodemo.security"
+)
+
+const (
+	// DenialReasonReplayed denies a request which was seen before or is too
+	// old to tell.
+	DenialReasonReplayed = "replayed"
+	// DenialReasonInactiveToken denies an expired, revoked or unknown token.
+	DenialReasonInactiveToken = "inactive_token"
+	// DenialReasonInsufficientScope denies a token lacking a required scope.
+	DenialReasonInsufficientScope = "insufficient_scope"
+	// DenialReasonInvalidAudience denies a token meant for someone else.
+	DenialReasonInvalidAudience = "invalid_audience"
+	// DenialReasonInvalidApiKey denies an unknown API key.
+	DenialReasonInvalidApiKey = "invalid_api_key"
+	// DenialReasonNoClientCertificate denies a peer without TLS certificate.
+	DenialReasonNoClientCertificate = "no_client_certificate"
+	// DenialReasonIdentityNotAllowed denies a peer whose certificate doesn't
+	// match any allowed identity.
+	DenialReasonIdentityNotAllowed = "identity_not_allowed"
 )
 
 // Authentication response object for a Callbacks.
@@ -36,15 +60,32 @@ type AuthResponse struct {
 	StatusCode int
 	// Optional http body used only on denied response.
 	Body string
-	// Optional http headers used on either denied or ok responses.
+	// Optional template of the body of denied responses, replacing Body.
+	// It's a text/template executed with the AuthResponse, whose json
+	// function quotes values, e.g. {"reason": {{json .DenialReason}}}.
+	BodyTemplate string
+	// Optional content type of the body of denied responses. Defaults to
+	// application/json with a BodyTemplate.
+	ContentType string
+	// Optional http headers removed from the request on ok responses.
 	HeadersToRemove map[string]struct{}
 	// Optional http headers used on either denied or ok responses.
 	HeadersToSet map[string]string
 	// Optional http headers used on either denied or ok responses.
 	HeadersToAppend map[string]string
 	// Filter State. FilterStatePrefix will be added to keys
-	// before storing in FilterState
+	// before storing in FilterState. The entries are published
+	// to the DynamicMetadataNamespace as well.
 	FilterState map[string]string
+	// Optional reason of a denied response, for stats.
+	DenialReason string
+	// Optional response code details of denied or error responses, shown
+	// by %RESPONSE_CODE_DETAILS% in access logs. Derived from the
+	// DenialReason by default.
+	ResponseCodeDetails string
+	// Optional expiry of the verified credentials. Cached responses don't
+	// outlive it.
+	ExpiresAt time.Time
 }
 
 func AuthResponseOK() AuthResponse {
@@ -68,6 +109,15 @@ func AuthResponseDenied(statusCode int) AuthResponse {
 	}
 }
 
+func AuthResponseReplayed() AuthResponse {
+	return AuthResponse{
+		Status:       AuthDenied,
+		StatusCode:   http.StatusUnauthorized,
+		Body:         "replayed request",
+		DenialReason: DenialReasonReplayed,
+	}
+}
+
 func AuthResponseError() AuthResponse {
 	return AuthResponse{
 		Status:     AuthError,
@@ -86,17 +136,26 @@ type RequestContext interface {
 	GoContext() gocontext.Context
 	BodyReader() io.Reader
 	GetSecret(string) string
+	// GetSecretsWithPrefix returns the secrets whose name starts with
+	// prefix, keyed by the rest of their name.
+	GetSecretsWithPrefix(prefix string) map[string]string
+	// StreamInfo returns the stream info of the request, e.g. for the TLS
+	// peer certificate.
+	StreamInfo() envoy.StreamInfo
 	Logger() logger.Logger
 }
 
 type requestContextImpl struct {
-	callbacks  Callbacks
-	goContext  gocontext.Context
-	headers    envoy.RequestHeaderMap
-	bodyReader io.Reader
-	secrets    map[string]string
-	activeSpan envoy.Span
-	logger     logger.Logger
+	callbacks   Callbacks
+	goContext   gocontext.Context
+	headers     envoy.RequestHeaderMap
+	bodyReader  io.Reader
+	secrets     map[string]string
+	activeSpan  envoy.Span
+	asyncClient envoy.AsyncClient
+	logger      logger.Logger
+	// only asked for the stream info when needed
+	streamCallbacks envoy.StreamFilterCallbacks
 }
 
 func (c *requestContextImpl) Callbacks() Callbacks {
@@ -119,6 +178,20 @@ func (c *requestContextImpl) GetSecret(key string) string {
 	return c.secrets[key]
 }
 
+func (c *requestContextImpl) GetSecretsWithPrefix(prefix string) map[string]string {
+	secrets := map[string]string{}
+	for k, v := range c.secrets {
+		if strings.HasPrefix(k, prefix) {
+			secrets[k[len(prefix):]] = v
+		}
+	}
+	return secrets
+}
+
+func (c *requestContextImpl) StreamInfo() envoy.StreamInfo {
+	return c.streamCallbacks.StreamInfo()
+}
+
 func (c *requestContextImpl) Logger() logger.Logger {
 	return c.logger
 }
@@ -127,15 +200,21 @@ func (c *requestContextImpl) ActiveSpan() envoy.Span {
 	return c.activeSpan
 }
 
-func CreateRequestContext(callbacks Callbacks, goContext gocontext.Context, activeSpan envoy.Span,
+func (c *requestContextImpl) AsyncClient() envoy.AsyncClient {
+	return c.asyncClient
+}
+
+func CreateRequestContext(callbacks Callbacks, goContext gocontext.Context, streamCallbacks envoy.StreamFilterCallbacks, asyncClient envoy.AsyncClient,
 	headers envoy.RequestHeaderMap, secrets map[string]string, bodyReader io.Reader, logger logger.Logger) RequestContext {
 	return &requestContextImpl{
-		callbacks:  callbacks,
-		goContext:  goContext,
-		headers:    headers,
-		secrets:    secrets,
-		logger:     logger,
-		bodyReader: bodyReader,
-		activeSpan: activeSpan,
+		callbacks:       callbacks,
+		goContext:       goContext,
+		headers:         headers,
+		secrets:         secrets,
+		logger:          logger,
+		bodyReader:      bodyReader,
+		activeSpan:      streamCallbacks.ActiveSpan(),
+		asyncClient:     asyncClient,
+		streamCallbacks: streamCallbacks,
 	}
 }
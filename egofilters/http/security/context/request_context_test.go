@@ -32,9 +32,27 @@ func Test_AuthResponseDenied(t *testing.T) {
 	assert.Equal(t, AuthDenied, response.Status)
 }
 
+func Test_AuthResponseReplayed(t *testing.T) {
+	response := AuthResponseReplayed()
+	assert.NotNil(t, response)
+	assert.Equal(t, 401, response.StatusCode)
+	assert.Equal(t, AuthDenied, response.Status)
+	assert.Equal(t, DenialReasonReplayed, response.DenialReason)
+}
+
 func Test_AuthResponseError(t *testing.T) {
 	response := AuthResponseError()
 	assert.NotNil(t, response)
 	assert.Equal(t, 500, response.StatusCode)
 	assert.Equal(t, AuthError, response.Status)
 }
+
+func Test_GetSecretsWithPrefix(t *testing.T) {
+	ctx := &requestContextImpl{secrets: map[string]string{
+		"api-key/billing":   "key1",
+		"api-key/billing#2": "key2",
+		"api-keys":          "key3",
+	}}
+	assert.Equal(t, map[string]string{"billing": "key1", "billing#2": "key2"}, ctx.GetSecretsWithPrefix("api-key/"))
+	assert.Empty(t, ctx.GetSecretsWithPrefix("unknown/"))
+}
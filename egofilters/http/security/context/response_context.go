@@ -45,6 +45,7 @@ type responseContextImpl struct {
 	callbacks      ResponseCallbacks
 	logger         logger.Logger
 	activeSpan     envoy.Span
+	asyncClient    envoy.AsyncClient
 }
 
 func (c *responseContextImpl) AuthResponse() AuthResponse {
@@ -83,7 +84,11 @@ func (c *responseContextImpl) ActiveSpan() envoy.Span {
 	return c.activeSpan
 }
 
-func CreateResponseContext(callbacks ResponseCallbacks, goContext gocontext.Context, activeSpan envoy.Span,
+func (c *responseContextImpl) AsyncClient() envoy.AsyncClient {
+	return c.asyncClient
+}
+
+func CreateResponseContext(callbacks ResponseCallbacks, goContext gocontext.Context, activeSpan envoy.Span, asyncClient envoy.AsyncClient,
 	secrets map[string]string, authResponse AuthResponse, requestHeaders envoy.RequestHeaderMap, headers envoy.ResponseHeaderMap,
 	bodyReader io.Reader, logger logger.Logger) ResponseContext {
 	return &responseContextImpl{
@@ -96,5 +101,6 @@ func CreateResponseContext(callbacks ResponseCallbacks, goContext gocontext.Cont
 		bodyReader:     bodyReader,
 		logger:         logger,
 		activeSpan:     activeSpan,
+		asyncClient:    asyncClient,
 	}
 }
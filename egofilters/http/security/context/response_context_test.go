@@ -65,3 +65,9 @@ func Test_ActiveSpan(t *testing.T) {
 	res := r.ActiveSpan()
 	assert.Nil(t, res)
 }
+
+func Test_AsyncClient(t *testing.T) {
+	r := &responseContextImpl{}
+	res := r.AsyncClient()
+	assert.Nil(t, res)
+}
@@ -54,6 +54,11 @@ func TestCreateFilterSuccessfully(t *testing.T) {
 	scope.On("CounterFromStatName", "auth_ok").Return(&envoymocks.Counter{})
 	scope.On("CounterFromStatName", "auth_denied").Return(&envoymocks.Counter{})
 	scope.On("CounterFromStatName", "auth_error").Return(&envoymocks.Counter{})
+	scope.On("CounterFromStatName", "auth_replayed").Return(&envoymocks.Counter{})
+	scope.On("CounterFromStatName", "auth_failure_mode_allowed").Return(&envoymocks.Counter{})
+	scope.On("GaugeFromStatName", "verifications_in_flight", mock.Anything).Return(&envoymocks.Gauge{})
+	scope.On("CounterFromStatName", mock.Anything).Return(&envoymocks.Counter{})
+	scope.On("HistogramFromStatName", mock.Anything, mock.Anything).Return(&envoymocks.Histogram{})
 
 	factoryFactory := CreateFactoryFactory()
 
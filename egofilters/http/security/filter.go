@@ -6,8 +6,13 @@
 package security
 
 import (
+	gocontext "context"
 	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
 
 	ego "github.com/grab/ego/ego/src/go"
 	"github.com/grab/ego/ego/src/go/envoy"
@@ -25,8 +30,15 @@ import (
 
 const (
 	FilterID = "security"
+
+	// FailureModeAllowedHeader marks requests let through on verifier errors.
+	FailureModeAllowedHeader = "x-ego-security-failure-mode-allowed"
+	// FailureModeAllowedState is the FilterState key marking such requests.
+	FailureModeAllowedState = "failure_mode_allowed"
 )
 
+var errRequestTooLarge = errors.New("request body too large")
+
 // State of this filter's communication with the verifier.
 // The filter has either not started calling the verifier, in the middle of calling it
 // or has completed.
@@ -46,6 +58,8 @@ const (
 	WaitingForResponseBody
 	// when the filter is signing response
 	Signing
+	// when a streaming verifier is reading the request body as it arrives
+	StreamingRequestBody
 )
 
 const (
@@ -63,6 +77,13 @@ type security struct {
 
 	verifier verifier.Verifier
 	signer   verifier.Signer
+	// whether to let requests through on verifier errors
+	failureModeAllow bool
+	// no limit if 0
+	maxRequestBytes uint64
+	requestBytes    uint64
+	// the request body read by streaming verifiers
+	body *bodyStream
 
 	// Used to caching response from OnComplete from a goroutine
 	authResponse context.AuthResponse
@@ -92,6 +113,9 @@ func newSecurity(native envoy.GoHttpFilter, config *securityConfig) ego.HttpFilt
 // Noted that we need to handle OnDestroy to stop whatever we're doing in securityFilter
 func (f *security) OnDestroy() {
 	f.Cancel()
+	if f.body != nil {
+		f.body.closeWithError(gocontext.Canceled)
+	}
 }
 
 func (f *security) DecodeHeaders(headers envoy.RequestHeaderMap, endStream bool) headersstatus.Type {
@@ -113,12 +137,22 @@ func (f *security) DecodeHeaders(headers envoy.RequestHeaderMap, endStream bool)
 		// FIXME: shouldn't we rather block the request?
 		return headersstatus.Continue
 	}
+	f.failureModeAllow = f.config.failureModeAllowed(&requirement)
+	f.maxRequestBytes = f.config.maxRequestBytes(&requirement)
 
 	f.requestHeaders = headers
 
 	// TODO: check logic for Http::Utility::isWebSocketUpgradeRequest(headers)
 	// and Http::Utility::isH2UpgradeRequest(headers)
 	if f.verifier.WithBody() && !endStream {
+		if verifier.StreamsBody(f.verifier) {
+			// The verifier reads the body while DecodeData receives it
+			f.Logger().Debug("[DecodeHeaders] start verify with streamed body")
+			f.body = newBodyStream()
+			f.startVerify(f.body)
+			f.state = StreamingRequestBody
+			return headersstatus.StopIteration
+		}
 		// Need to wait for body on DecodeData
 		f.state = WaitingForRequestBody
 		return headersstatus.StopIteration
@@ -136,13 +170,30 @@ func (f *security) DecodeData(data envoy.BufferInstance, endStream bool) datasta
 
 	f.Logger().Debug("[DecodeData] called")
 
-	if f.state != WaitingForRequestBody {
+	if f.state != WaitingForRequestBody && f.state != StreamingRequestBody {
 		return datastatus.Continue
 	}
 
+	if 0 != f.maxRequestBytes {
+		f.requestBytes += data.Length()
+		if f.requestBytes > f.maxRequestBytes {
+			f.rejectRequestTooLarge()
+			return datastatus.StopIterationNoBuffer
+		}
+	}
+
+	if f.state == StreamingRequestBody {
+		f.body.write(data)
+		if endStream {
+			f.body.closeWithError(nil)
+			f.state = Calling
+		}
+		// Envoy still buffers the body for the upstream
+		return datastatus.StopIterationAndBuffer
+	}
+
 	// Only purpose of DecodeData is buffer data, if it doesn't need just
 	// continue. Note that will only get here if we have a verifier.
-	// TODO: check how we can conveniently limit the buffer size.
 	if !endStream {
 		// wait for all data
 		return datastatus.StopIterationAndBuffer
@@ -159,10 +210,29 @@ func (f *security) DecodeData(data envoy.BufferInstance, endStream bool) datasta
 	return datastatus.StopIterationAndWatermark
 }
 
+// rejectRequestTooLarge replies 413 to requests with a body larger than
+// maxRequestBytes.
+func (f *security) rejectRequestTooLarge() {
+	f.Logger().Warn("[DecodeData] request body too large", logger.Data{
+		"max_request_bytes": f.maxRequestBytes,
+	})
+	if f.body != nil {
+		// ends a streaming verifier, its result is ignored
+		f.body.closeWithError(errRequestTooLarge)
+	}
+	f.state = Responded
+	f.Native.DecoderCallbacks().SendLocalReply(http.StatusRequestEntityTooLarge, "request body too large", nil, responseCodeDetailsRequestTooLarge)
+}
+
 func (f *security) DecodeTrailers(trailes envoy.RequestTrailerMap) trailersstatus.Type {
 
 	f.Logger().Debug("[DecodeTrailers] called")
 
+	if f.state == StreamingRequestBody {
+		// the body ends with the trailers
+		f.body.closeWithError(nil)
+		f.state = Calling
+	}
 	if f.state == Calling {
 		return trailersstatus.StopIteration
 	}
@@ -216,6 +286,7 @@ func (f *security) OnPost(tag uint64) {
 // OnComplete implement for Callbacks interface, will be called by Verifier
 func (f *security) OnComplete(response context.AuthResponse) {
 	f.Logger().Debug("[OnComplete] called")
+	f.config.stats.verificationsInFlight.Dec()
 	f.authResponse = response
 	f.Native.Post(authPost)
 	f.Unpin()
@@ -231,8 +302,9 @@ func (f *security) OnCompleteSigning(signResp context.SignResponse) {
 func (f *security) startVerify(body io.Reader) {
 	f.Logger().Debug("[startVerify] called")
 	f.state = Calling
-	ctx := context.CreateRequestContext(f, f.Context, f.Native.DecoderCallbacks().ActiveSpan(), f.requestHeaders, f.secrets, body, f.Logger())
+	ctx := context.CreateRequestContext(f, f.Context, f.Native.DecoderCallbacks(), f.Native.AsyncClient(), f.requestHeaders, f.secrets, body, f.Logger())
 	f.Pin()
+	f.config.stats.verificationsInFlight.Inc()
 	go func() {
 		f.verifier.Verify(ctx)
 	}()
@@ -247,52 +319,42 @@ func (f *security) endVerify() {
 		return
 	}
 
-	dc := f.Native.DecoderCallbacks()
 	response := f.authResponse
 
 	switch response.Status {
 	case context.AuthOK:
-		headers := f.requestHeaders
-		for k := range response.HeadersToRemove {
-			headers.Remove(k)
-		}
-		for k, v := range response.HeadersToSet {
-			headers.SetCopy(k, v)
-		}
-		for k, v := range response.HeadersToAppend {
-			headers.AppendCopy(k, v)
-		}
-		for k, v := range response.FilterState {
-			f.Native.DecoderCallbacks().StreamInfo().FilterState().SetData(context.FilterStatePrefix+k, v, statetype.ReadOnly, lifespan.DownstreamRequest)
-		}
 		f.config.stats.authOK.Inc()
-		f.state = Complete
-
-		// This function has been called only from OnPost, result of OnComplete from a goroutine
-		// So we need to continue decoding because the case not allow already handled above
-		dc.ContinueDecoding()
+		f.incRouteCounter("auth_ok")
+		f.allowRequest(response)
 
 	case context.AuthDenied:
-		// TODO: support modify headers of the local response to client
 		f.Logger().Warn("[endVerify] could not authenticate the request", logger.Data{
 			"status_code": response.StatusCode,
+			"reason":      response.DenialReason,
 		})
-		f.state = Responded
-
-		// use only HeadersToSet for now. Consider adding HeaderToAppend and HeaderToRemove if any use cases.
-		dc.SendLocalReply(response.StatusCode, response.Body, response.HeadersToSet, "")
+		f.sendLocalReply(response, responseCodeDetailsDenied)
 		f.config.stats.authDenied.Inc()
+		f.incRouteCounter("auth_denied")
+		f.config.dynamicStats.inc(denialReasonStatName(response))
+		if response.DenialReason == context.DenialReasonReplayed {
+			f.config.stats.authReplayed.Inc()
+		}
 
 	case context.AuthError:
-		// TODO: check possibility to support failureModeAllow
+		f.config.stats.authError.Inc()
+		f.incRouteCounter("auth_error")
+		if f.failureModeAllow {
+			f.Logger().Warn("[endVerify] let the request through despite an error", logger.Data{
+				"status_code": response.StatusCode,
+			})
+			f.config.stats.authFailureModeAllowed.Inc()
+			f.allowRequest(failureModeAllowedResponse())
+			return
+		}
 		f.Logger().Warn("[endVerify] rejected the request with an error", logger.Data{
 			"status_code": response.StatusCode,
 		})
-		f.state = Responded
-
-		// use only HeadersToSet for now. Consider adding HeaderToAppend and HeaderToRemove if any use cases.
-		dc.SendLocalReply(response.StatusCode, response.Body, response.HeadersToSet, "")
-		f.config.stats.authError.Inc()
+		f.sendLocalReply(response, responseCodeDetailsError)
 
 	default:
 		f.Logger().Warn("[endVerify] unknown response status from the verifier", logger.Data{
@@ -303,13 +365,82 @@ func (f *security) endVerify() {
 	}
 }
 
+// sendLocalReply replies to a request denied or failed by the verifier.
+// details are the response code details if the verifier didn't set any.
+func (f *security) sendLocalReply(response context.AuthResponse, details string) {
+	reply, err := newLocalReply(response, details)
+	if err != nil {
+		f.Logger().Error("[sendLocalReply] can't render the body template.", err)
+	}
+	f.state = Responded
+	f.Native.DecoderCallbacks().SendLocalReply(reply.statusCode, reply.body, reply.headers, reply.details)
+}
+
+// allowRequest applies the mutations of response to the request and
+// continues decoding.
+func (f *security) allowRequest(response context.AuthResponse) {
+	headers := f.requestHeaders
+	// only we may mark requests as let through on errors
+	headers.Remove(FailureModeAllowedHeader)
+	for k := range response.HeadersToRemove {
+		headers.Remove(k)
+	}
+	for k, v := range response.HeadersToSet {
+		headers.SetCopy(k, v)
+	}
+	for k, v := range response.HeadersToAppend {
+		headers.AppendCopy(k, v)
+	}
+	for k, v := range response.FilterState {
+		f.Native.DecoderCallbacks().StreamInfo().FilterState().SetData(context.FilterStatePrefix+k, v, statetype.ReadOnly, lifespan.DownstreamRequest)
+	}
+	if len(response.FilterState) > 0 {
+		metadata := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(response.FilterState))}
+		for k, v := range response.FilterState {
+			metadata.Fields[k] = &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v}}
+		}
+		f.Native.DecoderCallbacks().StreamInfo().DynamicMetadata().Set(context.DynamicMetadataNamespace, metadata)
+	}
+	f.state = Complete
+
+	// This function has been called only from OnPost, result of OnComplete from a goroutine
+	// So we need to continue decoding because the case not allow already handled above
+	f.Native.DecoderCallbacks().ContinueDecoding()
+}
+
+// incRouteCounter counts result for the route of the request, unless the
+// route has no name.
+func (f *security) incRouteCounter(result string) {
+	route := f.Native.DecoderCallbacks().Route()
+	if route == nil {
+		return
+	}
+	entry := route.RouteEntry()
+	if entry == nil {
+		return
+	}
+	name, err := entry.RouteName()
+	if err != nil || "" == name {
+		return
+	}
+	f.config.dynamicStats.inc(routeStatName(name.Copy(), result))
+}
+
+// failureModeAllowedResponse marks a request let through on an error.
+func failureModeAllowedResponse() context.AuthResponse {
+	response := context.AuthResponseOK()
+	response.HeadersToSet = map[string]string{FailureModeAllowedHeader: "true"}
+	response.FilterState = map[string]string{FailureModeAllowedState: "true"}
+	return response
+}
+
 func (f *security) startSigning(body io.Reader) {
 
 	f.Logger().Debug("[startSigning] called")
 
 	f.state = Signing
 	var ctx = context.CreateResponseContext(
-		f, f.Context, f.Native.EncoderCallbacks().ActiveSpan(), f.secrets, f.authResponse, f.requestHeaders, f.responseHeaders, body, f.Logger())
+		f, f.Context, f.Native.EncoderCallbacks().ActiveSpan(), f.Native.AsyncClient(), f.secrets, f.authResponse, f.requestHeaders, f.responseHeaders, body, f.Logger())
 
 	f.Pin()
 	go func() {
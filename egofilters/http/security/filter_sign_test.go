@@ -101,6 +101,7 @@ func TestEncodeHeaders(t *testing.T) {
 			secretProvider.On("Secret").Return(volatile.String("zzz"))
 
 			native.On("Log", mock.Anything, mock.Anything)
+			native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
 
 			decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
 			native.On("DecoderCallbacks").Return(decoderCallbacks)
@@ -224,6 +225,7 @@ func TestEncodeData(t *testing.T) {
 			secretProvider.On("Secret").Return(volatile.String("zzz"))
 
 			native.On("Log", mock.Anything, mock.Anything)
+			native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
 
 			decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
 			native.On("DecoderCallbacks").Return(decoderCallbacks)
@@ -334,6 +336,7 @@ func TestOnCompleteSigning(t *testing.T) {
 			secretProvider.On("Secret").Return(volatile.String("zzz"))
 
 			native.On("Log", mock.Anything, mock.Anything)
+			native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
 
 			decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
 			native.On("DecoderCallbacks").Return(decoderCallbacks)
@@ -6,10 +6,14 @@
 package security
 
 import (
+	"io/ioutil"
+	"net/http"
 	"strings"
 	"sync"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	structpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
@@ -129,6 +133,7 @@ func TestDecodeHeaders(t *testing.T) {
 			secretProvider.On("Secret").Return(volatile.String("zzz"))
 
 			native.On("Log", mock.Anything, mock.Anything)
+			native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
 
 			decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
 			native.On("DecoderCallbacks").Return(decoderCallbacks)
@@ -141,10 +146,13 @@ func TestDecodeHeaders(t *testing.T) {
 			native.On("ResolveMostSpecificPerGoFilterConfig", FilterID, route).Return(tc.routeSpecificFilterConfig)
 
 			provider := &verifiermocks.Verifier{}
+			verificationsInFlight := &envoymocks.Gauge{}
+			verificationsInFlight.On("Inc")
 			config := &securityConfig{
 				verifiers: map[string]verifier.Verifier{
 					"my_verifier": provider,
 				},
+				stats: securityStats{verificationsInFlight: verificationsInFlight},
 			}
 			provider.On("WithBody").Return(tc.requestBodyRequired)
 
@@ -185,10 +193,12 @@ func TestDecodeData(t *testing.T) {
 		// set-up
 		endstream                 bool
 		routeSpecificFilterConfig interface{}
+		maxRequestBytes           uint64
 
 		// verify
 		expectedDataStatus datastatus.Type
 		verifierCalled     bool
+		tooLarge           bool
 	}{
 		{
 			name:      "should call verifier if endstream is true",
@@ -221,6 +231,29 @@ func TestDecodeData(t *testing.T) {
 			expectedDataStatus: datastatus.Continue,
 			verifierCalled:     false,
 		},
+		{
+			name:      "should call verifier if the body doesn't exceed max_request_bytes",
+			endstream: true,
+			routeSpecificFilterConfig: pb.Requirement{
+				RequiresType: &pb.Requirement_ProviderName{ProviderName: "my_verifier"},
+			},
+			maxRequestBytes: 3,
+
+			expectedDataStatus: datastatus.StopIterationAndWatermark,
+			verifierCalled:     true,
+		},
+		{
+			name:      "should reply 413 if the body exceeds max_request_bytes",
+			endstream: false,
+			routeSpecificFilterConfig: pb.Requirement{
+				RequiresType: &pb.Requirement_ProviderName{ProviderName: "my_verifier"},
+			},
+			maxRequestBytes: 2,
+
+			expectedDataStatus: datastatus.StopIterationNoBuffer,
+			verifierCalled:     false,
+			tooLarge:           true,
+		},
 	}
 
 	for _, tc := range tcs {
@@ -237,6 +270,7 @@ func TestDecodeData(t *testing.T) {
 			secretProvider.On("Secret").Return(volatile.String("zzz"))
 
 			native.On("Log", mock.Anything, mock.Anything)
+			native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
 
 			decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
 			native.On("DecoderCallbacks").Return(decoderCallbacks)
@@ -249,10 +283,14 @@ func TestDecodeData(t *testing.T) {
 			native.On("ResolveMostSpecificPerGoFilterConfig", FilterID, route).Return(tc.routeSpecificFilterConfig)
 
 			provider := &verifiermocks.Verifier{}
+			verificationsInFlight := &envoymocks.Gauge{}
+			verificationsInFlight.On("Inc")
 			config := &securityConfig{
 				verifiers: map[string]verifier.Verifier{
 					"my_verifier": provider,
 				},
+				stats:              securityStats{verificationsInFlight: verificationsInFlight},
+				requestBytesLimits: map[string]uint64{"my_verifier": tc.maxRequestBytes},
 			}
 			provider.On("WithBody").Return(true)
 
@@ -268,7 +306,11 @@ func TestDecodeData(t *testing.T) {
 			filter.DecodeHeaders(headerMap, false)
 
 			bufferInstance := &envoymocks.BufferInstance{}
+			bufferInstance.On("Length").Return(uint64(3))
 			decoderCallbacks.On("AddDecodedData", bufferInstance, true)
+			if tc.tooLarge {
+				decoderCallbacks.On("SendLocalReply", http.StatusRequestEntityTooLarge, "request body too large", map[string]string(nil), responseCodeDetailsRequestTooLarge)
+			}
 
 			fullBuffer := &envoymocks.BufferInstance{}
 
@@ -282,6 +324,10 @@ func TestDecodeData(t *testing.T) {
 
 			// Continue if not route specific route config
 			assert.Equal(t, tc.expectedDataStatus, decodeDataResult)
+			if tc.tooLarge {
+				decoderCallbacks.AssertCalled(t, "SendLocalReply", http.StatusRequestEntityTooLarge, "request body too large", map[string]string(nil), responseCodeDetailsRequestTooLarge)
+				provider.AssertNotCalled(t, "Verify", mock.Anything)
+			}
 			if tc.verifierCalled {
 				decoderCallbacks.AssertExpectations(t)
 
@@ -298,11 +344,81 @@ func TestDecodeData(t *testing.T) {
 	}
 }
 
+// streamingVerifier is a Verifier mock reading the body as a stream.
+type streamingVerifier struct {
+	*verifiermocks.Verifier
+}
+
+func (v streamingVerifier) StreamsBody() bool {
+	return true
+}
+
+func TestDecodeDataStreaming(t *testing.T) {
+	native := &envoymocks.GoHttpFilter{}
+
+	wg := sync.WaitGroup{}
+	native.On("Pin").Run(func(args mock.Arguments) {
+		wg.Add(1)
+	})
+
+	secretProvider := &envoymocks.GenericSecretConfigProvider{}
+	native.On("GenericSecretProvider").Return((secretProvider))
+	secretProvider.On("Secret").Return(volatile.String("zzz"))
+
+	native.On("Log", mock.Anything, mock.Anything)
+	native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
+
+	decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
+	native.On("DecoderCallbacks").Return(decoderCallbacks)
+
+	decoderCallbacks.On("ActiveSpan").Return(&envoymocks.Span{})
+
+	route := &envoymocks.Route{}
+	decoderCallbacks.On("Route").Return(route)
+
+	native.On("ResolveMostSpecificPerGoFilterConfig", FilterID, route).Return(pb.Requirement{
+		RequiresType: &pb.Requirement_ProviderName{ProviderName: "my_verifier"},
+	})
+
+	provider := &verifiermocks.Verifier{}
+	verificationsInFlight := &envoymocks.Gauge{}
+	verificationsInFlight.On("Inc")
+	config := &securityConfig{
+		verifiers: map[string]verifier.Verifier{
+			"my_verifier": streamingVerifier{provider},
+		},
+		stats: securityStats{verificationsInFlight: verificationsInFlight},
+	}
+	provider.On("WithBody").Return(true)
+
+	var body []byte
+	provider.On("Verify", mock.Anything).Run(func(args mock.Arguments) {
+		defer wg.Done()
+		body, _ = ioutil.ReadAll(args[0].(context.RequestContext).BodyReader())
+	})
+
+	filter := newSecurity(native, config)
+
+	// the verifier is started before the body arrives
+	assert.Equal(t, headersstatus.StopIteration, filter.DecodeHeaders(&envoymocks.RequestHeaderMap{}, false))
+	provider.AssertCalled(t, "WithBody")
+	assert.Equal(t, datastatus.StopIterationAndBuffer, filter.DecodeData(newBufferInstance("chunk1,"), false))
+	assert.Equal(t, datastatus.StopIterationAndBuffer, filter.DecodeData(newBufferInstance("chunk2"), true))
+
+	wg.Wait()
+
+	assert.Equal(t, "chunk1,chunk2", string(body))
+	// the body is never read from the buffer
+	decoderCallbacks.AssertNotCalled(t, "DecodingBuffer")
+	decoderCallbacks.AssertNotCalled(t, "AddDecodedData", mock.Anything, mock.Anything)
+}
+
 func TestOnComplete(t *testing.T) {
 	type localReplyData struct {
 		StatusCode int
 		Body       string
 		Header     map[string]string
+		Details    string
 	}
 
 	tcs := []struct {
@@ -311,11 +427,15 @@ func TestOnComplete(t *testing.T) {
 		authResp context.AuthResponse
 
 		// verify
-		localReply            *localReplyData
-		increaseOkCounter     bool
-		increaseErrCounter    bool
-		increaseDeniedCounter bool
-		filterState           map[string]string
+		localReply              *localReplyData
+		increaseOkCounter       bool
+		increaseErrCounter      bool
+		increaseDeniedCounter   bool
+		increaseReplayedCounter bool
+		failureModeAllow        bool
+		dynamicCounters         []string
+		filterState             map[string]string
+		dynamicMetadata         *structpb.Struct
 	}{
 		{
 			name: "verify successfully",
@@ -330,9 +450,13 @@ func TestOnComplete(t *testing.T) {
 
 			// HeadersToRemove, HeadersToSet and HeadersToAppend will be remove, set and append to headers to upstream respectly
 			increaseOkCounter: true,
+			dynamicCounters:   []string{"route.my_route.auth_ok"},
 
 			// hardcode keys to prevent regressions in target environment
 			filterState: map[string]string{"egodemo.security.ctx.session.state1": "val1"},
+			dynamicMetadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+				"state1": {Kind: &structpb.Value_StringValue{StringValue: "val1"}},
+			}},
 		},
 
 		{
@@ -349,10 +473,12 @@ func TestOnComplete(t *testing.T) {
 
 			localReply: &localReplyData{
 				StatusCode: 401,
-				Header:     map[string]string{"header-to-set": "val1"},
+				Header:     map[string]string{"header-to-set": "val1", "header-to-append": "val2"},
 				Body:       "this is an error",
+				Details:    "security_error",
 			},
 			increaseErrCounter: true,
+			dynamicCounters:    []string{"route.my_route.auth_error"},
 		},
 
 		{
@@ -369,10 +495,66 @@ func TestOnComplete(t *testing.T) {
 
 			localReply: &localReplyData{
 				StatusCode: 500,
-				Header:     map[string]string{"header-to-set": "val1"},
+				Header:     map[string]string{"header-to-set": "val1", "header-to-append": "val2"},
 				Body:       "this is an denied error",
+				Details:    "security_denied",
 			},
 			increaseDeniedCounter: true,
+			dynamicCounters:       []string{"route.my_route.auth_denied", "denied.unspecified"},
+		},
+
+		{
+			name: "verify with structured denied response",
+
+			authResp: context.AuthResponse{
+				StatusCode:          403,
+				Status:              context.AuthDenied,
+				BodyTemplate:        `{"code":{{.StatusCode}},"reason":{{json .DenialReason}},"details":{{json .ResponseCodeDetails}}}`,
+				HeadersToSet:        map[string]string{"header": "val1"},
+				HeadersToAppend:     map[string]string{"header": "val2"},
+				DenialReason:        "expired",
+				ResponseCodeDetails: "my_verifier_expired",
+			},
+
+			localReply: &localReplyData{
+				StatusCode: 403,
+				Header:     map[string]string{"header": "val1,val2", "content-type": "application/json"},
+				Body:       `{"code":403,"reason":"expired","details":"my_verifier_expired"}`,
+				Details:    "my_verifier_expired",
+			},
+			increaseDeniedCounter: true,
+			dynamicCounters:       []string{"route.my_route.auth_denied", "denied.expired"},
+		},
+
+		{
+			name: "verify with replayed response",
+
+			authResp: context.AuthResponseReplayed(),
+
+			localReply: &localReplyData{
+				StatusCode: 401,
+				Header:     map[string]string{},
+				Body:       "replayed request",
+				Details:    "security_denied{replayed}",
+			},
+			increaseDeniedCounter:   true,
+			increaseReplayedCounter: true,
+			dynamicCounters:         []string{"route.my_route.auth_denied", "denied.replayed"},
+		},
+
+		{
+			name: "verify with error response in failure mode allow",
+
+			authResp: context.AuthResponseError(),
+
+			// the request is marked and continues
+			failureModeAllow:   true,
+			increaseErrCounter: true,
+			dynamicCounters:    []string{"route.my_route.auth_error"},
+			filterState:        map[string]string{"egodemo.security.ctx.session.failure_mode_allowed": "true"},
+			dynamicMetadata: &structpb.Struct{Fields: map[string]*structpb.Value{
+				"failure_mode_allowed": {Kind: &structpb.Value_StringValue{StringValue: "true"}},
+			}},
 		},
 	}
 	for _, tc := range tcs {
@@ -384,6 +566,7 @@ func TestOnComplete(t *testing.T) {
 			secretProvider.On("Secret").Return(volatile.String("zzz"))
 
 			native.On("Log", mock.Anything, mock.Anything)
+			native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
 
 			decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
 			native.On("DecoderCallbacks").Return(decoderCallbacks)
@@ -396,16 +579,29 @@ func TestOnComplete(t *testing.T) {
 			authOkStats := &envoymocks.Counter{}
 			authDeniedStats := &envoymocks.Counter{}
 			authErrorStats := &envoymocks.Counter{}
+			authReplayedStats := &envoymocks.Counter{}
+			authFailureModeAllowedStats := &envoymocks.Counter{}
+			verificationsInFlight := &envoymocks.Gauge{}
+			verificationsInFlight.On("Dec")
+
+			dynamicCounter := &envoymocks.Counter{}
+			scope := &envoymocks.Scope{}
+			scope.On("CounterFromStatName", mock.Anything).Return(dynamicCounter)
+			dynamicCounter.On("Inc")
 
 			config := &securityConfig{
 				verifiers: map[string]verifier.Verifier{
 					"my_verifier": provider,
 				},
 				stats: securityStats{
-					authOK:     authOkStats,
-					authDenied: authDeniedStats,
-					authError:  authErrorStats,
+					authOK:                 authOkStats,
+					authDenied:             authDeniedStats,
+					authError:              authErrorStats,
+					authReplayed:           authReplayedStats,
+					authFailureModeAllowed: authFailureModeAllowedStats,
+					verificationsInFlight:  verificationsInFlight,
 				},
+				dynamicStats: newDynamicCounters(scope),
 			}
 
 			// set-up headermap
@@ -413,9 +609,13 @@ func TestOnComplete(t *testing.T) {
 
 			route := &envoymocks.Route{}
 			decoderCallbacks.On("Route").Return(route)
+			routeEntry := &envoymocks.RouteEntry{}
+			route.On("RouteEntry").Return(routeEntry)
+			routeEntry.On("RouteName").Return(volatile.String("my_route"), nil)
 
 			native.On("ResolveMostSpecificPerGoFilterConfig", FilterID, route).Return(pb.Requirement{
-				RequiresType: &pb.Requirement_ProviderName{ProviderName: "my_verifier"},
+				RequiresType:     &pb.Requirement_ProviderName{ProviderName: "my_verifier"},
+				FailureModeAllow: tc.failureModeAllow,
 			})
 
 			wg := sync.WaitGroup{}
@@ -441,6 +641,14 @@ func TestOnComplete(t *testing.T) {
 				authDeniedStats.On("Inc")
 			}
 
+			if tc.increaseReplayedCounter {
+				authReplayedStats.On("Inc")
+			}
+
+			if tc.failureModeAllow {
+				authFailureModeAllowedStats.On("Inc")
+			}
+
 			provider.On("WithBody").Return(true)
 
 			filter := newSecurity(native, config)
@@ -454,12 +662,24 @@ func TestOnComplete(t *testing.T) {
 
 			// set-up header map
 			filterState := &envoymocks.FilterState{}
+			dynamicMetadata := &envoymocks.DynamicMetadata{}
 			if tc.localReply != nil {
-				decoderCallbacks.On("SendLocalReply", tc.localReply.StatusCode, tc.localReply.Body, tc.localReply.Header, mock.Anything)
+				decoderCallbacks.On("SendLocalReply", tc.localReply.StatusCode, tc.localReply.Body, tc.localReply.Header, tc.localReply.Details)
 			} else {
-				headerMap.On("Remove", "header-to-remove")
-				headerMap.On("SetCopy", "header-to-set", "val1")
-				headerMap.On("AppendCopy", "header-to-append", "val2")
+				expected := tc.authResp
+				if tc.failureModeAllow {
+					expected = failureModeAllowedResponse()
+				}
+				headerMap.On("Remove", FailureModeAllowedHeader)
+				for k := range expected.HeadersToRemove {
+					headerMap.On("Remove", k)
+				}
+				for k, v := range expected.HeadersToSet {
+					headerMap.On("SetCopy", k, v)
+				}
+				for k, v := range expected.HeadersToAppend {
+					headerMap.On("AppendCopy", k, v)
+				}
 				streamInfo := &envoymocks.StreamInfo{}
 				decoderCallbacks.On("StreamInfo").Return(streamInfo)
 
@@ -469,6 +689,13 @@ func TestOnComplete(t *testing.T) {
 					filterState.On("SetData", k, v, mock.Anything, mock.Anything)
 				}
 
+				streamInfo.On("DynamicMetadata").Return(dynamicMetadata)
+				if tc.dynamicMetadata != nil {
+					dynamicMetadata.On("Set", "egodemo.security", mock.MatchedBy(func(value *structpb.Struct) bool {
+						return proto.Equal(tc.dynamicMetadata, value)
+					}))
+				}
+
 			}
 
 			callback := filter.(context.Callbacks)
@@ -482,14 +709,109 @@ func TestOnComplete(t *testing.T) {
 			authErrorStats.AssertExpectations(t)
 			authOkStats.AssertExpectations(t)
 			authDeniedStats.AssertExpectations(t)
+			authReplayedStats.AssertExpectations(t)
+			authFailureModeAllowedStats.AssertExpectations(t)
+			verificationsInFlight.AssertExpectations(t)
+			scope.AssertExpectations(t)
+			for _, name := range tc.dynamicCounters {
+				scope.AssertCalled(t, "CounterFromStatName", name)
+			}
 
 			headerMap.AssertExpectations(t)
 			filterState.AssertExpectations(t)
+			dynamicMetadata.AssertExpectations(t)
 
 			if tc.localReply != nil {
-				decoderCallbacks.AssertCalled(t, "SendLocalReply", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				decoderCallbacks.AssertCalled(t, "SendLocalReply", tc.localReply.StatusCode, tc.localReply.Body, tc.localReply.Header, tc.localReply.Details)
 			}
 		})
 	}
 
 }
+
+func TestOnCompleteRequiresAllDeniedInFailureModeAllow(t *testing.T) {
+	native := &envoymocks.GoHttpFilter{}
+	native.On("Pin")
+	native.On("Unpin")
+
+	secretProvider := &envoymocks.GenericSecretConfigProvider{}
+	native.On("GenericSecretProvider").Return((secretProvider))
+	secretProvider.On("Secret").Return(volatile.String("zzz"))
+
+	native.On("Log", mock.Anything, mock.Anything)
+	native.On("AsyncClient").Return(&envoymocks.AsyncClient{})
+
+	decoderCallbacks := &envoymocks.DecoderFilterCallbacks{}
+	native.On("DecoderCallbacks").Return(decoderCallbacks)
+	decoderCallbacks.On("ActiveSpan").Return(&envoymocks.Span{})
+
+	route := &envoymocks.Route{}
+	decoderCallbacks.On("Route").Return(route)
+	routeEntry := &envoymocks.RouteEntry{}
+	route.On("RouteEntry").Return(routeEntry)
+	routeEntry.On("RouteName").Return(volatile.String("my_route"), nil)
+
+	native.On("ResolveMostSpecificPerGoFilterConfig", FilterID, route).Return(pb.Requirement{
+		RequiresType: &pb.Requirement_RequiresAll{RequiresAll: &pb.RequirementAndList{Requirements: []*pb.Requirement{
+			{RequiresType: &pb.Requirement_ProviderName{ProviderName: "failing"}},
+			{RequiresType: &pb.Requirement_ProviderName{ProviderName: "denying"}},
+		}}},
+		FailureModeAllow: true,
+	})
+
+	// the error comes first
+	failed := make(chan struct{})
+	failing := &verifiermocks.Verifier{}
+	failing.On("WithBody").Return(false)
+	failing.On("Verify", mock.Anything).Run(func(args mock.Arguments) {
+		args[0].(context.RequestContext).Callbacks().OnComplete(context.AuthResponseError())
+		close(failed)
+	})
+	denying := &verifiermocks.Verifier{}
+	denying.On("WithBody").Return(false)
+	denying.On("Verify", mock.Anything).Run(func(args mock.Arguments) {
+		<-failed
+		args[0].(context.RequestContext).Callbacks().OnComplete(context.AuthResponseUnauthorized())
+	})
+
+	authDeniedStats := &envoymocks.Counter{}
+	authDeniedStats.On("Inc")
+	verificationsInFlight := &envoymocks.Gauge{}
+	verificationsInFlight.On("Inc")
+	verificationsInFlight.On("Dec")
+	dynamicCounter := &envoymocks.Counter{}
+	dynamicCounter.On("Inc")
+	scope := &envoymocks.Scope{}
+	scope.On("CounterFromStatName", mock.Anything).Return(dynamicCounter)
+
+	config := &securityConfig{
+		verifiers: map[string]verifier.Verifier{
+			"failing": failing,
+			"denying": denying,
+		},
+		stats: securityStats{
+			authDenied:            authDeniedStats,
+			verificationsInFlight: verificationsInFlight,
+		},
+		dynamicStats: newDynamicCounters(scope),
+	}
+
+	filter := newSecurity(native, config)
+	posted := make(chan struct{})
+	native.On("Post", authPost).Run(func(args mock.Arguments) {
+		filter.OnPost(authPost)
+		close(posted)
+	})
+	decoderCallbacks.On("SendLocalReply", http.StatusUnauthorized, mock.Anything, mock.Anything, mock.Anything)
+
+	headerMap := &envoymocks.RequestHeaderMap{}
+	headerMap.On("Size").Return(uint64(0))
+	headerMap.On("Iterate", mock.Anything)
+	assert.Equal(t, headersstatus.StopAllIterationAndWatermark, filter.DecodeHeaders(headerMap, true))
+
+	<-posted
+	// the denial is replied rather than letting the request through
+	decoderCallbacks.AssertCalled(t, "SendLocalReply", http.StatusUnauthorized, mock.Anything, mock.Anything, mock.Anything)
+	authDeniedStats.AssertExpectations(t)
+	scope.AssertCalled(t, "CounterFromStatName", "route.my_route.auth_denied")
+}
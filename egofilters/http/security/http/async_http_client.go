@@ -0,0 +1,105 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+)
+
+type asyncHttpClient struct {
+	client  envoy.AsyncClient
+	cluster string
+	timeout time.Duration
+}
+
+type asyncResult struct {
+	response *envoy.AsyncResponse
+	err      error
+}
+
+// NewAsyncHttpClient returns a HttpClient sending requests to an Envoy cluster
+// rather than to the host of the request url. Do blocks until the response
+// arrives via OnPost, so it must only be called from pinned go-routines and
+// never from the dispatcher.
+func NewAsyncHttpClient(client envoy.AsyncClient, cluster string, timeout time.Duration) HttpClient {
+	return &asyncHttpClient{
+		client:  client,
+		cluster: cluster,
+		timeout: timeout,
+	}
+}
+
+func (c *asyncHttpClient) Do(req *http.Request) (*http.Response, error) {
+	request := &envoy.AsyncRequest{
+		Method:    req.Method,
+		Path:      req.URL.RequestURI(),
+		Authority: req.Host,
+		Headers:   make(map[string][]string, len(req.Header)),
+		Timeout:   c.timeout,
+	}
+	if "" == request.Authority {
+		request.Authority = req.URL.Host
+	}
+	for k, v := range req.Header {
+		request.Headers[strings.ToLower(k)] = v
+	}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = body
+		request.Headers["content-length"] = []string{strconv.Itoa(len(body))}
+	}
+
+	// buffered, so the callback never blocks the dispatcher even if we gave up
+	done := make(chan asyncResult, 1)
+	err := c.client.Send(c.cluster, request, func(response *envoy.AsyncResponse, err error) {
+		done <- asyncResult{response, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			return nil, result.err
+		}
+		return newHttpResponse(req, result.response), nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func newHttpResponse(req *http.Request, response *envoy.AsyncResponse) *http.Response {
+	header := make(http.Header, len(response.Headers))
+	for k, v := range response.Headers {
+		header[http.CanonicalHeaderKey(k)] = v
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(response.Body)),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}
+}
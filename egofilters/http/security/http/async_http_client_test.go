@@ -0,0 +1,116 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package http
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	egomocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+)
+
+func TestAsyncHttpClientDo(t *testing.T) {
+	tcs := []struct {
+		name     string
+		sendErr  error
+		response *envoy.AsyncResponse
+		err      error
+
+		expectedErr error
+	}{
+		{
+			name: "should convert the response",
+			response: &envoy.AsyncResponse{
+				StatusCode: http.StatusOK,
+				Headers:    map[string][]string{"content-type": {"application/json"}, "x-multi": {"1", "2"}},
+				Body:       []byte(`{"ok": true}`),
+			},
+		},
+		{
+			name:        "should return the error of Send",
+			sendErr:     errors.New("filter destroyed"),
+			expectedErr: errors.New("filter destroyed"),
+		},
+		{
+			name:        "should return the error of the request",
+			err:         errors.New("unknown cluster"),
+			expectedErr: errors.New("unknown cluster"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			asyncClient := &egomocks.AsyncClient{}
+			var actualReq *envoy.AsyncRequest
+			call := asyncClient.On("Send", "custom_auth", mock.AnythingOfType("*envoy.AsyncRequest"), mock.Anything).Return(tc.sendErr)
+			call.Run(func(args mock.Arguments) {
+				actualReq = args[1].(*envoy.AsyncRequest)
+				if tc.sendErr == nil {
+					// the callback is invoked later on, in the dispatcher context
+					go args[2].(func(*envoy.AsyncResponse, error))(tc.response, tc.err)
+				}
+			})
+
+			client := NewAsyncHttpClient(asyncClient, "custom_auth", time.Second)
+
+			req, _ := http.NewRequest(http.MethodPost, "http://custom-auth.example.com/verify?x=1", bytes.NewReader([]byte("body")))
+			req.Header.Set("X-Custom-Auth-Verb", "GET")
+
+			resp, err := client.Do(req)
+
+			asyncClient.AssertExpectations(t)
+			assert.Equal(t, &envoy.AsyncRequest{
+				Method:    http.MethodPost,
+				Path:      "/verify?x=1",
+				Authority: "custom-auth.example.com",
+				Headers: map[string][]string{
+					"x-custom-auth-verb": {"GET"},
+					"content-length":     {"4"},
+				},
+				Body:    []byte("body"),
+				Timeout: time.Second,
+			}, actualReq)
+
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+				assert.Nil(t, resp)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.response.StatusCode, resp.StatusCode)
+			assert.Equal(t, http.Header{"Content-Type": {"application/json"}, "X-Multi": {"1", "2"}}, resp.Header)
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, tc.response.Body, body)
+		})
+	}
+}
+
+func TestAsyncHttpClientDoCanceled(t *testing.T) {
+	asyncClient := &egomocks.AsyncClient{}
+	asyncClient.On("Send", "custom_auth", mock.Anything, mock.Anything).Return(nil)
+
+	client := NewAsyncHttpClient(asyncClient, "custom_auth", 0)
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://custom-auth.example.com", nil)
+
+	resp, err := client.Do(req)
+
+	assert.Equal(t, gocontext.Canceled, err)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,203 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/logger"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+)
+
+// ErrCircuitOpen is returned instead of calling a service deemed down.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker, as reported by its gauge.
+type CircuitState uint64
+
+const (
+	// CircuitClosed lets all calls through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails all calls.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probes through.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerOptions specifies when a CircuitBreaker opens and closes.
+type CircuitBreakerOptions struct {
+	// Number of consecutive failures opening the circuit.
+	FailureThreshold int
+	// How long the circuit stays open before probing the service.
+	OpenDuration time.Duration
+	// Number of concurrent probes when half-open. The circuit closes once
+	// they all succeeded, and opens again on the first failure.
+	HalfOpenProbes int
+}
+
+// CircuitBreaker fails calls to a service without sending them after too
+// many consecutive failures. Connection errors and 5xx responses are
+// failures. It is shared by all workers calling the service.
+type CircuitBreaker struct {
+	options        CircuitBreakerOptions
+	gauge          envoy.Gauge
+	log            logger.Logger
+	getCurrentTime func() time.Time
+
+	lock     sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// in flight and succeeded probes when half-open
+	probes    int
+	successes int
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker. State changes are set on
+// gauge, unless it is nil, and logged to log.
+func NewCircuitBreaker(options CircuitBreakerOptions, gauge envoy.Gauge, log logger.Logger) *CircuitBreaker {
+	if options.HalfOpenProbes < 1 {
+		options.HalfOpenProbes = 1
+	}
+	b := &CircuitBreaker{options: options, gauge: gauge, log: log, getCurrentTime: time.Now}
+	if nil != b.gauge {
+		b.gauge.Set(uint64(CircuitClosed))
+	}
+	return b
+}
+
+// State returns the current state.
+func (b *CircuitBreaker) State() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+// allow reports whether a call may be sent, and whether it's a probe.
+func (b *CircuitBreaker) allow() (allowed, probe bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if CircuitOpen == b.state && b.getCurrentTime().Sub(b.openedAt) >= b.options.OpenDuration {
+		b.probes, b.successes = 0, 0
+		b.setState(CircuitHalfOpen)
+	}
+	switch b.state {
+	case CircuitClosed:
+		return true, false
+	case CircuitHalfOpen:
+		if b.probes < b.options.HalfOpenProbes {
+			b.probes++
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// record counts the result of an allowed call. Results of calls canceled by
+// the caller count neither as success nor failure.
+func (b *CircuitBreaker) record(probe, success, canceled bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case CircuitClosed:
+		if canceled {
+			return
+		}
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.options.FailureThreshold {
+			b.open()
+		}
+
+	case CircuitHalfOpen:
+		// calls sent before the circuit opened are ignored
+		if !probe {
+			return
+		}
+		b.probes--
+		switch {
+		case canceled:
+		case success:
+			b.successes++
+			if b.successes >= b.options.HalfOpenProbes {
+				b.failures = 0
+				b.setState(CircuitClosed)
+			}
+		default:
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.getCurrentTime()
+	b.setState(CircuitOpen)
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	b.log.Warn("[CircuitBreaker] state changed", logger.Data{
+		"from": b.state.String(),
+		"to":   state.String(),
+	})
+	b.state = state
+	if nil != b.gauge {
+		b.gauge.Set(uint64(state))
+	}
+}
+
+type circuitBreakingHttpClient struct {
+	HttpClientWithCtx
+	breaker *CircuitBreaker
+}
+
+// NewCircuitBreakingHttpClient returns a HttpClientWithCtx failing calls with
+// ErrCircuitOpen while breaker is open.
+func NewCircuitBreakingHttpClient(client HttpClientWithCtx, breaker *CircuitBreaker) HttpClientWithCtx {
+	return &circuitBreakingHttpClient{HttpClientWithCtx: client, breaker: breaker}
+}
+
+func (c *circuitBreakingHttpClient) Do(req *http.Request) (*http.Response, error) {
+	return c.do(req, c.HttpClientWithCtx.Do)
+}
+
+func (c *circuitBreakingHttpClient) DoWithTracing(ctx context.Context, req *http.Request, spanName string) (*http.Response, error) {
+	return c.do(req, func(req *http.Request) (*http.Response, error) {
+		return c.HttpClientWithCtx.DoWithTracing(ctx, req, spanName)
+	})
+}
+
+func (c *circuitBreakingHttpClient) do(req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	allowed, probe := c.breaker.allow()
+	if !allowed {
+		return nil, ErrCircuitOpen
+	}
+	resp, err := do(req)
+	success := nil == err && resp.StatusCode < http.StatusInternalServerError
+	c.breaker.record(probe, success, nil != err && nil != req.Context().Err())
+	return resp, err
+}
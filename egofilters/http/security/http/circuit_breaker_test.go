@@ -0,0 +1,122 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package http
+
+import (
+	gocontext "context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/logger"
+	egomock "github.com/grab/ego/ego/test/go/mock"
+
+	egomocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+	"github.com/grab/ego/egofilters/mock/gen/http/security/http"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	gauge := &egomocks.Gauge{}
+	var states []CircuitState
+	gauge.On("Set", mock.Anything).Run(func(args mock.Arguments) {
+		states = append(states, CircuitState(args[0].(uint64)))
+	})
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{
+		FailureThreshold: 2,
+		OpenDuration:     time.Second,
+		HalfOpenProbes:   2,
+	}, gauge, logger.NewLogger("CircuitBreakerLogger", egomock.NativeLogger{}))
+	breaker.getCurrentTime = func() time.Time { return now }
+
+	call := func(success bool) bool {
+		allowed, probe := breaker.allow()
+		if allowed {
+			breaker.record(probe, success, false)
+		}
+		return allowed
+	}
+
+	// only consecutive failures open the circuit
+	assert.True(t, call(false))
+	assert.True(t, call(true))
+	assert.True(t, call(false))
+	assert.Equal(t, CircuitClosed, breaker.State())
+	assert.True(t, call(false))
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.False(t, call(true))
+
+	// half-open after the open duration, with limited probes
+	now = now.Add(time.Second)
+	allowed1, probe1 := breaker.allow()
+	allowed2, probe2 := breaker.allow()
+	allowed3, _ := breaker.allow()
+	assert.True(t, allowed1 && probe1 && allowed2 && probe2)
+	assert.False(t, allowed3)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+
+	// a failed probe opens the circuit again
+	breaker.record(probe1, true, false)
+	breaker.record(probe2, false, false)
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.False(t, call(true))
+
+	// canceled probes don't count
+	now = now.Add(time.Second)
+	allowed1, probe1 = breaker.allow()
+	require.True(t, allowed1)
+	breaker.record(probe1, false, true)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+
+	// the circuit closes once all probes succeeded
+	assert.True(t, call(true))
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	assert.True(t, call(true))
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	assert.Equal(t, []CircuitState{
+		CircuitClosed, CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed,
+	}, states)
+}
+
+func TestCircuitBreakingHttpClient(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 1, OpenDuration: time.Minute}, nil,
+		logger.NewLogger("CircuitBreakerLogger", egomock.NativeLogger{}))
+
+	client := &mocks.HttpClientWithCtx{}
+	ctx := &contextmocks.Context{}
+	wrapped := NewCircuitBreakingHttpClient(client, breaker)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	require.Nil(t, err)
+
+	// calls canceled by the caller aren't failures
+	canceledCtx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	canceledReq := req.WithContext(canceledCtx)
+	client.On("DoWithTracing", ctx, canceledReq, "span").Return(nil, gocontext.Canceled).Once()
+	_, err = wrapped.DoWithTracing(ctx, canceledReq, "span")
+	assert.Equal(t, gocontext.Canceled, err)
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	client.On("DoWithTracing", ctx, req, "span").Return(&http.Response{StatusCode: http.StatusBadGateway}, nil).Once()
+	resp, err := wrapped.DoWithTracing(ctx, req, "span")
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// the service isn't called while open
+	_, err = wrapped.DoWithTracing(ctx, req, "span")
+	assert.Equal(t, ErrCircuitOpen, err)
+	_, err = wrapped.Do(req)
+	assert.Equal(t, ErrCircuitOpen, err)
+	client.AssertNumberOfCalls(t, "DoWithTracing", 2)
+}
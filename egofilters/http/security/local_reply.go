@@ -0,0 +1,125 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package security
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+)
+
+// Response code details of local replies, unless set by the verifier.
+const (
+	responseCodeDetailsDenied          = "security_denied"
+	responseCodeDetailsError           = "security_error"
+	responseCodeDetailsRequestTooLarge = "security_request_too_large"
+)
+
+const (
+	contentTypeHeader = "content-type"
+	jsonContentType   = "application/json"
+)
+
+// bodyTemplates caches the parsed body templates of AuthResponses by text.
+var bodyTemplates sync.Map
+
+var bodyTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// localReply is the local reply to a request denied by a verifier or failed
+// with an error.
+type localReply struct {
+	statusCode int
+	body       string
+	headers    map[string]string
+	details    string
+}
+
+// newLocalReply returns the local reply to response. details are the response
+// code details if the verifier didn't set any. The body is empty if its
+// template fails, with the error returned.
+func newLocalReply(response context.AuthResponse, details string) (localReply, error) {
+	if "" == response.ResponseCodeDetails {
+		response.ResponseCodeDetails = details
+		if "" != response.DenialReason {
+			response.ResponseCodeDetails += "{" + response.DenialReason + "}"
+		}
+	}
+
+	reply := localReply{
+		statusCode: response.StatusCode,
+		body:       response.Body,
+		headers:    make(map[string]string, len(response.HeadersToSet)+len(response.HeadersToAppend)+1),
+		details:    response.ResponseCodeDetails,
+	}
+	// the maps of the response may be shared by a cache
+	for k, v := range response.HeadersToSet {
+		reply.headers[k] = v
+	}
+	for k, v := range response.HeadersToAppend {
+		if prev, ok := reply.headers[k]; ok {
+			v = prev + "," + v
+		}
+		reply.headers[k] = v
+	}
+
+	if "" != response.ContentType {
+		for k := range reply.headers {
+			if strings.EqualFold(k, contentTypeHeader) {
+				delete(reply.headers, k)
+			}
+		}
+		reply.headers[contentTypeHeader] = response.ContentType
+	}
+
+	if "" != response.BodyTemplate {
+		if !hasHeader(reply.headers, contentTypeHeader) {
+			reply.headers[contentTypeHeader] = jsonContentType
+		}
+		body, err := renderBodyTemplate(response)
+		if err != nil {
+			reply.body = ""
+			return reply, err
+		}
+		reply.body = body
+	}
+	return reply, nil
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderBodyTemplate(response context.AuthResponse) (string, error) {
+	var t *template.Template
+	if cached, ok := bodyTemplates.Load(response.BodyTemplate); ok {
+		t = cached.(*template.Template)
+	} else {
+		var err error
+		if t, err = template.New("body").Funcs(bodyTemplateFuncs).Parse(response.BodyTemplate); err != nil {
+			return "", err
+		}
+		bodyTemplates.Store(response.BodyTemplate, t)
+	}
+
+	var body strings.Builder
+	if err := t.Execute(&body, response); err != nil {
+		return "", err
+	}
+	return body.String(), nil
+}
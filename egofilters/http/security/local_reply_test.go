@@ -0,0 +1,124 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+)
+
+func TestNewLocalReply(t *testing.T) {
+	tcs := []struct {
+		name     string
+		response context.AuthResponse
+		reply    localReply
+		err      bool
+	}{
+		{
+			name:     "plain",
+			response: context.AuthResponseUnauthorized(),
+			reply: localReply{
+				statusCode: 401,
+				headers:    map[string]string{},
+				details:    "security_denied",
+			},
+		},
+		{
+			name: "denial reason",
+			response: context.AuthResponse{
+				StatusCode:   403,
+				Body:         "nope",
+				DenialReason: "expired",
+			},
+			reply: localReply{
+				statusCode: 403,
+				body:       "nope",
+				headers:    map[string]string{},
+				details:    "security_denied{expired}",
+			},
+		},
+		{
+			name: "content type replacing the header",
+			response: context.AuthResponse{
+				StatusCode:      403,
+				Body:            "<p>nope</p>",
+				ContentType:     "text/html",
+				HeadersToSet:    map[string]string{"Content-Type": "text/plain", "a": "1"},
+				HeadersToAppend: map[string]string{"a": "2", "b": "3"},
+			},
+			reply: localReply{
+				statusCode: 403,
+				body:       "<p>nope</p>",
+				headers:    map[string]string{"content-type": "text/html", "a": "1,2", "b": "3"},
+				details:    "security_denied",
+			},
+		},
+		{
+			name: "body template",
+			response: context.AuthResponse{
+				StatusCode:   401,
+				Body:         "ignored",
+				BodyTemplate: `{"error":{{json .Body}},"reason":{{json .DenialReason}}}`,
+				DenialReason: `"quoted"`,
+			},
+			reply: localReply{
+				statusCode: 401,
+				body:       `{"error":"ignored","reason":"\"quoted\""}`,
+				headers:    map[string]string{"content-type": "application/json"},
+				details:    `security_denied{"quoted"}`,
+			},
+		},
+		{
+			name: "body template with a content type header",
+			response: context.AuthResponse{
+				StatusCode:   401,
+				BodyTemplate: `{"code":{{.StatusCode}}}`,
+				HeadersToSet: map[string]string{"Content-Type": "application/problem+json"},
+			},
+			reply: localReply{
+				statusCode: 401,
+				body:       `{"code":401}`,
+				headers:    map[string]string{"Content-Type": "application/problem+json"},
+				details:    "security_denied",
+			},
+		},
+		{
+			name: "invalid body template",
+			response: context.AuthResponse{
+				StatusCode:          401,
+				Body:                "ignored",
+				BodyTemplate:        `{"code":{{.Unknown}}}`,
+				ResponseCodeDetails: "my_details",
+			},
+			reply: localReply{
+				statusCode: 401,
+				headers:    map[string]string{"content-type": "application/json"},
+				details:    "my_details",
+			},
+			err: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			headersToSet := make(map[string]string, len(tc.response.HeadersToSet))
+			for k, v := range tc.response.HeadersToSet {
+				headersToSet[k] = v
+			}
+
+			reply, err := newLocalReply(tc.response, responseCodeDetailsDenied)
+			assert.Equal(t, tc.err, err != nil)
+			assert.Equal(t, tc.reply, reply)
+			// the response is left as is
+			if nil != tc.response.HeadersToSet {
+				assert.Equal(t, headersToSet, tc.response.HeadersToSet)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package security
+
+import (
+	"sync"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/envoy/stats"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	"github.com/grab/ego/egofilters/http/security/verifier"
+)
+
+// dynamicCounters creates counters on first use, for names only known at
+// runtime like route names. It is shared by all workers.
+type dynamicCounters struct {
+	scope envoy.Scope
+
+	lock     sync.Mutex
+	counters map[string]envoy.Counter
+}
+
+func newDynamicCounters(scope envoy.Scope) *dynamicCounters {
+	return &dynamicCounters{scope: scope, counters: make(map[string]envoy.Counter)}
+}
+
+// inc increments the counter name, unless it can't be created.
+func (c *dynamicCounters) inc(name string) {
+	c.lock.Lock()
+	counter, ok := c.counters[name]
+	if !ok {
+		counter = c.scope.CounterFromStatName(name)
+		c.counters[name] = counter
+	}
+	c.lock.Unlock()
+
+	if counter != nil {
+		counter.Inc()
+	}
+}
+
+// createProviderStats creates the stats of the provider name.
+func createProviderStats(scope envoy.Scope, name string) (*verifier.ProviderStats, error) {
+	prefix := providerStatPrefix(name)
+	providerStats := &verifier.ProviderStats{
+		AuthOK:        scope.CounterFromStatName(prefix + "auth_ok"),
+		AuthDenied:    scope.CounterFromStatName(prefix + "auth_denied"),
+		AuthError:     scope.CounterFromStatName(prefix + "auth_error"),
+		VerifyLatency: scope.HistogramFromStatName(prefix+"verify_ms", stats.Milliseconds),
+		SignLatency:   scope.HistogramFromStatName(prefix+"sign_ms", stats.Milliseconds),
+	}
+	if providerStats.AuthOK == nil || providerStats.AuthDenied == nil || providerStats.AuthError == nil ||
+		providerStats.VerifyLatency == nil || providerStats.SignLatency == nil {
+		return nil, ErrCannotCreateStats
+	}
+	return providerStats, nil
+}
+
+// createCircuitStateGauge creates the gauge reporting the circuit breaker
+// state of the provider name.
+func createCircuitStateGauge(scope envoy.Scope, name string) (envoy.Gauge, error) {
+	gauge := scope.GaugeFromStatName(providerStatPrefix(name)+"circuit_state", stats.NeverImport)
+	if gauge == nil {
+		return nil, ErrCannotCreateStats
+	}
+	return gauge, nil
+}
+
+// createTokenCacheStats creates the counters of the token cache of the
+// provider name.
+func createTokenCacheStats(scope envoy.Scope, name string) (verifier.CacheStats, error) {
+	prefix := providerStatPrefix(name)
+	cacheStats := verifier.CacheStats{
+		Hits:      scope.CounterFromStatName(prefix + "token_cache_hit"),
+		Misses:    scope.CounterFromStatName(prefix + "token_cache_miss"),
+		Evictions: scope.CounterFromStatName(prefix + "token_cache_eviction"),
+	}
+	if cacheStats.Hits == nil || cacheStats.Misses == nil || cacheStats.Evictions == nil {
+		return verifier.CacheStats{}, ErrCannotCreateStats
+	}
+	return cacheStats, nil
+}
+
+func providerStatPrefix(name string) string {
+	return "provider." + name + "."
+}
+
+// routeStatName is the name of the counter of result on route.
+func routeStatName(route, result string) string {
+	return "route." + route + "." + result
+}
+
+// denialReasonStatName is the name of the counter of denied responses with
+// reason.
+func denialReasonStatName(response context.AuthResponse) string {
+	if "" == response.DenialReason {
+		return "denied.unspecified"
+	}
+	return "denied." + response.DenialReason
+}
@@ -0,0 +1,142 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/url"
+	"strings"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+)
+
+const (
+	defaultClientIDHeader = "x-client-id"
+	clientIDSessionKey    = "ClientID"
+	apiKeySuffixSeparator = "#"
+)
+
+// CreateApiKeyProvider ...
+func CreateApiKeyProvider(provider *pb.ApiKeyProvider) (*apiKeyProvider, error) {
+	v := &apiKeyProvider{
+		provider:       provider,
+		clientIDHeader: defaultClientIDHeader,
+	}
+	if "" != provider.ClientIdHeader {
+		v.clientIDHeader = provider.ClientIdHeader
+	}
+	return v, nil
+}
+
+type apiKeyProvider struct {
+	baseProvider
+	provider       *pb.ApiKeyProvider
+	clientIDHeader string
+}
+
+func (v *apiKeyProvider) Verify(ctx context.RequestContext) {
+	key := v.apiKey(ctx)
+	if "" == key {
+		ctx.Logger().Debug("[Verify] no API key.")
+		ctx.Callbacks().OnComplete(context.AuthResponseUnauthorized())
+		return
+	}
+
+	// the keys are read for each request, as SDS may have rotated them
+	client, ok := matchApiKey(key, ctx.GetSecretsWithPrefix(v.provider.KeySecretPrefix))
+	if !ok {
+		ctx.Logger().Debug("[Verify] unknown API key.")
+		resp := context.AuthResponseUnauthorized()
+		resp.DenialReason = context.DenialReasonInvalidApiKey
+		ctx.Callbacks().OnComplete(resp)
+		return
+	}
+
+	resp := context.AuthResponseOK()
+	resp.HeadersToRemove = map[string]struct{}{v.clientIDHeader: {}}
+	resp.HeadersToSet = map[string]string{v.clientIDHeader: client}
+	resp.FilterState = map[string]string{clientIDSessionKey: client}
+	v.removeApiKey(ctx, &resp)
+	ctx.Callbacks().OnComplete(resp)
+}
+
+// removeApiKey makes resp remove the key from the request, so that it isn't
+// sent upstream.
+func (v *apiKeyProvider) removeApiKey(ctx context.RequestContext, resp *context.AuthResponse) {
+	switch v.provider.GetKeySource().(type) {
+	case *pb.ApiKeyProvider_Header:
+		resp.HeadersToRemove[v.provider.GetHeader()] = struct{}{}
+	case *pb.ApiKeyProvider_QueryParam:
+		resp.HeadersToSet[":path"] = withoutQueryParam(ctx.Headers().Path().Copy(), v.provider.GetQueryParam())
+	}
+}
+
+// apiKey returns the key of the request, empty if there is none.
+func (v *apiKeyProvider) apiKey(ctx context.RequestContext) string {
+	switch v.provider.GetKeySource().(type) {
+	case *pb.ApiKeyProvider_Header:
+		return ctx.Headers().Get(v.provider.GetHeader()).Copy()
+	case *pb.ApiKeyProvider_QueryParam:
+		path := ctx.Headers().Path().Copy()
+		i := strings.IndexByte(path, '?')
+		if i < 0 {
+			return ""
+		}
+		query, err := url.ParseQuery(path[i+1:])
+		if err != nil {
+			return ""
+		}
+		return query.Get(v.provider.GetQueryParam())
+	}
+	return ""
+}
+
+// withoutQueryParam returns path without the query parameter name. The other
+// parameters are kept as they are.
+func withoutQueryParam(path, name string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	var kept []string
+	for _, param := range strings.Split(path[i+1:], "&") {
+		key := param
+		if j := strings.IndexByte(param, '='); j >= 0 {
+			key = param[:j]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil && unescaped == name {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	if 0 == len(kept) {
+		return path[:i]
+	}
+	return path[:i+1] + strings.Join(kept, "&")
+}
+
+// matchApiKey returns the client name of key, looked up in keys, which are
+// keyed by client name. Keys are compared in constant time, regardless of
+// their length and of which one matches.
+func matchApiKey(key string, keys map[string]string) (string, bool) {
+	hash := sha256.Sum256([]byte(key))
+	client, found := "", false
+	for name, candidate := range keys {
+		candidateHash := sha256.Sum256([]byte(candidate))
+		if 1 == subtle.ConstantTimeCompare(hash[:], candidateHash[:]) && "" != candidate {
+			client, found = name, true
+		}
+	}
+	if !found {
+		return "", false
+	}
+	if i := strings.Index(client, apiKeySuffixSeparator); i >= 0 {
+		client = client[:i]
+	}
+	return client, true
+}
@@ -0,0 +1,203 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/logger"
+	"github.com/grab/ego/ego/src/go/volatile"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+func TestApiKeyVerify(t *testing.T) {
+	keys := map[string]string{
+		"billing":   "key1",
+		"billing#2": "key2",
+		"reporting": "key3",
+		"disabled":  "",
+	}
+
+	tcs := []struct {
+		name         string
+		settings     pb.ApiKeyProvider
+		header       string
+		path         string
+		authResponse context.AuthResponse
+	}{
+		{
+			name:     "key in header",
+			settings: pb.ApiKeyProvider{KeySource: &pb.ApiKeyProvider_Header{Header: "x-api-key"}},
+			header:   "key3",
+			authResponse: context.AuthResponse{
+				Status:          context.AuthOK,
+				StatusCode:      200,
+				HeadersToRemove: map[string]struct{}{"x-client-id": {}, "x-api-key": {}},
+				HeadersToSet:    map[string]string{"x-client-id": "reporting"},
+				FilterState:     map[string]string{"ClientID": "reporting"},
+			},
+		},
+		{
+			name: "key in query parameter",
+			settings: pb.ApiKeyProvider{
+				KeySource:      &pb.ApiKeyProvider_QueryParam{QueryParam: "api_key"},
+				ClientIdHeader: "x-api-client",
+			},
+			path: "/path?a=b&api_key=key2",
+			authResponse: context.AuthResponse{
+				Status:          context.AuthOK,
+				StatusCode:      200,
+				HeadersToRemove: map[string]struct{}{"x-api-client": {}},
+				HeadersToSet:    map[string]string{"x-api-client": "billing", ":path": "/path?a=b"},
+				FilterState:     map[string]string{"ClientID": "billing"},
+			},
+		},
+		{
+			name:         "no key in header",
+			settings:     pb.ApiKeyProvider{KeySource: &pb.ApiKeyProvider_Header{Header: "x-api-key"}},
+			authResponse: context.AuthResponseUnauthorized(),
+		},
+		{
+			name:         "no query",
+			settings:     pb.ApiKeyProvider{KeySource: &pb.ApiKeyProvider_QueryParam{QueryParam: "api_key"}},
+			path:         "/path",
+			authResponse: context.AuthResponseUnauthorized(),
+		},
+		{
+			name:     "unknown key",
+			settings: pb.ApiKeyProvider{KeySource: &pb.ApiKeyProvider_Header{Header: "x-api-key"}},
+			header:   "key4",
+			authResponse: context.AuthResponse{
+				Status:       context.AuthDenied,
+				StatusCode:   401,
+				DenialReason: context.DenialReasonInvalidApiKey,
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := tc.settings
+			settings.KeySecretPrefix = "api-key/"
+			provider, err := CreateApiKeyProvider(&settings)
+			require.Nil(t, err)
+
+			ctx := &contextmocks.RequestContext{}
+			ctx.On("Logger").Return(logger.NewLogger("ApiKeyLogger", egomocks.NativeLogger{}))
+			ctx.On("GetSecretsWithPrefix", "api-key/").Return(keys).Maybe()
+
+			headerMap := &envoymocks.RequestHeaderMap{}
+			headerMap.On("Get", "x-api-key").Return(volatile.String(tc.header)).Maybe()
+			headerMap.On("Path").Return(volatile.String(tc.path)).Maybe()
+			ctx.On("Headers").Return(headerMap)
+
+			callbacks := &contextmocks.Callbacks{}
+			callbacks.On("OnComplete", mock.Anything)
+			ctx.On("Callbacks").Return(callbacks)
+
+			provider.Verify(ctx)
+
+			callbacks.AssertCalled(t, "OnComplete", tc.authResponse)
+		})
+	}
+}
+
+func TestApiKeyNotSentUpstream(t *testing.T) {
+	tcs := []struct {
+		name     string
+		settings pb.ApiKeyProvider
+		path     string
+		header   string
+	}{
+		{
+			name:     "key in header",
+			settings: pb.ApiKeyProvider{KeySource: &pb.ApiKeyProvider_Header{Header: "X-Api-Key"}},
+			path:     "/path?a=b",
+			header:   "key1",
+		},
+		{
+			name:     "key in query parameter",
+			settings: pb.ApiKeyProvider{KeySource: &pb.ApiKeyProvider_QueryParam{QueryParam: "api_key"}},
+			path:     "/path?api_key=key1&a=b",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := tc.settings
+			settings.KeySecretPrefix = "api-key/"
+			provider, err := CreateApiKeyProvider(&settings)
+			require.Nil(t, err)
+
+			headers := &requestHeaders{}
+			headers.AddCopy(":path", tc.path)
+			if "" != tc.header {
+				headers.AddCopy("x-api-key", tc.header)
+			}
+
+			ctx := &contextmocks.RequestContext{}
+			ctx.On("Logger").Return(logger.NewLogger("ApiKeyLogger", egomocks.NativeLogger{}))
+			ctx.On("GetSecretsWithPrefix", "api-key/").Return(map[string]string{"billing": "key1"})
+			ctx.On("Headers").Return(headers)
+			var authResp context.AuthResponse
+			callbacks := &contextmocks.Callbacks{}
+			callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+				authResp = args[0].(context.AuthResponse)
+			})
+			ctx.On("Callbacks").Return(callbacks)
+
+			provider.Verify(ctx)
+			require.Equal(t, context.AuthOK, authResp.Status)
+
+			// as the filter mutates the upstream request
+			for k := range authResp.HeadersToRemove {
+				headers.Remove(k)
+			}
+			for k, v := range authResp.HeadersToSet {
+				headers.SetCopy(k, v)
+			}
+			assert.Equal(t, volatile.String("/path?a=b"), headers.Path())
+			assert.Equal(t, volatile.String(""), headers.Get("x-api-key"))
+			assert.Equal(t, volatile.String("billing"), headers.Get("x-client-id"))
+		})
+	}
+}
+
+func TestWithoutQueryParam(t *testing.T) {
+	assert.Equal(t, "/path?a=b&c=d", withoutQueryParam("/path?a=b&api_key=key1&c=d", "api_key"))
+	assert.Equal(t, "/path?a=b%20c", withoutQueryParam("/path?api_key=key1&a=b%20c&api%5Fkey=key2", "api_key"))
+	assert.Equal(t, "/path", withoutQueryParam("/path?api_key=key1", "api_key"))
+	assert.Equal(t, "/path?api_key_2=x", withoutQueryParam("/path?api_key_2=x", "api_key"))
+	assert.Equal(t, "/path", withoutQueryParam("/path", "api_key"))
+}
+
+func TestMatchApiKey(t *testing.T) {
+	keys := map[string]string{"billing#old": "key1", "billing#new": "key2", "empty": ""}
+
+	client, ok := matchApiKey("key1", keys)
+	assert.True(t, ok)
+	assert.Equal(t, "billing", client)
+	client, ok = matchApiKey("key2", keys)
+	assert.True(t, ok)
+	assert.Equal(t, "billing", client)
+
+	_, ok = matchApiKey("key", keys)
+	assert.False(t, ok)
+	_, ok = matchApiKey("", keys)
+	assert.False(t, ok)
+	_, ok = matchApiKey("key1", nil)
+	assert.False(t, ok)
+}
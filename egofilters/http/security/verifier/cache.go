@@ -0,0 +1,194 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+)
+
+// CacheStats are the counters of a VerificationCache.
+type CacheStats struct {
+	Hits      envoy.Counter
+	Misses    envoy.Counter
+	Evictions envoy.Counter
+}
+
+// VerificationCache is a size bounded LRU cache of verification results. It
+// is shared by all workers.
+type VerificationCache struct {
+	maxEntries     int
+	positiveTTL    time.Duration
+	negativeTTL    time.Duration
+	stats          CacheStats
+	getCurrentTime getCurrentTimeOpt
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// most recently used first
+	lru *list.List
+}
+
+type cacheEntry struct {
+	key       string
+	response  context.AuthResponse
+	expiresAt time.Time
+}
+
+// NewVerificationCache returns a cache holding up to maxEntries results.
+// Passed and denied results are cached for positiveTTL and negativeTTL
+// respectively, errors are never cached. Results don't outlive the ExpiresAt
+// of their response.
+func NewVerificationCache(maxEntries int, positiveTTL, negativeTTL time.Duration, stats CacheStats) *VerificationCache {
+	return newVerificationCache(maxEntries, positiveTTL, negativeTTL, stats, getCurrentTime)
+}
+
+func newVerificationCache(
+	maxEntries int, positiveTTL, negativeTTL time.Duration, stats CacheStats, getCurrentTime getCurrentTimeOpt) *VerificationCache {
+	return &VerificationCache{
+		maxEntries:     maxEntries,
+		positiveTTL:    positiveTTL,
+		negativeTTL:    negativeTTL,
+		stats:          stats,
+		getCurrentTime: getCurrentTime,
+		entries:        make(map[string]*list.Element),
+		lru:            list.New(),
+	}
+}
+
+func (c *VerificationCache) get(key string) (context.AuthResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses.Inc()
+		return context.AuthResponse{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !c.getCurrentTime().Before(entry.expiresAt) {
+		c.remove(element)
+		c.stats.Misses.Inc()
+		return context.AuthResponse{}, false
+	}
+	c.lru.MoveToFront(element)
+	c.stats.Hits.Inc()
+	return entry.response, true
+}
+
+func (c *VerificationCache) put(key string, response context.AuthResponse) {
+	var ttl time.Duration
+	switch response.Status {
+	case context.AuthOK:
+		ttl = c.positiveTTL
+	case context.AuthDenied:
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	now := c.getCurrentTime()
+	expiresAt := now.Add(ttl)
+	if !response.ExpiresAt.IsZero() && response.ExpiresAt.Before(expiresAt) {
+		expiresAt = response.ExpiresAt
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := &cacheEntry{key: key, response: response, expiresAt: expiresAt}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions.Inc()
+	}
+}
+
+func (c *VerificationCache) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// NewCachingVerifier returns a Verifier caching the results of verifier
+// under the provider name. Verifiers not implementing Cacheable are
+// returned as they are.
+//
+// The returned Verifier doesn't stream the body, even if verifier does: the
+// key covers the whole body, so it is only known once all of it arrived, and
+// verifier needs the body again on a miss. The body is buffered instead.
+func NewCachingVerifier(name string, verifier Verifier, cache *VerificationCache) Verifier {
+	cacheable, ok := verifier.(Cacheable)
+	if !ok {
+		return verifier
+	}
+	return &cachingVerifier{name: name, verifier: verifier, cacheable: cacheable, cache: cache}
+}
+
+type cachingVerifier struct {
+	name      string
+	verifier  Verifier
+	cacheable Cacheable
+	cache     *VerificationCache
+}
+
+func (v *cachingVerifier) WithBody() bool {
+	return v.verifier.WithBody()
+}
+
+func (v *cachingVerifier) Verify(ctx context.RequestContext) {
+	// the body is read for the cache key and again by the verifier
+	var body []byte
+	reader := ctx.BodyReader()
+	if reader != nil {
+		var err error
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			ctx.Logger().Error("[Verify] can not read the body.", err)
+			ctx.Callbacks().OnComplete(context.AuthResponseError())
+			return
+		}
+	}
+	child := func(callbacks context.Callbacks) *childRequestContext {
+		c := &childRequestContext{RequestContext: ctx, callbacks: callbacks, goContext: ctx.GoContext()}
+		if reader != nil {
+			c.bodyReader = bytes.NewReader(body)
+		}
+		return c
+	}
+
+	hash := sha256.New()
+	if !v.cacheable.WriteCacheKey(child(ctx.Callbacks()), hash) {
+		v.verifier.Verify(child(ctx.Callbacks()))
+		return
+	}
+	key := v.name + "/" + string(hash.Sum(nil))
+
+	if response, ok := v.cache.get(key); ok {
+		ctx.Logger().Debug("[Verify] cached result.", v.name)
+		ctx.Callbacks().OnComplete(response)
+		return
+	}
+
+	v.verifier.Verify(child(callbacksFunc(func(response context.AuthResponse) {
+		v.cache.put(key, response)
+		ctx.Callbacks().OnComplete(response)
+	})))
+}
@@ -0,0 +1,204 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"bytes"
+	gocontext "context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/logger"
+	"github.com/grab/ego/ego/src/go/volatile"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+// cacheableFakeVerifier is a fakeVerifier keyed by the body.
+type cacheableFakeVerifier struct {
+	fakeVerifier
+	calls     int
+	cacheable bool
+}
+
+func (v *cacheableFakeVerifier) Verify(ctx context.RequestContext) {
+	v.calls++
+	v.fakeVerifier.Verify(ctx)
+}
+
+func (v *cacheableFakeVerifier) WithBody() bool {
+	return true
+}
+
+func (v *cacheableFakeVerifier) WriteCacheKey(ctx context.RequestContext, w io.Writer) bool {
+	io.Copy(w, ctx.BodyReader())
+	return v.cacheable
+}
+
+// streamingFakeVerifier is a cacheableFakeVerifier streaming the body.
+type streamingFakeVerifier struct {
+	cacheableFakeVerifier
+}
+
+func (v *streamingFakeVerifier) StreamsBody() bool {
+	return true
+}
+
+func newCacheStats() (CacheStats, *envoymocks.Counter, *envoymocks.Counter, *envoymocks.Counter) {
+	hits, misses, evictions := &envoymocks.Counter{}, &envoymocks.Counter{}, &envoymocks.Counter{}
+	for _, counter := range []*envoymocks.Counter{hits, misses, evictions} {
+		counter.On("Inc").Return()
+	}
+	return CacheStats{Hits: hits, Misses: misses, Evictions: evictions}, hits, misses, evictions
+}
+
+func verifyWithBody(v Verifier, body string) context.AuthResponse {
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("BodyReader").Return(bytes.NewReader([]byte(body)))
+	ctx.On("GoContext").Return(gocontext.Background())
+	ctx.On("Logger").Return(logger.NewLogger("CacheLogger", egomocks.NativeLogger{}))
+
+	headerMap := &envoymocks.RequestHeaderMap{}
+	headerMap.On("Authorization").Return(volatile.String(""))
+	ctx.On("Headers").Return(headerMap)
+
+	var authResp context.AuthResponse
+	callbacks := &contextmocks.Callbacks{}
+	callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+		authResp = args[0].(context.AuthResponse)
+	})
+	ctx.On("Callbacks").Return(callbacks)
+
+	v.Verify(ctx)
+	return authResp
+}
+
+func TestCachingVerifier(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	stats, hits, misses, evictions := newCacheStats()
+	cache := newVerificationCache(2, time.Minute, 10*time.Second, stats, func() time.Time { return now })
+
+	passing := &cacheableFakeVerifier{fakeVerifier: fakeVerifier{response: authResponseOK("x-passing", "passing")}, cacheable: true}
+	denying := &cacheableFakeVerifier{fakeVerifier: fakeVerifier{response: context.AuthResponseUnauthorized()}, cacheable: true}
+	failing := &cacheableFakeVerifier{fakeVerifier: fakeVerifier{response: context.AuthResponseError()}, cacheable: true}
+	passingVerifier := NewCachingVerifier("passing", passing, cache)
+	denyingVerifier := NewCachingVerifier("denying", denying, cache)
+	failingVerifier := NewCachingVerifier("failing", failing, cache)
+	assert.True(t, passingVerifier.WithBody())
+
+	// the verifier still gets the body after the cache key was computed
+	assert.Equal(t, authResponseOK("x-passing", "passing"), verifyWithBody(passingVerifier, "body1"))
+	assert.Equal(t, "body1", string(passing.body))
+	assert.Equal(t, authResponseOK("x-passing", "passing"), verifyWithBody(passingVerifier, "body1"))
+	assert.Equal(t, 1, passing.calls)
+
+	// keys differ by inputs and provider
+	verifyWithBody(passingVerifier, "body2")
+	assert.Equal(t, 2, passing.calls)
+	assert.Equal(t, context.AuthResponseUnauthorized(), verifyWithBody(denyingVerifier, "body1"))
+	assert.Equal(t, context.AuthResponseUnauthorized(), verifyWithBody(denyingVerifier, "body1"))
+	assert.Equal(t, 1, denying.calls)
+
+	// the least recently used entry was evicted
+	verifyWithBody(passingVerifier, "body2")
+	verifyWithBody(passingVerifier, "body1")
+	assert.Equal(t, 3, passing.calls)
+	evictions.AssertNumberOfCalls(t, "Inc", 2)
+
+	// errors are not cached
+	verifyWithBody(failingVerifier, "body1")
+	verifyWithBody(failingVerifier, "body1")
+	assert.Equal(t, 2, failing.calls)
+
+	// denied results expire first
+	verifyWithBody(denyingVerifier, "body1")
+	assert.Equal(t, 2, denying.calls)
+	now = now.Add(10 * time.Second)
+	verifyWithBody(denyingVerifier, "body1")
+	verifyWithBody(passingVerifier, "body1")
+	assert.Equal(t, 3, denying.calls)
+	assert.Equal(t, 3, passing.calls)
+
+	hits.AssertNumberOfCalls(t, "Inc", 4)
+	misses.AssertNumberOfCalls(t, "Inc", 8)
+	evictions.AssertNumberOfCalls(t, "Inc", 3)
+}
+
+func TestCachingVerifierOptOut(t *testing.T) {
+	stats, _, _, _ := newCacheStats()
+	cache := NewVerificationCache(10, time.Minute, time.Minute, stats)
+
+	notCacheable := &cacheableFakeVerifier{fakeVerifier: fakeVerifier{response: context.AuthResponseOK()}}
+	v := NewCachingVerifier("not_cacheable", notCacheable, cache)
+	verifyWithBody(v, "body1")
+	verifyWithBody(v, "body1")
+	assert.Equal(t, 2, notCacheable.calls)
+	assert.Equal(t, "body1", string(notCacheable.body))
+
+	// verifiers not implementing Cacheable aren't wrapped
+	fake := &fakeVerifier{}
+	assert.Same(t, fake, NewCachingVerifier("fake", fake, cache))
+}
+
+func TestCachingVerifierBuffersStreamedBody(t *testing.T) {
+	stats, hits, _, _ := newCacheStats()
+	cache := NewVerificationCache(10, time.Minute, time.Minute, stats)
+
+	streaming := &streamingFakeVerifier{cacheableFakeVerifier{fakeVerifier: fakeVerifier{response: context.AuthResponseOK()}, cacheable: true}}
+	require.True(t, StreamsBody(streaming))
+	v := NewCachingVerifier("streaming", streaming, cache)
+	assert.False(t, StreamsBody(v))
+
+	// the key covers the whole body
+	verifyWithBody(v, "body1")
+	assert.Equal(t, "body1", string(streaming.body))
+	verifyWithBody(v, "body2")
+	verifyWithBody(v, "body1")
+	assert.Equal(t, 2, streaming.calls)
+	hits.AssertNumberOfCalls(t, "Inc", 1)
+}
+
+func TestCachingVerifierExpiringCredentials(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	now := jwtTestNow
+	provider, err := createJwtProvider(&pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_LocalJwks{LocalJwks: keys.jwks(t)},
+	}, nil, func() time.Time { return now })
+	require.Nil(t, err)
+
+	stats, hits, _, _ := newCacheStats()
+	cache := newVerificationCache(10, time.Hour, time.Minute, stats, func() time.Time { return now })
+	v := NewCachingVerifier("jwt", provider, cache)
+
+	verify := func(exp time.Time) context.AuthResponse {
+		token := signJwt(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{"exp": exp.Unix()}, keys.rsa)
+		ctx, authResp := newBearerTestContext("Bearer " + token)
+		ctx.On("BodyReader").Return(nil)
+		v.Verify(ctx)
+		return *authResp
+	}
+
+	// the token expires before the positive TTL
+	exp := now.Add(time.Minute)
+	assert.Equal(t, context.AuthOK, verify(exp).Status)
+	now = now.Add(time.Minute)
+	assert.Equal(t, context.AuthOK, verify(exp).Status)
+	hits.AssertNumberOfCalls(t, "Inc", 1)
+	// and the clock skew
+	now = now.Add(defaultJwtClockSkew)
+	assert.Equal(t, context.AuthDenied, verify(exp).Status)
+	hits.AssertNumberOfCalls(t, "Inc", 1)
+}
@@ -0,0 +1,143 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+)
+
+const (
+	defaultIdentityHeader  = "x-peer-identity"
+	peerIdentitySessionKey = "PeerIdentity"
+)
+
+var (
+	// ErrNoAllowedIdentity ...
+	ErrNoAllowedIdentity = errors.New("no allowed client certificate identity")
+	// ErrInvalidURISanPattern ...
+	ErrInvalidURISanPattern = errors.New(`"**" is only allowed as the last segment of URI SAN patterns`)
+)
+
+// CreateClientCertificateProvider ...
+func CreateClientCertificateProvider(provider *pb.ClientCertificateProvider) (*clientCertificateProvider, error) {
+	if 0 == len(provider.AllowedUriSans) && 0 == len(provider.AllowedDnsSans) && 0 == len(provider.AllowedSubjects) {
+		return nil, ErrNoAllowedIdentity
+	}
+	for _, pattern := range provider.AllowedUriSans {
+		if i := strings.Index(pattern, "/**"); i >= 0 && i != len(pattern)-len("/**") {
+			return nil, ErrInvalidURISanPattern
+		}
+	}
+	v := &clientCertificateProvider{
+		provider:       provider,
+		identityHeader: defaultIdentityHeader,
+	}
+	if "" != provider.IdentityHeader {
+		v.identityHeader = provider.IdentityHeader
+	}
+	return v, nil
+}
+
+type clientCertificateProvider struct {
+	baseProvider
+	provider       *pb.ClientCertificateProvider
+	identityHeader string
+}
+
+func (v *clientCertificateProvider) Verify(ctx context.RequestContext) {
+	ssl := ctx.StreamInfo().DownstreamSslConnection()
+	if nil == ssl || !ssl.PeerCertificatePresented {
+		ctx.Logger().Debug("[Verify] no client certificate.")
+		resp := context.AuthResponseUnauthorized()
+		resp.DenialReason = context.DenialReasonNoClientCertificate
+		ctx.Callbacks().OnComplete(resp)
+		return
+	}
+
+	identity, ok := v.identity(ssl)
+	if !ok {
+		ctx.Logger().Debug("[Verify] client certificate not allowed.", ssl.SubjectPeerCertificate)
+		resp := context.AuthResponseDenied(http.StatusForbidden)
+		resp.DenialReason = context.DenialReasonIdentityNotAllowed
+		ctx.Callbacks().OnComplete(resp)
+		return
+	}
+
+	resp := context.AuthResponseOK()
+	resp.HeadersToRemove = map[string]struct{}{v.identityHeader: {}}
+	resp.HeadersToSet = map[string]string{v.identityHeader: identity}
+	resp.FilterState = map[string]string{peerIdentitySessionKey: identity}
+	ctx.Callbacks().OnComplete(resp)
+}
+
+// identity returns the first allowed URI SAN, DNS SAN or subject of the peer
+// certificate.
+func (v *clientCertificateProvider) identity(ssl *envoy.SslConnectionInfo) (string, bool) {
+	for _, san := range ssl.UriSanPeerCertificate {
+		for _, pattern := range v.provider.AllowedUriSans {
+			if matchURISan(pattern, san) {
+				return san, true
+			}
+		}
+	}
+	for _, san := range ssl.DnsSansPeerCertificate {
+		for _, pattern := range v.provider.AllowedDnsSans {
+			if matchDNSSan(pattern, san) {
+				return san, true
+			}
+		}
+	}
+	if "" != ssl.SubjectPeerCertificate {
+		for _, subject := range v.provider.AllowedSubjects {
+			if subject == ssl.SubjectPeerCertificate {
+				return subject, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchURISan reports whether san matches pattern, in which a "*" segment
+// matches any non empty segment, and a trailing "**" segment one or more.
+func matchURISan(pattern, san string) bool {
+	patternSegments := strings.Split(pattern, "/")
+	segments := strings.Split(san, "/")
+	for i, p := range patternSegments {
+		if i >= len(segments) {
+			return false
+		}
+		if "**" == p && i == len(patternSegments)-1 {
+			return "" != segments[i]
+		}
+		if "*" == p {
+			if "" == segments[i] {
+				return false
+			}
+			continue
+		}
+		if p != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(patternSegments)
+}
+
+// matchDNSSan reports whether san matches pattern, in which a leading "*."
+// matches a single label. Wildcard SANs only match themselves.
+func matchDNSSan(pattern, san string) bool {
+	if strings.HasPrefix(pattern, "*.") && !strings.HasPrefix(san, "*.") {
+		i := strings.IndexByte(san, '.')
+		return i > 0 && strings.EqualFold(pattern[1:], san[i:])
+	}
+	return strings.EqualFold(pattern, san)
+}
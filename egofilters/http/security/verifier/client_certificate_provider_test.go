@@ -0,0 +1,198 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/logger"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+func TestClientCertificateVerify(t *testing.T) {
+	settings := &pb.ClientCertificateProvider{
+		AllowedUriSans:  []string{"spiffe://mesh.example.com/ns/*/sa/billing", "spiffe://partner.example.com/**"},
+		AllowedDnsSans:  []string{"*.reporting.svc.cluster.local"},
+		AllowedSubjects: []string{"CN=legacy,O=Example"},
+	}
+
+	identityResponse := func(identity string) context.AuthResponse {
+		return context.AuthResponse{
+			Status:          context.AuthOK,
+			StatusCode:      200,
+			HeadersToRemove: map[string]struct{}{"x-peer-identity": {}},
+			HeadersToSet:    map[string]string{"x-peer-identity": identity},
+			FilterState:     map[string]string{"PeerIdentity": identity},
+		}
+	}
+
+	tcs := []struct {
+		name         string
+		ssl          *envoy.SslConnectionInfo
+		authResponse context.AuthResponse
+	}{
+		{
+			name: "SPIFFE ID",
+			ssl: &envoy.SslConnectionInfo{
+				PeerCertificatePresented: true,
+				UriSanPeerCertificate:    []string{"https://billing.example.com", "spiffe://mesh.example.com/ns/prod/sa/billing"},
+				DnsSansPeerCertificate:   []string{"billing.reporting.svc.cluster.local"},
+				SubjectPeerCertificate:   "CN=legacy,O=Example",
+			},
+			authResponse: identityResponse("spiffe://mesh.example.com/ns/prod/sa/billing"),
+		},
+		{
+			name: "trust domain",
+			ssl: &envoy.SslConnectionInfo{
+				PeerCertificatePresented: true,
+				UriSanPeerCertificate:    []string{"spiffe://partner.example.com/payments"},
+			},
+			authResponse: identityResponse("spiffe://partner.example.com/payments"),
+		},
+		{
+			name: "DNS SAN",
+			ssl: &envoy.SslConnectionInfo{
+				PeerCertificatePresented: true,
+				UriSanPeerCertificate:    []string{"spiffe://mesh.example.com/ns/prod/sa/other"},
+				DnsSansPeerCertificate:   []string{"api.reporting.svc.cluster.local"},
+			},
+			authResponse: identityResponse("api.reporting.svc.cluster.local"),
+		},
+		{
+			name: "subject",
+			ssl: &envoy.SslConnectionInfo{
+				PeerCertificatePresented: true,
+				SubjectPeerCertificate:   "CN=legacy,O=Example",
+			},
+			authResponse: identityResponse("CN=legacy,O=Example"),
+		},
+		{
+			name: "no TLS",
+			authResponse: context.AuthResponse{
+				Status:       context.AuthDenied,
+				StatusCode:   401,
+				DenialReason: context.DenialReasonNoClientCertificate,
+			},
+		},
+		{
+			name: "no client certificate",
+			ssl:  &envoy.SslConnectionInfo{},
+			authResponse: context.AuthResponse{
+				Status:       context.AuthDenied,
+				StatusCode:   401,
+				DenialReason: context.DenialReasonNoClientCertificate,
+			},
+		},
+		{
+			name: "identity not allowed",
+			ssl: &envoy.SslConnectionInfo{
+				PeerCertificatePresented: true,
+				UriSanPeerCertificate:    []string{"spiffe://mesh.example.com/ns/prod/sa/other"},
+				DnsSansPeerCertificate:   []string{"a.b.reporting.svc.cluster.local"},
+				SubjectPeerCertificate:   "CN=legacy,O=Other",
+			},
+			authResponse: context.AuthResponse{
+				Status:       context.AuthDenied,
+				StatusCode:   403,
+				DenialReason: context.DenialReasonIdentityNotAllowed,
+			},
+		},
+	}
+
+	provider, err := CreateClientCertificateProvider(settings)
+	require.Nil(t, err)
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &contextmocks.RequestContext{}
+			ctx.On("Logger").Return(logger.NewLogger("ClientCertificateLogger", egomocks.NativeLogger{}))
+
+			streamInfo := &envoymocks.StreamInfo{}
+			streamInfo.On("DownstreamSslConnection").Return(tc.ssl)
+			ctx.On("StreamInfo").Return(streamInfo)
+
+			callbacks := &contextmocks.Callbacks{}
+			callbacks.On("OnComplete", mock.Anything)
+			ctx.On("Callbacks").Return(callbacks)
+
+			provider.Verify(ctx)
+
+			callbacks.AssertCalled(t, "OnComplete", tc.authResponse)
+		})
+	}
+}
+
+func TestCreateClientCertificateProvider(t *testing.T) {
+	provider, err := CreateClientCertificateProvider(&pb.ClientCertificateProvider{
+		AllowedSubjects: []string{"CN=legacy"},
+		IdentityHeader:  "x-client-identity",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "x-client-identity", provider.identityHeader)
+
+	_, err = CreateClientCertificateProvider(&pb.ClientCertificateProvider{})
+	assert.Equal(t, ErrNoAllowedIdentity, err)
+
+	_, err = CreateClientCertificateProvider(&pb.ClientCertificateProvider{
+		AllowedUriSans: []string{"spiffe://mesh.example.com/**/sa/billing"},
+	})
+	assert.Equal(t, ErrInvalidURISanPattern, err)
+}
+
+func TestMatchURISan(t *testing.T) {
+	tcs := []struct {
+		pattern string
+		san     string
+		match   bool
+	}{
+		{"spiffe://mesh.example.com/ns/prod/sa/billing", "spiffe://mesh.example.com/ns/prod/sa/billing", true},
+		{"spiffe://mesh.example.com/ns/prod/sa/billing", "spiffe://mesh.example.com/ns/prod/sa/billing2", false},
+		{"spiffe://mesh.example.com/ns/*/sa/billing", "spiffe://mesh.example.com/ns/dev/sa/billing", true},
+		{"spiffe://mesh.example.com/ns/*/sa/billing", "spiffe://mesh.example.com/ns//sa/billing", false},
+		{"spiffe://mesh.example.com/ns/*/sa/billing", "spiffe://mesh.example.com/ns/a/b/sa/billing", false},
+		{"spiffe://mesh.example.com/ns/*", "spiffe://mesh.example.com/ns/dev/sa/billing", false},
+		{"spiffe://mesh.example.com/**", "spiffe://mesh.example.com/ns/dev/sa/billing", true},
+		{"spiffe://mesh.example.com/**", "spiffe://mesh.example.com/", false},
+		{"spiffe://mesh.example.com/**", "spiffe://mesh.example.com", false},
+		{"spiffe://mesh.example.com/**", "spiffe://mesh.example.com.evil/ns/dev", false},
+	}
+
+	for _, tc := range tcs {
+		assert.Equal(t, tc.match, matchURISan(tc.pattern, tc.san), "%s %s", tc.pattern, tc.san)
+	}
+}
+
+func TestMatchDNSSan(t *testing.T) {
+	tcs := []struct {
+		pattern string
+		san     string
+		match   bool
+	}{
+		{"billing.example.com", "Billing.Example.com", true},
+		{"billing.example.com", "billing.example.org", false},
+		{"*.example.com", "billing.example.com", true},
+		{"*.example.com", "a.billing.example.com", false},
+		{"*.example.com", ".example.com", false},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "*.example.com", true},
+		{"billing.example.com", "*.example.com", false},
+	}
+
+	for _, tc := range tcs {
+		assert.Equal(t, tc.match, matchDNSSan(tc.pattern, tc.san), "%s %s", tc.pattern, tc.san)
+	}
+}
@@ -6,7 +6,12 @@
 package verifier
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -14,13 +19,19 @@ import (
 	"time"
 
 	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/logger"
 
+	"github.com/grab/ego/egofilters/http/httpclient"
 	"github.com/grab/ego/egofilters/http/security/context"
 	securityhttp "github.com/grab/ego/egofilters/http/security/http"
 	pb "github.com/grab/ego/egofilters/http/security/proto"
 )
 
-const hmacUserIDSessionKey = "UserID"
+const (
+	hmacUserIDSessionKey       = "UserID"
+	customHMACTimeout          = 10 * time.Second
+	defaultCircuitOpenDuration = 5 * time.Second
+)
 
 type getCurrentTimeOpt func() time.Time
 
@@ -29,29 +40,87 @@ func getCurrentTime() time.Time {
 }
 
 // CreateCustomHMACProvider ...
-func CreateCustomHMACProvider(provider *pb.CustomHMACProvider) (*customHMACProvider, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// circuitState reports the state of the circuit breaker, if any. It may be nil.
+func CreateCustomHMACProvider(provider *pb.CustomHMACProvider, circuitState envoy.Gauge) (*customHMACProvider, error) {
+	// shared by all requests, so that connections are reused
+	client := httpclient.NewHttpClient(clientOptions(provider.HttpClient))
+	v, err := createCustomHMACProvider(provider, securityhttp.NewHttpClientWithCtx(client), getCurrentTime, isValidSignature)
+	if err != nil {
+		return nil, err
+	}
+	if settings := provider.GetHttpClient().GetCircuitBreaker(); nil != settings {
+		v.breaker = securityhttp.NewCircuitBreaker(circuitBreakerOptions(settings), circuitState, logger.NewDefaultLogger("CustomHMACProvider"))
+	}
+	return v, nil
+}
+
+func circuitBreakerOptions(settings *pb.CircuitBreaker) securityhttp.CircuitBreakerOptions {
+	options := securityhttp.CircuitBreakerOptions{
+		FailureThreshold: int(settings.FailureThreshold),
+		OpenDuration:     time.Duration(settings.OpenDurationMs) * time.Millisecond,
+		HalfOpenProbes:   int(settings.HalfOpenProbes),
+	}
+	if 0 == options.OpenDuration {
+		options.OpenDuration = defaultCircuitOpenDuration
 	}
-	return createCustomHMACProvider(provider, securityhttp.NewHttpClientWithCtx(client), getCurrentTime, isValidSignature)
+	return options
+}
+
+// clientOptions converts settings, which may be nil.
+func clientOptions(settings *pb.HttpClientSettings) httpclient.ClientOptions {
+	options := httpclient.ClientOptions{
+		Timeout:       time.Duration(settings.GetTimeoutMs()) * time.Millisecond,
+		PerTryTimeout: time.Duration(settings.GetPerTryTimeoutMs()) * time.Millisecond,
+		NumRetries:    int(settings.GetNumRetries()),
+		RetryBackoff:  time.Duration(settings.GetRetryBackoffBaseIntervalMs()) * time.Millisecond,
+		MaxIdleConns:  int(settings.GetMaxIdleConnections()),
+	}
+	if 0 == options.Timeout {
+		options.Timeout = customHMACTimeout
+	}
+	return options
 }
 
 func createCustomHMACProvider(
 	provider *pb.CustomHMACProvider, client securityhttp.HttpClientWithCtx, getCurrentTime getCurrentTimeOpt, isValidSignature isValidHMACSignatureOpt) (*customHMACProvider, error) {
-	return &customHMACProvider{
+	v := &customHMACProvider{
 		provider:         provider,
 		client:           client,
+		clientOptions:    clientOptions(provider.HttpClient),
 		getCurrentTime:   getCurrentTime,
 		isValidSignature: isValidSignature,
-	}, nil
+	}
+	if nil != provider.ReplayProtection {
+		v.replays = newReplayGuard(provider.ReplayProtection, getCurrentTime)
+	}
+	return v, nil
 }
 
 type customHMACProvider struct {
 	baseProvider
 	provider         *pb.CustomHMACProvider
 	client           securityhttp.HttpClientWithCtx
+	clientOptions    httpclient.ClientOptions
 	getCurrentTime   getCurrentTimeOpt
 	isValidSignature isValidHMACSignatureOpt
+	// nil without replay protection
+	replays *replayGuard
+	// nil without circuit breaker
+	breaker *securityhttp.CircuitBreaker
+}
+
+// httpClient returns the client for calling the custom auth provider. That's
+// the Envoy cluster, if configured.
+func (v *customHMACProvider) httpClient(ctx context.Context) securityhttp.HttpClientWithCtx {
+	client := v.client
+	if "" != v.provider.Cluster {
+		asyncClient := securityhttp.NewAsyncHttpClient(ctx.AsyncClient(), v.provider.Cluster, v.clientOptions.TryTimeout())
+		client = securityhttp.NewHttpClientWithCtx(httpclient.NewRetryingHttpClient(asyncClient, v.clientOptions))
+	}
+	if nil != v.breaker {
+		client = securityhttp.NewCircuitBreakingHttpClient(client, v.breaker)
+	}
+	return client
 }
 
 func (v *customHMACProvider) Verify(ctx context.RequestContext) {
@@ -64,6 +133,13 @@ func (v *customHMACProvider) Verify(ctx context.RequestContext) {
 		return
 	}
 
+	if nil != v.replays {
+		v.replays.verifyOnce(ctx, parts[0]+":"+parts[1], func(ctx context.RequestContext) {
+			v.checkHMACSignature(ctx, parts)
+		})
+		return
+	}
+
 	v.checkHMACSignature(ctx, parts)
 }
 
@@ -103,7 +179,7 @@ func (v *customHMACProvider) checkHMACSignature(ctx context.RequestContext, part
 		spanName = "custom_hmac_verify"
 	}
 
-	httpResponse, httpErr := v.client.DoWithTracing(ctx, request, spanName)
+	httpResponse, httpErr := v.httpClient(ctx).DoWithTracing(ctx, request, spanName)
 	v.handleHMACSignatureCheckResponse(ctx, userID, httpResponse, httpErr)
 }
 
@@ -169,12 +245,25 @@ func (v *customHMACProvider) WithBody() bool {
 	return true
 }
 
+func (v *customHMACProvider) WriteCacheKey(ctx context.RequestContext, w io.Writer) bool {
+	// a cached result would let replays pass
+	if nil != v.replays {
+		return false
+	}
+	return writeHMACCacheKey(ctx, w)
+}
+
 // CustomHMACSignResponse ...
 type CustomHMACSignResponse struct {
 	Signature string `json:"signature"`
 }
 
 func (v *customHMACProvider) Sign(ctx context.ResponseContext) {
+	if "" != v.provider.ResponseSigningKey {
+		v.signLocally(ctx)
+		return
+	}
+
 	serviceKey := ctx.GetSecret(v.provider.ServiceKey)
 	serviceToken := ctx.GetSecret(v.provider.ServiceToken)
 
@@ -194,10 +283,7 @@ func (v *customHMACProvider) Sign(ctx context.ResponseContext) {
 		})
 		return
 	}
-	// golang will format to UTC by default so here need to force the timezone info to GMT
-	// RFC1123 is the preferred time format for RFC7231,
-	// eg. Sun, 06 Nov 1994 08:49:37 GMT
-	signedTime := v.getCurrentTime().In(time.FixedZone("GMT", 0)).Format(time.RFC1123)
+	signedTime := v.signedTime()
 	signReq.Header.Set("X-Custom-Auth-Date", signedTime)
 	signReq.Header.Set("Authorization", "Token "+serviceKey+" "+serviceToken)
 	signReq.Header.Set("X-Custom-Auth-Status-Code", ctx.Headers().Status().Copy())
@@ -209,7 +295,7 @@ func (v *customHMACProvider) Sign(ctx context.ResponseContext) {
 		spanName = "custom_hmac_sign"
 	}
 
-	resp, err := v.client.DoWithTracing(ctx, signReq, spanName)
+	resp, err := v.httpClient(ctx).DoWithTracing(ctx, signReq, spanName)
 
 	if err != nil {
 		ctx.Logger().Error("[Sign] can't send sign request.", err)
@@ -250,6 +336,49 @@ func (v *customHMACProvider) Sign(ctx context.ResponseContext) {
 	})
 }
 
+// signLocally signs the response in-process, setting the same headers as
+// the response signing service.
+func (v *customHMACProvider) signLocally(ctx context.ResponseContext) {
+	key := ctx.GetSecret(v.provider.ResponseSigningKey)
+	if "" == key {
+		ctx.Logger().Error("[Sign] missing response signing key.", v.provider.ResponseSigningKey)
+		ctx.Callbacks().OnCompleteSigning(context.SignResponse{
+			StatusCode: http.StatusInternalServerError,
+		})
+		return
+	}
+
+	bodyHash := sha256.New()
+	if body := ctx.BodyReader(); body != nil {
+		if _, err := io.Copy(bodyHash, body); err != nil {
+			ctx.Logger().Error("[Sign] can't read response body.", err)
+			ctx.Callbacks().OnCompleteSigning(context.SignResponse{
+				StatusCode: http.StatusInternalServerError,
+			})
+			return
+		}
+	}
+
+	signedTime := v.signedTime()
+	mac := hmac.New(sha256.New, []byte(key))
+	writeSignedFields(mac, ctx.Headers().Status().Copy(), signedTime, hex.EncodeToString(bodyHash.Sum(nil)))
+
+	ctx.Callbacks().OnCompleteSigning(context.SignResponse{
+		HeadersToSet: map[string]string{
+			"X-Custom-Auth-Signature-HMAC-SHA256": base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+			"Date":                                signedTime,
+		},
+	})
+}
+
+// signedTime returns the current time formatted for the Date header.
+func (v *customHMACProvider) signedTime() string {
+	// golang will format to UTC by default so here need to force the timezone info to GMT
+	// RFC1123 is the preferred time format for RFC7231,
+	// eg. Sun, 06 Nov 1994 08:49:37 GMT
+	return v.getCurrentTime().In(time.FixedZone("GMT", 0)).Format(time.RFC1123)
+}
+
 func (v *customHMACProvider) SigningRequired(headers envoy.ResponseHeaderMap, authResp context.AuthResponse) bool {
 	if nil == headers || nil == authResp.FilterState || "" == authResp.FilterState[hmacUserIDSessionKey] {
 		return false
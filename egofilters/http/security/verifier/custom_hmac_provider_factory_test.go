@@ -7,17 +7,64 @@ package verifier
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grab/ego/egofilters/http/httpclient"
+	securityhttp "github.com/grab/ego/egofilters/http/security/http"
 	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
 )
 
 func TestCreateCustomHMACProvider(t *testing.T) {
-	provider, err := CreateCustomHMACProvider(&pb.CustomHMACProvider{})
+	provider, err := CreateCustomHMACProvider(&pb.CustomHMACProvider{}, nil)
 
 	require.Nil(t, err)
 	assert.NotNil(t, provider)
 	assert.NotNil(t, provider.getCurrentTime())
 }
+
+func TestCustomHMACClientOptions(t *testing.T) {
+	assert.Equal(t, httpclient.ClientOptions{Timeout: customHMACTimeout}, clientOptions(nil))
+	assert.Equal(t, httpclient.ClientOptions{
+		Timeout:       3 * time.Second,
+		PerTryTimeout: time.Second,
+		NumRetries:    2,
+		RetryBackoff:  50 * time.Millisecond,
+		MaxIdleConns:  16,
+	}, clientOptions(&pb.HttpClientSettings{
+		TimeoutMs:                  3000,
+		PerTryTimeoutMs:            1000,
+		NumRetries:                 2,
+		RetryBackoffBaseIntervalMs: 50,
+		MaxIdleConnections:         16,
+	}))
+}
+
+func TestCreateCustomHMACProviderCircuitBreaker(t *testing.T) {
+	provider, err := CreateCustomHMACProvider(&pb.CustomHMACProvider{}, nil)
+	require.Nil(t, err)
+	assert.Nil(t, provider.breaker)
+
+	circuitState := &envoymocks.Gauge{}
+	circuitState.On("Set", uint64(securityhttp.CircuitClosed))
+	provider, err = CreateCustomHMACProvider(&pb.CustomHMACProvider{
+		HttpClient: &pb.HttpClientSettings{CircuitBreaker: &pb.CircuitBreaker{FailureThreshold: 5}},
+	}, circuitState)
+	require.Nil(t, err)
+	assert.NotNil(t, provider.breaker)
+	circuitState.AssertExpectations(t)
+
+	assert.Equal(t, securityhttp.CircuitBreakerOptions{
+		FailureThreshold: 5,
+		OpenDuration:     defaultCircuitOpenDuration,
+	}, circuitBreakerOptions(&pb.CircuitBreaker{FailureThreshold: 5}))
+	assert.Equal(t, securityhttp.CircuitBreakerOptions{
+		FailureThreshold: 5,
+		OpenDuration:     time.Second,
+		HalfOpenProbes:   3,
+	}, circuitBreakerOptions(&pb.CircuitBreaker{FailureThreshold: 5, OpenDurationMs: 1000, HalfOpenProbes: 3}))
+}
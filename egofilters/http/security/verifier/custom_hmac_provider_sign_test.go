@@ -326,3 +326,67 @@ func TestHMACSignResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestHMACSignResponseLocally(t *testing.T) {
+	tcs := []struct {
+		name         string
+		signingKey   string
+		responseBody string
+		signResult   context.SignResponse
+	}{
+		{
+			name:         "should sign response with the signing key",
+			signingKey:   "signing_secret",
+			responseBody: "this is body from upstream",
+			signResult: context.SignResponse{
+				HeadersToSet: map[string]string{
+					"Date":                                "Sat, 01 Jan 2000 02:03:04 GMT",
+					"X-Custom-Auth-Signature-HMAC-SHA256": hmacTestSignature("signing_secret", "200", "Sat, 01 Jan 2000 02:03:04 GMT", "this is body from upstream"),
+				},
+			},
+		},
+		{
+			name:         "should return 500 if the signing key is missing",
+			responseBody: "this is body from upstream",
+			signResult: context.SignResponse{
+				StatusCode: http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, val := range tcs {
+		tc := val
+		t.Run(tc.name, func(t *testing.T) {
+			responseContext := &contextmocks.ResponseContext{}
+			responseContext.On("GetSecret", "response_signing_key").Return(tc.signingKey)
+			responseContext.On("BodyReader").Return(strings.NewReader(tc.responseBody))
+			responseContext.On("Logger").Return(logger.NewLogger("CustomHMACLogger", egomocks.NativeLogger{}))
+
+			headers := &envoymocks.ResponseHeaderMap{}
+			headers.On("Status").Return(volatile.String("200"))
+			responseContext.On("Headers").Return(headers)
+
+			callbacks := &contextmocks.ResponseCallbacks{}
+			responseContext.On("Callbacks").Return(callbacks)
+			var signResp context.SignResponse
+			callbacks.On("OnCompleteSigning", mock.Anything).Run(func(args mock.Arguments) {
+				signResp = args[0].(context.SignResponse)
+			})
+
+			// the response signing service is never called
+			httpClient := &httpmocks.HttpClientWithCtx{}
+			config := &pb.CustomHMACProvider{
+				ResponseSigningUrl: "http://custom-auth.example.com",
+				ResponseSigningKey: "response_signing_key",
+			}
+			currentTime := func() time.Time { return time.Date(2000, time.January, 1, 2, 3, 4, 5, time.UTC) }
+			signer, err := createCustomHMACProvider(config, httpClient, currentTime, nil)
+			require.Nil(t, err)
+
+			signer.Sign(responseContext)
+
+			httpClient.AssertNotCalled(t, "DoWithTracing", mock.Anything, mock.Anything, mock.Anything)
+			assert.Equal(t, tc.signResult, signResp)
+		})
+	}
+}
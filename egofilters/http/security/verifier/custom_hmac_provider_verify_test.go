@@ -13,16 +13,19 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grab/ego/ego/src/go/envoy"
 	"github.com/grab/ego/ego/src/go/logger"
 	"github.com/grab/ego/ego/src/go/volatile"
 	egomocks "github.com/grab/ego/ego/test/go/mock"
 
 	"github.com/grab/ego/egofilters/http/security/context"
+	securityhttp "github.com/grab/ego/egofilters/http/security/http"
 	pb "github.com/grab/ego/egofilters/http/security/proto"
 
 	"github.com/grab/ego/egofilters/mock/gen/http/security/context"
@@ -57,7 +60,7 @@ func TestHMACInvalidToken(t *testing.T) {
 
 			ctx.On("Logger").Return(logger.NewLogger("CustomHMACLogger", egomocks.NativeLogger{}))
 
-			provider, _ := CreateCustomHMACProvider(&pb.CustomHMACProvider{})
+			provider, _ := CreateCustomHMACProvider(&pb.CustomHMACProvider{}, nil)
 
 			provider.Verify(ctx)
 
@@ -466,3 +469,103 @@ func TestHMACVerify(t *testing.T) {
 		})
 	}
 }
+
+func TestHMACVerifyThroughCluster(t *testing.T) {
+	settings := &pb.CustomHMACProvider{
+		RequestValidationUrl: "http://custom-auth.example.com/verify",
+		ServiceKey:           "service_key",
+		ServiceToken:         "service_token",
+		Cluster:              "custom_auth",
+	}
+
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("GoContext").Return(gocontext.Background())
+	ctx.On("GetSecret", mock.Anything).Return("secret")
+	ctx.On("BodyReader").Return(bytes.NewReader([]byte("request body")))
+	ctx.On("Logger").Return(logger.NewLogger("CustomHMACLogger", egomocks.NativeLogger{}))
+
+	headerMap := &envoymocks.RequestHeaderMap{}
+	ctx.On("Headers").Return(headerMap)
+	headerMap.On("Authorization").Return(volatile.String("partner_id1:signature"))
+	headerMap.On("Path").Return(volatile.String("/foo"))
+	headerMap.On("Method").Return(volatile.String(http.MethodGet))
+	headerMap.On("ContentType").Return(volatile.String(""))
+	headerMap.On("Get", mock.Anything).Return(volatile.String(""))
+
+	// the async client is used rather than the configured http client
+	asyncClient := &envoymocks.AsyncClient{}
+	ctx.On("AsyncClient").Return(asyncClient)
+	var actualReq *envoy.AsyncRequest
+	asyncClient.On("Send", "custom_auth", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		actualReq = args[1].(*envoy.AsyncRequest)
+		args[2].(func(*envoy.AsyncResponse, error))(&envoy.AsyncResponse{StatusCode: http.StatusOK}, nil)
+	}).Return(nil)
+
+	callbacks := &mocks.Callbacks{}
+	ctx.On("Callbacks").Return(callbacks)
+	var authResp context.AuthResponse
+	callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+		authResp = args[0].(context.AuthResponse)
+	})
+
+	isValid := func(resp *http.Response) (bool, error) {
+		return http.StatusOK == resp.StatusCode, nil
+	}
+	provider, _ := createCustomHMACProvider(settings, &httpmocks.HttpClientWithCtx{}, getCurrentTime, isValid)
+
+	provider.Verify(ctx)
+
+	asyncClient.AssertExpectations(t)
+	require.NotNil(t, actualReq)
+	assert.Equal(t, http.MethodPost, actualReq.Method)
+	assert.Equal(t, "/verify", actualReq.Path)
+	assert.Equal(t, "custom-auth.example.com", actualReq.Authority)
+	assert.Equal(t, []byte("request body"), actualReq.Body)
+	assert.Equal(t, customHMACTimeout, actualReq.Timeout)
+	assert.Equal(t, context.AuthOK, authResp.Status)
+}
+
+func TestHMACVerifyCircuitOpen(t *testing.T) {
+	settings := &pb.CustomHMACProvider{
+		RequestValidationUrl: "https://custom-auth.example.com/verify",
+		ServiceKey:           "service_key",
+		ServiceToken:         "service_token",
+	}
+
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("GoContext").Return(gocontext.Background())
+	ctx.On("GetSecret", mock.Anything).Return("secret")
+	ctx.On("BodyReader").Return(bytes.NewReader([]byte("request body")))
+	ctx.On("Logger").Return(logger.NewLogger("CustomHMACLogger", egomocks.NativeLogger{}))
+
+	headerMap := &envoymocks.RequestHeaderMap{}
+	ctx.On("Headers").Return(headerMap)
+	headerMap.On("Authorization").Return(volatile.String("partner_id1:signature"))
+	headerMap.On("Path").Return(volatile.String("/foo"))
+	headerMap.On("Method").Return(volatile.String(http.MethodGet))
+	headerMap.On("ContentType").Return(volatile.String(""))
+	headerMap.On("Get", mock.Anything).Return(volatile.String(""))
+
+	var authResp context.AuthResponse
+	callbacks := &mocks.Callbacks{}
+	callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+		authResp = args[0].(context.AuthResponse)
+	})
+	ctx.On("Callbacks").Return(callbacks)
+
+	client := &httpmocks.HttpClientWithCtx{}
+	client.On("DoWithTracing", ctx, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	provider, _ := createCustomHMACProvider(settings, client, getCurrentTime, isValidSignature)
+	provider.breaker = securityhttp.NewCircuitBreaker(securityhttp.CircuitBreakerOptions{FailureThreshold: 1, OpenDuration: time.Minute},
+		nil, logger.NewLogger("CircuitBreakerLogger", egomocks.NativeLogger{}))
+
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthResponseError(), authResp)
+
+	// the custom auth provider isn't called while the circuit is open
+	authResp = context.AuthResponse{}
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthResponseError(), authResp)
+	client.AssertNumberOfCalls(t, "DoWithTracing", 1)
+}
@@ -130,7 +130,7 @@ func TestIsValidSignature(t *testing.T) {
 	for _, tmp := range tcs {
 		tc := tmp
 		t.Run(tc.name, func(t *testing.T) {
-			provider, err := CreateCustomHMACProvider(&pb.CustomHMACProvider{})
+			provider, err := CreateCustomHMACProvider(&pb.CustomHMACProvider{}, nil)
 			require.Nil(t, err)
 
 			valid, err := provider.isValidSignature(tc.response)
@@ -0,0 +1,113 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"time"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+)
+
+// ProviderStats are the stats of a provider.
+type ProviderStats struct {
+	AuthOK     envoy.Counter
+	AuthDenied envoy.Counter
+	AuthError  envoy.Counter
+	// in milliseconds
+	VerifyLatency envoy.Histogram
+	SignLatency   envoy.Histogram
+}
+
+// NewInstrumentedVerifier returns a Verifier recording the results and
+// latency of verifier in stats. A nil verifier is returned as it is.
+func NewInstrumentedVerifier(verifier Verifier, stats *ProviderStats) Verifier {
+	if verifier == nil {
+		return nil
+	}
+	return &instrumentedVerifier{Verifier: verifier, stats: stats, getCurrentTime: getCurrentTime}
+}
+
+type instrumentedVerifier struct {
+	Verifier
+	stats          *ProviderStats
+	getCurrentTime getCurrentTimeOpt
+}
+
+func (v *instrumentedVerifier) Verify(ctx context.RequestContext) {
+	start := v.getCurrentTime()
+	v.Verifier.Verify(&childRequestContext{
+		RequestContext: ctx,
+		callbacks: callbacksFunc(func(response context.AuthResponse) {
+			v.stats.VerifyLatency.RecordValue(millisecondsSince(start, v.getCurrentTime()))
+			switch response.Status {
+			case context.AuthOK:
+				v.stats.AuthOK.Inc()
+			case context.AuthDenied:
+				v.stats.AuthDenied.Inc()
+			default:
+				v.stats.AuthError.Inc()
+			}
+			ctx.Callbacks().OnComplete(response)
+		}),
+		goContext:  ctx.GoContext(),
+		bodyReader: ctx.BodyReader(),
+	})
+}
+
+func (v *instrumentedVerifier) StreamsBody() bool {
+	return StreamsBody(v.Verifier)
+}
+
+// NewInstrumentedSigner returns a Signer recording the latency of signer in
+// stats. A nil signer is returned as it is.
+func NewInstrumentedSigner(signer Signer, stats *ProviderStats) Signer {
+	if signer == nil {
+		return nil
+	}
+	return &instrumentedSigner{Signer: signer, stats: stats, getCurrentTime: getCurrentTime}
+}
+
+type instrumentedSigner struct {
+	Signer
+	stats          *ProviderStats
+	getCurrentTime getCurrentTimeOpt
+}
+
+func (s *instrumentedSigner) Sign(ctx context.ResponseContext) {
+	start := s.getCurrentTime()
+	s.Signer.Sign(&childResponseContext{
+		ResponseContext: ctx,
+		callbacks: responseCallbacksFunc(func(response context.SignResponse) {
+			s.stats.SignLatency.RecordValue(millisecondsSince(start, s.getCurrentTime()))
+			ctx.Callbacks().OnCompleteSigning(response)
+		}),
+	})
+}
+
+func millisecondsSince(start, now time.Time) uint64 {
+	if elapsed := now.Sub(start); elapsed > 0 {
+		return uint64(elapsed / time.Millisecond)
+	}
+	return 0
+}
+
+type responseCallbacksFunc func(context.SignResponse)
+
+func (f responseCallbacksFunc) OnCompleteSigning(response context.SignResponse) {
+	f(response)
+}
+
+// childResponseContext is the ResponseContext of a wrapped signer.
+type childResponseContext struct {
+	context.ResponseContext
+	callbacks context.ResponseCallbacks
+}
+
+func (c *childResponseContext) Callbacks() context.ResponseCallbacks {
+	return c.callbacks
+}
@@ -0,0 +1,133 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+// fakeSigner completes with response.
+type fakeSigner struct {
+	response context.SignResponse
+}
+
+func (s *fakeSigner) Sign(ctx context.ResponseContext) {
+	ctx.Callbacks().OnCompleteSigning(s.response)
+}
+
+func (s *fakeSigner) SigningRequired(envoy.ResponseHeaderMap, context.AuthResponse) bool {
+	return true
+}
+
+// steppingClock advances by step on every call.
+func steppingClock(step time.Duration) getCurrentTimeOpt {
+	now := time.Unix(1600000000, 0)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+func newProviderStats() *ProviderStats {
+	stats := &ProviderStats{
+		AuthOK:        &envoymocks.Counter{},
+		AuthDenied:    &envoymocks.Counter{},
+		AuthError:     &envoymocks.Counter{},
+		VerifyLatency: &envoymocks.Histogram{},
+		SignLatency:   &envoymocks.Histogram{},
+	}
+	for _, counter := range []envoy.Counter{stats.AuthOK, stats.AuthDenied, stats.AuthError} {
+		counter.(*envoymocks.Counter).On("Inc").Return()
+	}
+	for _, histogram := range []envoy.Histogram{stats.VerifyLatency, stats.SignLatency} {
+		histogram.(*envoymocks.Histogram).On("RecordValue", mock.Anything).Return()
+	}
+	return stats
+}
+
+func TestInstrumentedVerifier(t *testing.T) {
+	tcs := []struct {
+		name     string
+		response context.AuthResponse
+		counter  func(*ProviderStats) envoy.Counter
+	}{
+		{
+			name:     "ok",
+			response: context.AuthResponseOK(),
+			counter:  func(stats *ProviderStats) envoy.Counter { return stats.AuthOK },
+		},
+		{
+			name:     "denied",
+			response: context.AuthResponseUnauthorized(),
+			counter:  func(stats *ProviderStats) envoy.Counter { return stats.AuthDenied },
+		},
+		{
+			name:     "error",
+			response: context.AuthResponseError(),
+			counter:  func(stats *ProviderStats) envoy.Counter { return stats.AuthError },
+		},
+	}
+
+	for _, val := range tcs {
+		tc := val
+		t.Run(tc.name, func(t *testing.T) {
+			stats := newProviderStats()
+			fake := &fakeVerifier{response: tc.response}
+			v := &instrumentedVerifier{Verifier: fake, stats: stats, getCurrentTime: steppingClock(25 * time.Millisecond)}
+
+			// the response and the body are passed through
+			assert.Equal(t, tc.response, verifyWithBody(v, "body1"))
+			assert.Equal(t, "body1", string(fake.body))
+
+			stats.VerifyLatency.(*envoymocks.Histogram).AssertCalled(t, "RecordValue", uint64(25))
+			for _, counter := range []envoy.Counter{stats.AuthOK, stats.AuthDenied, stats.AuthError} {
+				if counter == tc.counter(stats) {
+					counter.(*envoymocks.Counter).AssertNumberOfCalls(t, "Inc", 1)
+				} else {
+					counter.(*envoymocks.Counter).AssertNotCalled(t, "Inc")
+				}
+			}
+		})
+	}
+}
+
+func TestInstrumentedSigner(t *testing.T) {
+	stats := newProviderStats()
+	response := context.SignResponse{HeadersToSet: map[string]string{"x-signature": "1"}}
+	s := &instrumentedSigner{Signer: &fakeSigner{response: response}, stats: stats, getCurrentTime: steppingClock(3 * time.Millisecond)}
+
+	ctx := &contextmocks.ResponseContext{}
+	callbacks := &contextmocks.ResponseCallbacks{}
+	callbacks.On("OnCompleteSigning", response).Return()
+	ctx.On("Callbacks").Return(callbacks)
+
+	s.Sign(ctx)
+
+	callbacks.AssertExpectations(t)
+	stats.SignLatency.(*envoymocks.Histogram).AssertCalled(t, "RecordValue", uint64(3))
+	stats.VerifyLatency.(*envoymocks.Histogram).AssertNotCalled(t, "RecordValue", mock.Anything)
+}
+
+func TestInstrumentedNil(t *testing.T) {
+	assert.Nil(t, NewInstrumentedVerifier(nil, newProviderStats()))
+	assert.Nil(t, NewInstrumentedSigner(nil, newProviderStats()))
+}
+
+func TestInstrumentedStreamsBody(t *testing.T) {
+	assert.False(t, StreamsBody(NewInstrumentedVerifier(&fakeVerifier{}, newProviderStats())))
+	assert.True(t, StreamsBody(NewInstrumentedVerifier(&localHMACProvider{}, newProviderStats())))
+}
@@ -0,0 +1,253 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/logger"
+
+	"github.com/grab/ego/egofilters/http/httpclient"
+	"github.com/grab/ego/egofilters/http/security/context"
+	securityhttp "github.com/grab/ego/egofilters/http/security/http"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+)
+
+const (
+	defaultIntrospectionCacheMaxEntries = 10000
+	// large enough for any sane introspection response
+	maxIntrospectionResponseBytes = 1 << 20
+)
+
+// CreateIntrospectionProvider ...
+// circuitState reports the state of the circuit breaker, if any. It may be nil.
+// cacheStats are the counters of the token cache.
+func CreateIntrospectionProvider(
+	provider *pb.IntrospectionProvider, circuitState envoy.Gauge, cacheStats CacheStats) (*introspectionProvider, error) {
+	// shared by all requests, so that connections are reused
+	client := httpclient.NewHttpClient(clientOptions(provider.HttpClient))
+	v, err := createIntrospectionProvider(provider, securityhttp.NewHttpClientWithCtx(client), cacheStats, getCurrentTime)
+	if err != nil {
+		return nil, err
+	}
+	if settings := provider.GetHttpClient().GetCircuitBreaker(); nil != settings {
+		v.breaker = securityhttp.NewCircuitBreaker(circuitBreakerOptions(settings), circuitState, logger.NewDefaultLogger("IntrospectionProvider"))
+	}
+	return v, nil
+}
+
+func createIntrospectionProvider(provider *pb.IntrospectionProvider,
+	client securityhttp.HttpClientWithCtx, cacheStats CacheStats, getCurrentTime getCurrentTimeOpt) (*introspectionProvider, error) {
+	if _, err := url.Parse(provider.IntrospectionUrl); err != nil {
+		return nil, err
+	}
+	maxEntries := int(provider.CacheMaxEntries)
+	if 0 == maxEntries {
+		maxEntries = defaultIntrospectionCacheMaxEntries
+	}
+	// active tokens are cached until their exp otherwise
+	positiveTTL := time.Duration(math.MaxInt64)
+	if 0 != provider.CacheMaxSeconds {
+		positiveTTL = time.Duration(provider.CacheMaxSeconds) * time.Second
+	}
+	return &introspectionProvider{
+		provider:       provider,
+		client:         client,
+		clientOptions:  clientOptions(provider.HttpClient),
+		cache:          newVerificationCache(maxEntries, positiveTTL, 0, cacheStats, getCurrentTime),
+		getCurrentTime: getCurrentTime,
+	}, nil
+}
+
+type introspectionProvider struct {
+	baseProvider
+	provider       *pb.IntrospectionProvider
+	client         securityhttp.HttpClientWithCtx
+	clientOptions  httpclient.ClientOptions
+	cache          *VerificationCache
+	getCurrentTime getCurrentTimeOpt
+	// nil without circuit breaker
+	breaker *securityhttp.CircuitBreaker
+}
+
+// httpClient returns the client for calling the authorization server. That's
+// the Envoy cluster, if configured.
+func (v *introspectionProvider) httpClient(ctx context.Context) securityhttp.HttpClientWithCtx {
+	client := v.client
+	if "" != v.provider.Cluster {
+		asyncClient := securityhttp.NewAsyncHttpClient(ctx.AsyncClient(), v.provider.Cluster, v.clientOptions.TryTimeout())
+		client = securityhttp.NewHttpClientWithCtx(httpclient.NewRetryingHttpClient(asyncClient, v.clientOptions))
+	}
+	if nil != v.breaker {
+		client = securityhttp.NewCircuitBreakingHttpClient(client, v.breaker)
+	}
+	return client
+}
+
+func (v *introspectionProvider) Verify(ctx context.RequestContext) {
+	token, ok := bearerToken(ctx.Headers())
+	if !ok {
+		ctx.Logger().Debug("[Verify] no bearer token.")
+		ctx.Callbacks().OnComplete(bearerChallenge(context.AuthResponseUnauthorized(), ""))
+		return
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	key := string(hash[:])
+	if resp, ok := v.cache.get(key); ok {
+		ctx.Callbacks().OnComplete(resp)
+		return
+	}
+
+	fields, err := v.introspect(ctx, token)
+	if err != nil {
+		ctx.Logger().Error("[Verify] can't introspect the token.", err)
+		ctx.Callbacks().OnComplete(context.AuthResponseError())
+		return
+	}
+
+	resp := v.authResponse(fields)
+	if context.AuthOK != resp.Status {
+		ctx.Logger().Debug("[Verify] token rejected.", resp.DenialReason)
+		ctx.Callbacks().OnComplete(resp)
+		return
+	}
+	// tokens without exp are not cached
+	if !resp.ExpiresAt.IsZero() {
+		v.cache.put(key, resp)
+	}
+	ctx.Callbacks().OnComplete(resp)
+}
+
+// introspect asks the authorization server about token, and returns the
+// fields of its response. Numbers are decoded as json.Number.
+func (v *introspectionProvider) introspect(ctx context.RequestContext, token string) (map[string]interface{}, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	request, err := http.NewRequestWithContext(ctx.GoContext(), http.MethodPost, v.provider.IntrospectionUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	// the credentials are form encoded first, see RFC 6749 section 2.3.1
+	request.SetBasicAuth(
+		url.QueryEscape(ctx.GetSecret(v.provider.ClientId)), url.QueryEscape(ctx.GetSecret(v.provider.ClientSecret)))
+	request.Header.Set(contentTypeHeader, "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set(cacheControlHeader, "no-cache")
+	request.Header.Set(requestIDHeader, ctx.Headers().Get(requestIDHeader).Copy())
+
+	spanName := ""
+	if v.provider.TracingEnabled {
+		spanName = "oauth2_introspect"
+	}
+	response, err := v.httpClient(ctx).DoWithTracing(ctx, request, spanName)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if http.StatusOK != response.StatusCode {
+		// drained to reuse the connection
+		io.Copy(ioutil.Discard, io.LimitReader(response.Body, maxIntrospectionResponseBytes))
+		return nil, fmt.Errorf("introspection response status %d", response.StatusCode)
+	}
+
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(io.LimitReader(response.Body, maxIntrospectionResponseBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// authResponse checks the active, exp, scope and aud fields of an
+// introspection response.
+func (v *introspectionProvider) authResponse(fields map[string]interface{}) context.AuthResponse {
+	if active, _ := fields["active"].(bool); !active {
+		return bearerDenial(http.StatusUnauthorized, context.DenialReasonInactiveToken, "invalid_token")
+	}
+	// expired tokens aren't active, unless the server lags behind
+	var expiresAt time.Time
+	if exp, ok := fields["exp"]; ok {
+		t, err := claimTime(exp)
+		if err != nil || !v.getCurrentTime().Before(t) {
+			return bearerDenial(http.StatusUnauthorized, context.DenialReasonInactiveToken, "invalid_token")
+		}
+		expiresAt = t
+	}
+	if !hasScopes(fields["scope"], v.provider.RequiredScopes) {
+		return bearerDenial(http.StatusForbidden, context.DenialReasonInsufficientScope, "insufficient_scope")
+	}
+	if 0 != len(v.provider.Audiences) && !containsAudience(fields["aud"], v.provider.Audiences) {
+		return bearerDenial(http.StatusUnauthorized, context.DenialReasonInvalidAudience, "invalid_token")
+	}
+
+	resp := context.AuthResponseOK()
+	resp.ExpiresAt = expiresAt
+	resp.HeadersToSet = make(map[string]string)
+	resp.HeadersToRemove = make(map[string]struct{})
+	for _, h := range v.provider.FieldToHeaders {
+		resp.HeadersToRemove[h.Header] = struct{}{}
+		if value, ok := claimString(fields[h.Field]); ok {
+			resp.HeadersToSet[h.Header] = value
+		}
+	}
+
+	resp.FilterState = make(map[string]string)
+	for _, field := range v.provider.FieldsToFilterState {
+		if value, ok := claimString(fields[field]); ok {
+			resp.FilterState[field] = value
+		}
+	}
+	return resp
+}
+
+// bearerDenial returns a denied response with a Bearer challenge.
+func bearerDenial(statusCode int, reason, bearerError string) context.AuthResponse {
+	resp := bearerChallenge(context.AuthResponseDenied(statusCode), bearerError)
+	resp.DenialReason = reason
+	return resp
+}
+
+// bearerChallenge sets the WWW-Authenticate header of a denied response, see
+// RFC 6750 section 3.
+func bearerChallenge(resp context.AuthResponse, bearerError string) context.AuthResponse {
+	challenge := "Bearer"
+	if "" != bearerError {
+		challenge += ` error="` + bearerError + `"`
+	}
+	resp.HeadersToSet = map[string]string{"WWW-Authenticate": challenge}
+	return resp
+}
+
+// hasScopes reports whether scope, a space separated list, contains all of
+// required.
+func hasScopes(scope interface{}, required []string) bool {
+	if 0 == len(required) {
+		return true
+	}
+	s, _ := scope.(string)
+	granted := map[string]struct{}{}
+	for _, name := range strings.Fields(s) {
+		granted[name] = struct{}{}
+	}
+	for _, name := range required {
+		if _, ok := granted[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
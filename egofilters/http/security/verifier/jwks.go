@@ -0,0 +1,287 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	gocontext "context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	securityhttp "github.com/grab/ego/egofilters/http/security/http"
+)
+
+const (
+	defaultJwksCacheDuration = 5 * time.Minute
+	// keys are not refetched more often than this for unknown key IDs
+	jwksMinRefreshInterval = 30 * time.Second
+	jwksFetchTimeout       = 10 * time.Second
+)
+
+var (
+	// ErrUnsupportedJwk ...
+	ErrUnsupportedJwk = errors.New("unsupported JSON web key")
+	// ErrInvalidJwks ...
+	ErrInvalidJwks = errors.New("invalid JSON web key set")
+)
+
+// jwk is a verification key of a JSON Web Key Set.
+type jwk struct {
+	kid string
+	alg string
+	// *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or []byte
+	key interface{}
+}
+
+// jwkJSON is the JSON representation of a key, see RFC 7517 and RFC 7518.
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// parseJwks parses a JSON Web Key Set, ignoring keys not used for
+// signatures.
+func parseJwks(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	if 0 == len(set.Keys) {
+		return nil, ErrInvalidJwks
+	}
+
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if "" != k.Use && "sig" != k.Use {
+			continue
+		}
+		key, err := parseJwk(&k)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", k.Kid, err)
+		}
+		keys = append(keys, jwk{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return keys, nil
+}
+
+func parseJwk(k *jwkJSON) (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, ErrUnsupportedJwk
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if "P-256" != k.Crv {
+			return nil, ErrUnsupportedJwk
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, ErrInvalidJwks
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+
+	case "OKP":
+		if "Ed25519" != k.Crv {
+			return nil, ErrUnsupportedJwk
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if ed25519.PublicKeySize != len(x) {
+			return nil, ErrInvalidJwks
+		}
+		return ed25519.PublicKey(x), nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		if 0 == len(secret) {
+			return nil, ErrInvalidJwks
+		}
+		return secret, nil
+	}
+	return nil, ErrUnsupportedJwk
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if 0 == len(b) {
+		return nil, ErrInvalidJwks
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// remoteJwks caches the keys fetched from a JWKS URI. It is shared by all
+// requests of a provider.
+type remoteJwks struct {
+	uri           string
+	cluster       string
+	cacheDuration time.Duration
+
+	client         securityhttp.HttpClientWithCtx
+	getCurrentTime getCurrentTimeOpt
+
+	lock      sync.Mutex
+	keys      []jwk
+	fetchedAt time.Time
+	// the last completed fetch, successful or not, and its error
+	attemptedAt time.Time
+	err         error
+	// closed when the ongoing fetch is done, nil if there is none
+	fetching chan struct{}
+}
+
+// httpClient returns the client for fetching the keys. That's the Envoy
+// cluster, if configured.
+func (r *remoteJwks) httpClient(ctx context.Context) securityhttp.HttpClientWithCtx {
+	if "" == r.cluster {
+		return r.client
+	}
+	return securityhttp.NewHttpClientWithCtx(
+		securityhttp.NewAsyncHttpClient(ctx.AsyncClient(), r.cluster, jwksFetchTimeout))
+}
+
+// getKeys returns the cached keys, fetching them if they have expired. With
+// refresh set, the keys are fetched unless they are very recent. Concurrent
+// requests wait for the fetch of one of them, and fetch again if its request
+// went away in the meantime. Keys are not fetched again for a while after a
+// failure, and stale keys are returned if fetching fails.
+func (r *remoteJwks) getKeys(ctx context.RequestContext, refresh bool) ([]jwk, error) {
+	for {
+		r.lock.Lock()
+		now := r.getCurrentTime()
+		age := now.Sub(r.fetchedAt)
+		if nil != r.keys && age < r.cacheDuration && (!refresh || age < jwksMinRefreshInterval) {
+			defer r.lock.Unlock()
+			return r.keys, nil
+		}
+
+		done := r.fetching
+		if nil == done {
+			if now.Sub(r.attemptedAt) < jwksMinRefreshInterval {
+				// back off, the last fetch was recent
+				defer r.lock.Unlock()
+				return r.keysOrError()
+			}
+			done = make(chan struct{})
+			r.fetching = done
+			r.lock.Unlock()
+			return r.fetch(ctx, now, done)
+		}
+		r.lock.Unlock()
+
+		select {
+		case <-done:
+		case <-ctx.GoContext().Done():
+			return nil, ctx.GoContext().Err()
+		}
+	}
+}
+
+// keysOrError returns the keys, which may be stale, or the error of the last
+// fetch if there are none. r.lock must be held.
+func (r *remoteJwks) keysOrError() ([]jwk, error) {
+	if nil == r.keys {
+		return nil, r.err
+	}
+	return r.keys, nil
+}
+
+// fetch fetches the keys for all requests waiting for done, and closes it.
+// It runs on the go-routine of ctx, which is pinned, since fetches through an
+// Envoy cluster are sent on behalf of the stream of ctx. A fetch failing
+// because ctx is canceled isn't recorded, so that a waiting request fetches
+// again rather than backing off.
+func (r *remoteJwks) fetch(ctx context.RequestContext, now time.Time, done chan struct{}) ([]jwk, error) {
+	goContext, cancel := gocontext.WithTimeout(ctx.GoContext(), jwksFetchTimeout)
+	defer cancel()
+	keys, err := r.fetchKeys(goContext, ctx)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.fetching = nil
+	close(done)
+	if err != nil && nil != ctx.GoContext().Err() {
+		return nil, err
+	}
+	r.attemptedAt = now
+	r.err = err
+	if err != nil {
+		if nil != r.keys {
+			ctx.Logger().Warn("[Verify] can't refresh JWKS, using cached keys.", r.uri, err)
+		}
+	} else {
+		r.keys = keys
+		r.fetchedAt = now
+	}
+	return r.keysOrError()
+}
+
+func (r *remoteJwks) fetchKeys(goContext gocontext.Context, ctx context.RequestContext) ([]jwk, error) {
+	request, err := http.NewRequestWithContext(goContext, http.MethodGet, r.uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := r.httpClient(ctx).DoWithTracing(ctx, request, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if http.StatusOK != response.StatusCode {
+		return nil, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseJwks(body)
+}
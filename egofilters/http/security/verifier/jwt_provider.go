@@ -0,0 +1,389 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	securityhttp "github.com/grab/ego/egofilters/http/security/http"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+)
+
+const (
+	defaultJwtClockSkew = 60 * time.Second
+	bearerPrefix        = "bearer "
+)
+
+var (
+	// ErrMalformedJwt ...
+	ErrMalformedJwt = errors.New("malformed JWT")
+	// ErrJwtKeyNotFound ...
+	ErrJwtKeyNotFound = errors.New("no matching key for JWT")
+	// ErrJwtInvalidSignature ...
+	ErrJwtInvalidSignature = errors.New("invalid JWT signature")
+	// ErrJwtInvalidClaims ...
+	ErrJwtInvalidClaims = errors.New("invalid JWT claims")
+)
+
+// jwtAlgorithms maps the supported algorithms to whether a key can be used
+// with them.
+var jwtAlgorithms = map[string]func(key interface{}) bool{
+	"RS256": func(key interface{}) bool { _, ok := key.(*rsa.PublicKey); return ok },
+	"ES256": func(key interface{}) bool { _, ok := key.(*ecdsa.PublicKey); return ok },
+	"HS256": func(key interface{}) bool { _, ok := key.([]byte); return ok },
+	"EdDSA": func(key interface{}) bool { _, ok := key.(ed25519.PublicKey); return ok },
+}
+
+// CreateJwtProvider ...
+func CreateJwtProvider(provider *pb.JwtProvider) (*jwtProvider, error) {
+	client := &http.Client{
+		Timeout: jwksFetchTimeout,
+	}
+	return createJwtProvider(provider, securityhttp.NewHttpClientWithCtx(client), getCurrentTime)
+}
+
+func createJwtProvider(
+	provider *pb.JwtProvider, client securityhttp.HttpClientWithCtx, getCurrentTime getCurrentTimeOpt) (*jwtProvider, error) {
+	v := &jwtProvider{
+		provider:       provider,
+		algorithms:     map[string]struct{}{},
+		clockSkew:      defaultJwtClockSkew,
+		getCurrentTime: getCurrentTime,
+	}
+
+	for _, alg := range provider.Algorithms {
+		v.algorithms[alg] = struct{}{}
+	}
+	if 0 == len(v.algorithms) {
+		for alg := range jwtAlgorithms {
+			v.algorithms[alg] = struct{}{}
+		}
+	}
+	if 0 != provider.ClockSkewSeconds {
+		v.clockSkew = time.Duration(provider.ClockSkewSeconds) * time.Second
+	}
+
+	switch provider.GetJwksSource().(type) {
+	case *pb.JwtProvider_LocalJwks:
+		keys, err := parseJwks([]byte(provider.GetLocalJwks()))
+		if err != nil {
+			return nil, err
+		}
+		v.localKeys = keys
+	case *pb.JwtProvider_RemoteJwks:
+		remote := provider.GetRemoteJwks()
+		v.remoteKeys = &remoteJwks{
+			uri:            remote.Uri,
+			cluster:        remote.Cluster,
+			cacheDuration:  defaultJwksCacheDuration,
+			client:         client,
+			getCurrentTime: getCurrentTime,
+		}
+		if 0 != remote.CacheDurationSeconds {
+			v.remoteKeys.cacheDuration = time.Duration(remote.CacheDurationSeconds) * time.Second
+		}
+	default:
+		return nil, ErrInvalidJwks
+	}
+	return v, nil
+}
+
+type jwtProvider struct {
+	baseProvider
+	provider       *pb.JwtProvider
+	algorithms     map[string]struct{}
+	clockSkew      time.Duration
+	localKeys      []jwk
+	remoteKeys     *remoteJwks
+	getCurrentTime getCurrentTimeOpt
+}
+
+// jwtHeader is the JOSE header of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (v *jwtProvider) Verify(ctx context.RequestContext) {
+	token, ok := bearerToken(ctx.Headers())
+	if !ok {
+		ctx.Logger().Debug("[Verify] no bearer token.")
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	parts := strings.Split(token, ".")
+	if 3 != len(parts) {
+		ctx.Logger().Debug("[Verify] invalid JWT. Expected 3 parts.", len(parts))
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	header := jwtHeader{}
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		ctx.Logger().Debug("[Verify] can't decode JWT header.", err)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+	if _, ok := v.algorithms[header.Alg]; !ok {
+		ctx.Logger().Debug("[Verify] JWT algorithm not allowed.", header.Alg)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		ctx.Logger().Debug("[Verify] can't decode JWT signature.", err)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	keys, err := v.getKeys(ctx, false)
+	if err != nil {
+		ctx.Logger().Error("[Verify] can't get JWKS.", err)
+		v.reportInternalError(ctx)
+		return
+	}
+	signingInput := []byte(token[:len(parts[0])+1+len(parts[1])])
+	err = verifyJwtSignature(keys, &header, signingInput, signature)
+	if err == ErrJwtKeyNotFound && nil != v.remoteKeys {
+		// the keys may have been rotated
+		if keys, err = v.getKeys(ctx, true); err != nil {
+			ctx.Logger().Error("[Verify] can't get JWKS.", err)
+			v.reportInternalError(ctx)
+			return
+		}
+		err = verifyJwtSignature(keys, &header, signingInput, signature)
+	}
+	if err != nil {
+		ctx.Logger().Debug("[Verify] can't verify JWT signature.", err)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeJwtPart(parts[1], &claims); err != nil {
+		ctx.Logger().Debug("[Verify] can't decode JWT claims.", err)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+	expiresAt, err := v.validateClaims(claims)
+	if err != nil {
+		ctx.Logger().Debug("[Verify] JWT claims rejected.", err)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	resp := context.AuthResponseOK()
+	resp.ExpiresAt = expiresAt
+	resp.HeadersToSet = make(map[string]string)
+	resp.HeadersToRemove = make(map[string]struct{})
+	for _, h := range v.provider.ClaimToHeaders {
+		resp.HeadersToRemove[h.Header] = struct{}{}
+		if value, ok := claimString(claims[h.Claim]); ok {
+			resp.HeadersToSet[h.Header] = value
+		}
+	}
+
+	resp.FilterState = make(map[string]string)
+	for _, claim := range v.provider.ClaimsToFilterState {
+		if value, ok := claimString(claims[claim]); ok {
+			resp.FilterState[claim] = value
+		}
+	}
+	ctx.Callbacks().OnComplete(resp)
+}
+
+func (v *jwtProvider) WriteCacheKey(ctx context.RequestContext, w io.Writer) bool {
+	io.WriteString(w, ctx.Headers().Authorization().Copy())
+	return true
+}
+
+func (v *jwtProvider) getKeys(ctx context.RequestContext, refresh bool) ([]jwk, error) {
+	if nil == v.remoteKeys {
+		return v.localKeys, nil
+	}
+	return v.remoteKeys.getKeys(ctx, refresh)
+}
+
+// validateClaims checks the registered claims iss, aud, exp and nbf. It
+// returns the time the token expires at, allowing for the clock skew, or
+// the zero time without an exp claim.
+func (v *jwtProvider) validateClaims(claims map[string]interface{}) (time.Time, error) {
+	if "" != v.provider.Issuer {
+		if iss, _ := claims["iss"].(string); iss != v.provider.Issuer {
+			return time.Time{}, ErrJwtInvalidClaims
+		}
+	}
+
+	if 0 != len(v.provider.Audiences) && !containsAudience(claims["aud"], v.provider.Audiences) {
+		return time.Time{}, ErrJwtInvalidClaims
+	}
+
+	now := v.getCurrentTime()
+	var expiresAt time.Time
+	if exp, ok := claims["exp"]; ok {
+		t, err := claimTime(exp)
+		if err != nil || !now.Before(t.Add(v.clockSkew)) {
+			return time.Time{}, ErrJwtInvalidClaims
+		}
+		expiresAt = t.Add(v.clockSkew)
+	}
+	if nbf, ok := claims["nbf"]; ok {
+		t, err := claimTime(nbf)
+		if err != nil || now.Add(v.clockSkew).Before(t) {
+			return time.Time{}, ErrJwtInvalidClaims
+		}
+	}
+	return expiresAt, nil
+}
+
+func (v *jwtProvider) reportInternalError(ctx context.RequestContext) {
+	ctx.Callbacks().OnComplete(context.AuthResponseError())
+}
+
+func (v *jwtProvider) reportUnauthorizedError(ctx context.RequestContext) {
+	ctx.Callbacks().OnComplete(context.AuthResponseUnauthorized())
+}
+
+// bearerToken returns the bearer token of the Authorization header.
+func bearerToken(headers envoy.RequestHeaderMap) (string, bool) {
+	authorization := headers.Authorization().Copy()
+	if len(authorization) <= len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(authorization[len(bearerPrefix):])
+	return token, "" != token
+}
+
+// decodeJwtPart decodes a base64url encoded JSON part of a JWT. Numbers are
+// decoded as json.Number.
+func decodeJwtPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return ErrMalformedJwt
+	}
+	return nil
+}
+
+// verifyJwtSignature tries the keys usable with the algorithm of the header.
+// A key ID in the header only matches keys with the same or without ID.
+func verifyJwtSignature(keys []jwk, header *jwtHeader, signingInput, signature []byte) error {
+	usable := jwtAlgorithms[header.Alg]
+	found := false
+	for _, k := range keys {
+		if "" != k.alg && k.alg != header.Alg {
+			continue
+		}
+		if "" != header.Kid && "" != k.kid && k.kid != header.Kid {
+			continue
+		}
+		if !usable(k.key) {
+			continue
+		}
+		found = true
+		if verifySignature(header.Alg, k.key, signingInput, signature) {
+			return nil
+		}
+	}
+	if !found {
+		return ErrJwtKeyNotFound
+	}
+	return ErrJwtInvalidSignature
+}
+
+func verifySignature(alg string, key interface{}, signingInput, signature []byte) bool {
+	switch alg {
+	case "RS256":
+		hashed := sha256.Sum256(signingInput)
+		return nil == rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, hashed[:], signature)
+	case "ES256":
+		if 64 != len(signature) {
+			return false
+		}
+		hashed := sha256.Sum256(signingInput)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), hashed[:], r, s)
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signingInput)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "EdDSA":
+		return ed25519.Verify(key.(ed25519.PublicKey), signingInput, signature)
+	}
+	return false
+}
+
+// containsAudience reports whether the aud claim, a string or an array of
+// strings, contains one of audiences.
+func containsAudience(aud interface{}, audiences []string) bool {
+	var values []interface{}
+	switch a := aud.(type) {
+	case string:
+		values = []interface{}{a}
+	case []interface{}:
+		values = a
+	}
+	for _, value := range values {
+		for _, audience := range audiences {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// claimTime converts a NumericDate claim to time.
+func claimTime(claim interface{}) (time.Time, error) {
+	n, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, ErrJwtInvalidClaims
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), nil
+}
+
+// claimString converts a string, number or boolean claim to string.
+func claimString(claim interface{}) (string, bool) {
+	switch c := claim.(type) {
+	case string:
+		return c, true
+	case json.Number:
+		return c.String(), true
+	case bool:
+		return strconv.FormatBool(c), true
+	}
+	return "", false
+}
@@ -0,0 +1,522 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	gocontext "context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/logger"
+	"github.com/grab/ego/ego/src/go/volatile"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	securityhttp "github.com/grab/ego/egofilters/http/security/http"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+var jwtTestNow = time.Unix(1600000000, 0)
+
+func jwtTestTime() time.Time {
+	return jwtTestNow
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signJwt creates a JWT for the header and claims, signed with key.
+func signJwt(t *testing.T, header, claims map[string]interface{}, key interface{}) string {
+	h, err := json.Marshal(header)
+	require.Nil(t, err)
+	c, err := json.Marshal(claims)
+	require.Nil(t, err)
+	signingInput := b64(h) + "." + b64(c)
+
+	var signature []byte
+	hashed := sha256.Sum256([]byte(signingInput))
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, hashed[:])
+		require.Nil(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, hashed[:])
+		require.Nil(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signingInput))
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	}
+	return signingInput + "." + b64(signature)
+}
+
+type jwtTestKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+	secret  []byte
+	// prepended to the key IDs
+	kidPrefix string
+}
+
+func newJwtTestKeys(t *testing.T) *jwtTestKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	return &jwtTestKeys{rsa: rsaKey, ec: ecKey, ed25519: edKey, secret: []byte("my-hmac-secret")}
+}
+
+// jwks returns the JWKS JSON of the public keys.
+func (k *jwtTestKeys) jwks(t *testing.T) string {
+	keys := []map[string]string{
+		{"kty": "RSA", "kid": k.kidPrefix + "rsa", "use": "sig",
+			"n": b64(k.rsa.N.Bytes()), "e": b64(big.NewInt(int64(k.rsa.E)).Bytes())},
+		{"kty": "EC", "kid": k.kidPrefix + "ec", "crv": "P-256",
+			"x": b64(k.ec.X.Bytes()), "y": b64(k.ec.Y.Bytes())},
+		{"kty": "OKP", "kid": k.kidPrefix + "ed25519", "crv": "Ed25519",
+			"x": b64(k.ed25519.Public().(ed25519.PublicKey))},
+		{"kty": "oct", "kid": k.kidPrefix + "hmac", "alg": "HS256", "k": b64(k.secret)},
+		// keys for encryption are ignored
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "", "e": ""},
+	}
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.Nil(t, err)
+	return string(data)
+}
+
+// newBearerTestContext returns a RequestContext with the Authorization
+// header, for the providers of bearer tokens, and the response it completes
+// with.
+func newBearerTestContext(authorization string) (*contextmocks.RequestContext, *context.AuthResponse) {
+	return newBearerTestContextWithGoContext(gocontext.Background(), authorization)
+}
+
+func newBearerTestContextWithGoContext(goContext gocontext.Context, authorization string) (*contextmocks.RequestContext, *context.AuthResponse) {
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("GoContext").Return(goContext)
+	ctx.On("Logger").Return(logger.NewLogger("BearerLogger", egomocks.NativeLogger{}))
+
+	headerMap := &envoymocks.RequestHeaderMap{}
+	headerMap.On("Authorization").Return(volatile.String(authorization))
+	headerMap.On("Get", requestIDHeader).Return(volatile.String("request-id1"))
+	ctx.On("Headers").Return(headerMap)
+
+	authResp := &context.AuthResponse{}
+	callbacks := &contextmocks.Callbacks{}
+	callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+		*authResp = args[0].(context.AuthResponse)
+	})
+	ctx.On("Callbacks").Return(callbacks)
+	return ctx, authResp
+}
+
+func TestCreateJwtProvider(t *testing.T) {
+	keys := newJwtTestKeys(t)
+
+	provider, err := CreateJwtProvider(&pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_LocalJwks{LocalJwks: keys.jwks(t)},
+	})
+	require.Nil(t, err)
+	assert.Len(t, provider.localKeys, 4)
+	assert.Len(t, provider.algorithms, 4)
+	assert.Equal(t, defaultJwtClockSkew, provider.clockSkew)
+	assert.NotNil(t, provider.getCurrentTime())
+
+	_, err = CreateJwtProvider(&pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_LocalJwks{LocalJwks: `{"keys": [{"kty": "EC", "crv": "P-384"}]}`},
+	})
+	assert.NotNil(t, err)
+
+	_, err = CreateJwtProvider(&pb.JwtProvider{})
+	assert.Equal(t, ErrInvalidJwks, err)
+}
+
+func TestJwtVerify(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	settings := &pb.JwtProvider{
+		Issuer:     "https://issuer.example.com",
+		Audiences:  []string{"service1", "service2"},
+		Algorithms: []string{"RS256", "ES256", "HS256", "EdDSA"},
+		JwksSource: &pb.JwtProvider_LocalJwks{LocalJwks: keys.jwks(t)},
+		ClaimToHeaders: []*pb.JwtClaimToHeader{
+			{Claim: "sub", Header: "x-jwt-sub"},
+			{Claim: "admin", Header: "x-jwt-admin"},
+			{Claim: "missing", Header: "x-jwt-missing"},
+		},
+		ClaimsToFilterState: []string{"sub", "level"},
+	}
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   "https://issuer.example.com",
+			"aud":   []string{"other", "service2"},
+			"sub":   "user1",
+			"admin": true,
+			"level": 3,
+			"exp":   jwtTestNow.Add(time.Hour).Unix(),
+			"nbf":   jwtTestNow.Unix(),
+		}
+		for k, v := range overrides {
+			if nil == v {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	okResponse := context.AuthResponseOK()
+	okResponse.HeadersToSet = map[string]string{"x-jwt-sub": "user1", "x-jwt-admin": "true"}
+	okResponse.HeadersToRemove = map[string]struct{}{"x-jwt-sub": {}, "x-jwt-admin": {}, "x-jwt-missing": {}}
+	okResponse.FilterState = map[string]string{"sub": "user1", "level": "3"}
+	okResponse.ExpiresAt = jwtTestNow.Add(time.Hour + defaultJwtClockSkew)
+	expiringResponse := okResponse
+	expiringResponse.ExpiresAt = jwtTestNow.Add(defaultJwtClockSkew - time.Second)
+
+	tcs := []struct {
+		name          string
+		authorization string
+		authResponse  context.AuthResponse
+	}{
+		{
+			name:          "RS256",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, claims(nil), keys.rsa),
+			authResponse:  okResponse,
+		},
+		{
+			name:          "ES256 without kid",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "ES256"}, claims(nil), keys.ec),
+			authResponse:  okResponse,
+		},
+		{
+			name:          "EdDSA",
+			authorization: "bearer " + signJwt(t, map[string]interface{}{"alg": "EdDSA", "kid": "ed25519"}, claims(nil), keys.ed25519),
+			authResponse:  okResponse,
+		},
+		{
+			name:          "HS256",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "HS256", "kid": "hmac"}, claims(nil), keys.secret),
+			authResponse:  okResponse,
+		},
+		{
+			name:          "expiry within clock skew",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, claims(map[string]interface{}{"exp": jwtTestNow.Add(-time.Second).Unix()}), keys.rsa),
+			authResponse:  expiringResponse,
+		},
+		{
+			name:          "missing authorization header",
+			authorization: "",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "not a bearer token",
+			authorization: "partner_id1:signature1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "malformed token",
+			authorization: "Bearer abc.def",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "unsupported algorithm",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "none"}, claims(nil), nil),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "key ID mismatch",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256", "kid": "other"}, claims(nil), keys.rsa),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "wrong key",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "HS256"}, claims(nil), []byte("wrong")),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "wrong issuer",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, claims(map[string]interface{}{"iss": "https://other.example.com"}), keys.rsa),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "wrong audience",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, claims(map[string]interface{}{"aud": "other"}), keys.rsa),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "missing audience",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, claims(map[string]interface{}{"aud": nil}), keys.rsa),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "expired",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, claims(map[string]interface{}{"exp": jwtTestNow.Add(-2 * time.Minute).Unix()}), keys.rsa),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "not yet valid",
+			authorization: "Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, claims(map[string]interface{}{"nbf": jwtTestNow.Add(2 * time.Minute).Unix()}), keys.rsa),
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+	}
+
+	provider, err := createJwtProvider(settings, nil, jwtTestTime)
+	require.Nil(t, err)
+
+	for _, val := range tcs {
+		tc := val
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, authResp := newBearerTestContext(tc.authorization)
+
+			provider.Verify(ctx)
+
+			assert.False(t, provider.WithBody())
+			assert.Equal(t, tc.authResponse, *authResp)
+		})
+	}
+}
+
+func TestJwtVerifyDisallowedAlgorithm(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	settings := &pb.JwtProvider{
+		Algorithms: []string{"RS256"},
+		JwksSource: &pb.JwtProvider_LocalJwks{LocalJwks: keys.jwks(t)},
+	}
+	provider, err := createJwtProvider(settings, nil, jwtTestTime)
+	require.Nil(t, err)
+
+	ctx, authResp := newBearerTestContext("Bearer " + signJwt(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{}, keys.secret))
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthResponseUnauthorized(), *authResp)
+
+	ctx, authResp = newBearerTestContext("Bearer " + signJwt(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{}, keys.rsa))
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthOK, authResp.Status)
+}
+
+func TestJwtVerifyRemoteJwks(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	var jwks atomic.Value
+	jwks.Store(keys.jwks(t))
+	var fetches int32
+	var status int32 = http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		w.Write([]byte(jwks.Load().(string)))
+	}))
+	defer server.Close()
+
+	now := jwtTestNow
+	settings := &pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_RemoteJwks{RemoteJwks: &pb.RemoteJwks{Uri: server.URL}},
+	}
+	provider, err := createJwtProvider(settings, securityhttp.NewHttpClientWithCtx(server.Client()), func() time.Time { return now })
+	require.Nil(t, err)
+
+	verify := func(key interface{}, alg, kid string) context.AuthResponse {
+		token := signJwt(t, map[string]interface{}{"alg": alg, "kid": kid}, map[string]interface{}{"sub": "user1"}, key)
+		ctx, authResp := newBearerTestContext("Bearer " + token)
+		provider.Verify(ctx)
+		return *authResp
+	}
+
+	// keys are fetched once and cached
+	assert.Equal(t, context.AuthOK, verify(keys.rsa, "RS256", "rsa").Status)
+	assert.Equal(t, context.AuthOK, verify(keys.ec, "ES256", "ec").Status)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// an unknown key ID doesn't refetch recently fetched keys
+	rotated := newJwtTestKeys(t)
+	rotated.kidPrefix = "rotated-"
+	jwks.Store(rotated.jwks(t))
+	assert.Equal(t, context.AuthResponseUnauthorized(), verify(rotated.rsa, "RS256", "rotated-rsa"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// but refetches older keys
+	now = now.Add(jwksMinRefreshInterval)
+	assert.Equal(t, context.AuthOK, verify(rotated.rsa, "RS256", "rotated-rsa").Status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+
+	// cached keys are used if refreshing fails
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	now = now.Add(defaultJwksCacheDuration)
+	assert.Equal(t, context.AuthOK, verify(rotated.ec, "ES256", "rotated-ec").Status)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+}
+
+func TestJwtVerifyRemoteJwksUnavailable(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	now := jwtTestNow
+	settings := &pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_RemoteJwks{RemoteJwks: &pb.RemoteJwks{Uri: server.URL}},
+	}
+	provider, err := createJwtProvider(settings, securityhttp.NewHttpClientWithCtx(server.Client()), func() time.Time { return now })
+	require.Nil(t, err)
+
+	token := signJwt(t, map[string]interface{}{"alg": "RS256"}, map[string]interface{}{}, keys.rsa)
+	verify := func() context.AuthResponse {
+		ctx, authResp := newBearerTestContext("Bearer " + token)
+		provider.Verify(ctx)
+		return *authResp
+	}
+
+	assert.Equal(t, context.AuthResponseError(), verify())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	// fetching backs off after a failure
+	assert.Equal(t, context.AuthResponseError(), verify())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	now = now.Add(jwksMinRefreshInterval)
+	assert.Equal(t, context.AuthResponseError(), verify())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestJwtVerifyRemoteJwksConcurrently(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	var fetches int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		started <- struct{}{}
+		<-release
+		w.Write([]byte(keys.jwks(t)))
+	}))
+	defer server.Close()
+
+	settings := &pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_RemoteJwks{RemoteJwks: &pb.RemoteJwks{Uri: server.URL}},
+	}
+	provider, err := createJwtProvider(settings, securityhttp.NewHttpClientWithCtx(server.Client()), jwtTestTime)
+	require.Nil(t, err)
+
+	token := signJwt(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, map[string]interface{}{}, keys.rsa)
+	authResps := make([]*context.AuthResponse, 3)
+	var wg sync.WaitGroup
+	for i := range authResps {
+		var ctx *contextmocks.RequestContext
+		ctx, authResps[i] = newBearerTestContext("Bearer " + token)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider.Verify(ctx)
+		}()
+	}
+	<-started
+
+	// a canceled request stops waiting, without failing the fetch
+	goContext, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	ctx, authResp := newBearerTestContextWithGoContext(goContext, "Bearer "+token)
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthResponseError(), *authResp)
+
+	close(release)
+	wg.Wait()
+	for _, authResp := range authResps {
+		assert.Equal(t, context.AuthOK, authResp.Status)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestJwtVerifyRemoteJwksFetchingRequestCanceled(t *testing.T) {
+	keys := newJwtTestKeys(t)
+	var fetches int32
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		started <- struct{}{}
+		select {
+		case <-release:
+			w.Write([]byte(keys.jwks(t)))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	// the second request reads the time while it finds the fetch of the first
+	var calls int32
+	waiting := make(chan struct{})
+	getCurrentTime := func() time.Time {
+		if 2 == atomic.AddInt32(&calls, 1) {
+			close(waiting)
+		}
+		return jwtTestNow
+	}
+	settings := &pb.JwtProvider{
+		JwksSource: &pb.JwtProvider_RemoteJwks{RemoteJwks: &pb.RemoteJwks{Uri: server.URL}},
+	}
+	provider, err := createJwtProvider(settings, securityhttp.NewHttpClientWithCtx(server.Client()), getCurrentTime)
+	require.Nil(t, err)
+
+	token := signJwt(t, map[string]interface{}{"alg": "RS256", "kid": "rsa"}, map[string]interface{}{}, keys.rsa)
+	goContext, cancel := gocontext.WithCancel(gocontext.Background())
+	fetchingCtx, fetchingAuthResp := newBearerTestContextWithGoContext(goContext, "Bearer "+token)
+	fetchingDone := make(chan struct{})
+	go func() {
+		defer close(fetchingDone)
+		provider.Verify(fetchingCtx)
+	}()
+	<-started
+
+	waitingCtx, waitingAuthResp := newBearerTestContext("Bearer " + token)
+	waitingDone := make(chan struct{})
+	go func() {
+		defer close(waitingDone)
+		provider.Verify(waitingCtx)
+	}()
+	<-waiting
+
+	// the waiting request fetches again, rather than backing off
+	cancel()
+	<-fetchingDone
+	assert.Equal(t, context.AuthResponseError(), *fetchingAuthResp)
+	<-started
+	close(release)
+	<-waitingDone
+	assert.Equal(t, context.AuthOK, waitingAuthResp.Status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
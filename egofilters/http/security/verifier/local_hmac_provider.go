@@ -0,0 +1,185 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+)
+
+const (
+	defaultHMACMaxClockSkew = 5 * time.Minute
+)
+
+// CreateLocalHMACProvider ...
+func CreateLocalHMACProvider(provider *pb.LocalHMACProvider) (*localHMACProvider, error) {
+	return createLocalHMACProvider(provider, getCurrentTime)
+}
+
+func createLocalHMACProvider(provider *pb.LocalHMACProvider, getCurrentTime getCurrentTimeOpt) (*localHMACProvider, error) {
+	v := &localHMACProvider{
+		provider:       provider,
+		maxClockSkew:   defaultHMACMaxClockSkew,
+		getCurrentTime: getCurrentTime,
+	}
+	if 0 != provider.MaxClockSkewSeconds {
+		v.maxClockSkew = time.Duration(provider.MaxClockSkewSeconds) * time.Second
+	}
+	if nil != provider.ReplayProtection {
+		v.replays = newReplayGuard(provider.ReplayProtection, getCurrentTime)
+	}
+	return v, nil
+}
+
+type localHMACProvider struct {
+	baseProvider
+	provider       *pb.LocalHMACProvider
+	maxClockSkew   time.Duration
+	getCurrentTime getCurrentTimeOpt
+	// nil without replay protection
+	replays *replayGuard
+}
+
+func (v *localHMACProvider) Verify(ctx context.RequestContext) {
+	parts := strings.SplitN(ctx.Headers().Authorization().Copy(), ":", 3)
+	if 2 != len(parts) {
+		ctx.Logger().Debug("[Verify] invalid token length. Expected length of 2.", len(parts))
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	if nil != v.replays {
+		v.replays.verifyOnce(ctx, parts[0]+":"+parts[1], func(ctx context.RequestContext) {
+			v.checkHMACSignature(ctx, parts)
+		})
+		return
+	}
+
+	v.checkHMACSignature(ctx, parts)
+}
+
+func (v *localHMACProvider) checkHMACSignature(ctx context.RequestContext, parts []string) {
+	userID := parts[0]
+	signature, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		ctx.Logger().Debug("[Verify] can't decode signature.", err)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	date := ctx.Headers().Get("Date").Copy()
+	signedTime, err := http.ParseTime(date)
+	if err != nil {
+		ctx.Logger().Debug("[Verify] invalid Date header.", date)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+	if skew := v.getCurrentTime().Sub(signedTime); skew > v.maxClockSkew || skew < -v.maxClockSkew {
+		ctx.Logger().Debug("[Verify] Date header out of allowed clock skew.", date)
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	secret := ctx.GetSecret(v.provider.SecretKey)
+	if "" == secret {
+		ctx.Logger().Error("[Verify] missing HMAC secret.", v.provider.SecretKey)
+		v.reportInternalError(ctx)
+		return
+	}
+
+	bodyHash := sha256.New()
+	if body := ctx.BodyReader(); body != nil {
+		if _, err := io.Copy(bodyHash, body); err != nil {
+			ctx.Logger().Error("[Verify] can't read request body.", err)
+			v.reportInternalError(ctx)
+			return
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	writeSignedFields(mac, userID, ctx.Headers().Method().Copy(), ctx.Headers().Path().Copy(),
+		date, ctx.Headers().ContentType().Copy(), hex.EncodeToString(bodyHash.Sum(nil)))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		ctx.Logger().Debug("[Verify] invalid signature.")
+		v.reportUnauthorizedError(ctx)
+		return
+	}
+
+	supportedHeaders := map[string]string{
+		userIDHeader: userID,
+	}
+
+	resp := context.AuthResponseOK()
+	resp.ExpiresAt = signedTime.Add(v.maxClockSkew)
+	resp.HeadersToSet = make(map[string]string)
+	resp.HeadersToRemove = make(map[string]struct{})
+	for _, h := range v.provider.GeneratedUpstreamHeaders {
+		if v, ok := supportedHeaders[h]; ok {
+			resp.HeadersToSet[h] = v
+			resp.HeadersToRemove[h] = struct{}{}
+		}
+	}
+
+	resp.FilterState = map[string]string{
+		hmacUserIDSessionKey: userID,
+	}
+	ctx.Callbacks().OnComplete(resp)
+}
+
+func (v *localHMACProvider) reportInternalError(ctx context.RequestContext) {
+	ctx.Callbacks().OnComplete(context.AuthResponseError())
+}
+
+func (v *localHMACProvider) reportUnauthorizedError(ctx context.RequestContext) {
+	ctx.Callbacks().OnComplete(context.AuthResponseUnauthorized())
+}
+
+func (v *localHMACProvider) WithBody() bool {
+	return true
+}
+
+// StreamsBody is true as only the hash of the body is needed.
+func (v *localHMACProvider) StreamsBody() bool {
+	return true
+}
+
+func (v *localHMACProvider) WriteCacheKey(ctx context.RequestContext, w io.Writer) bool {
+	// a cached result would let replays pass
+	if nil != v.replays {
+		return false
+	}
+	return writeHMACCacheKey(ctx, w)
+}
+
+// writeHMACCacheKey writes the signed parts of an HMAC authenticated request.
+func writeHMACCacheKey(ctx context.RequestContext, w io.Writer) bool {
+	headers := ctx.Headers()
+	writeSignedFields(w, headers.Authorization().Copy(), headers.Method().Copy(), headers.Path().Copy(),
+		headers.Get("Date").Copy(), headers.ContentType().Copy())
+	if body := ctx.BodyReader(); body != nil {
+		if _, err := io.Copy(w, body); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writeSignedFields writes the signed fields, each followed by a newline.
+func writeSignedFields(w io.Writer, fields ...string) {
+	for _, field := range fields {
+		io.WriteString(w, field)
+		io.WriteString(w, "\n")
+	}
+}
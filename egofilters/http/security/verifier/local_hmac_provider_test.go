@@ -0,0 +1,244 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"bytes"
+	gocontext "context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grab/ego/ego/src/go/logger"
+	"github.com/grab/ego/ego/src/go/volatile"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+// hmacTestSignature signs the fields and the SHA-256 of body, each followed
+// by a newline.
+func hmacTestSignature(secret string, fieldsAndBody ...string) string {
+	last := len(fieldsAndBody) - 1
+	bodyHash := sha256.Sum256([]byte(fieldsAndBody[last]))
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, field := range append(fieldsAndBody[:last:last], hex.EncodeToString(bodyHash[:])) {
+		mac.Write([]byte(field + "\n"))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func localHMACSignature(secret, userID, method, path, date, contentType, body string) string {
+	return hmacTestSignature(secret, userID, method, path, date, contentType, body)
+}
+
+func TestCreateLocalHMACProvider(t *testing.T) {
+	provider, err := CreateLocalHMACProvider(&pb.LocalHMACProvider{SecretKey: "hmac_secret"})
+
+	require.Nil(t, err)
+	assert.NotNil(t, provider.getCurrentTime())
+	assert.Equal(t, defaultHMACMaxClockSkew, provider.maxClockSkew)
+	assert.True(t, provider.WithBody())
+
+	provider, err = CreateLocalHMACProvider(&pb.LocalHMACProvider{SecretKey: "hmac_secret", MaxClockSkewSeconds: 30})
+
+	require.Nil(t, err)
+	assert.Equal(t, 30*time.Second, provider.maxClockSkew)
+}
+
+func TestLocalHMACVerify(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+	signature := localHMACSignature("secret1", "partner_id1", "POST", "/path1", date, "application/json", "request_body1")
+
+	okResponse := context.AuthResponseOK()
+	okResponse.HeadersToSet = map[string]string{"x-custom-userid": "partner_id1"}
+	okResponse.HeadersToRemove = map[string]struct{}{"x-custom-userid": {}}
+	okResponse.FilterState = map[string]string{"UserID": "partner_id1"}
+	okResponse.ExpiresAt = now.Add(defaultHMACMaxClockSkew)
+	earlierResponse := okResponse
+	earlierResponse.ExpiresAt = now
+
+	tcs := []struct {
+		name          string
+		authorization string
+		date          string
+		secret        string
+		body          string
+		authResponse  context.AuthResponse
+	}{
+		{
+			name:          "valid signature",
+			authorization: "partner_id1:" + signature,
+			date:          date,
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  okResponse,
+		},
+		{
+			name:          "Date header within clock skew",
+			authorization: "partner_id1:" + localHMACSignature("secret1", "partner_id1", "POST", "/path1", now.Add(-5*time.Minute).Format(http.TimeFormat), "application/json", "request_body1"),
+			date:          now.Add(-5 * time.Minute).Format(http.TimeFormat),
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  earlierResponse,
+		},
+		{
+			name:          "authorization header with only 1 part",
+			authorization: signature,
+			date:          date,
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "signature not base64 encoded",
+			authorization: "partner_id1:%%%",
+			date:          date,
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "other user id",
+			authorization: "partner_id2:" + signature,
+			date:          date,
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "modified body",
+			authorization: "partner_id1:" + signature,
+			date:          date,
+			secret:        "secret1",
+			body:          "request_body2",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "wrong secret",
+			authorization: "partner_id1:" + signature,
+			date:          date,
+			secret:        "secret2",
+			body:          "request_body1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "missing Date header",
+			authorization: "partner_id1:" + signature,
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "Date header out of clock skew",
+			authorization: "partner_id1:" + localHMACSignature("secret1", "partner_id1", "POST", "/path1", now.Add(6*time.Minute).Format(http.TimeFormat), "application/json", "request_body1"),
+			date:          now.Add(6 * time.Minute).Format(http.TimeFormat),
+			secret:        "secret1",
+			body:          "request_body1",
+			authResponse:  context.AuthResponseUnauthorized(),
+		},
+		{
+			name:          "missing secret",
+			authorization: "partner_id1:" + signature,
+			date:          date,
+			body:          "request_body1",
+			authResponse:  context.AuthResponseError(),
+		},
+	}
+
+	for _, val := range tcs {
+		tc := val
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := &contextmocks.RequestContext{}
+			ctx.On("GetSecret", "hmac_secret").Return(tc.secret)
+			ctx.On("BodyReader").Return(bytes.NewReader([]byte(tc.body)))
+			ctx.On("Logger").Return(logger.NewLogger("LocalHMACLogger", egomocks.NativeLogger{}))
+
+			headerMap := &envoymocks.RequestHeaderMap{}
+			headerMap.On("Authorization").Return(volatile.String(tc.authorization))
+			headerMap.On("Get", "Date").Return(volatile.String(tc.date))
+			headerMap.On("Method").Return(volatile.String("POST"))
+			headerMap.On("Path").Return(volatile.String("/path1"))
+			headerMap.On("ContentType").Return(volatile.String("application/json"))
+			ctx.On("Headers").Return(headerMap)
+
+			callbacks := &contextmocks.Callbacks{}
+			var authResp context.AuthResponse
+			callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+				authResp = args[0].(context.AuthResponse)
+			})
+			ctx.On("Callbacks").Return(callbacks)
+
+			settings := &pb.LocalHMACProvider{
+				SecretKey:                "hmac_secret",
+				GeneratedUpstreamHeaders: []string{"x-custom-userid"},
+			}
+			provider, _ := createLocalHMACProvider(settings, func() time.Time { return now })
+
+			provider.Verify(ctx)
+
+			callbacks.AssertNumberOfCalls(t, "OnComplete", 1)
+			assert.Equal(t, tc.authResponse, authResp)
+		})
+	}
+}
+
+func TestLocalHMACVerifyReplayed(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+	signature := localHMACSignature("secret1", "partner_id1", "POST", "/path1", date, "application/json", "request_body1")
+	settings := &pb.LocalHMACProvider{
+		SecretKey:        "hmac_secret",
+		ReplayProtection: &pb.HMACReplayProtection{},
+	}
+	provider, _ := createLocalHMACProvider(settings, func() time.Time { return now })
+
+	verify := func(body string) context.AuthResponse {
+		ctx := &contextmocks.RequestContext{}
+		ctx.On("GoContext").Return(gocontext.Background())
+		ctx.On("GetSecret", "hmac_secret").Return("secret1")
+		ctx.On("BodyReader").Return(bytes.NewReader([]byte(body)))
+		ctx.On("Logger").Return(logger.NewLogger("LocalHMACLogger", egomocks.NativeLogger{}))
+
+		headerMap := &envoymocks.RequestHeaderMap{}
+		headerMap.On("Authorization").Return(volatile.String("partner_id1:" + signature))
+		headerMap.On("Get", "Date").Return(volatile.String(date))
+		headerMap.On("Method").Return(volatile.String("POST"))
+		headerMap.On("Path").Return(volatile.String("/path1"))
+		headerMap.On("ContentType").Return(volatile.String("application/json"))
+		ctx.On("Headers").Return(headerMap)
+
+		callbacks := &contextmocks.Callbacks{}
+		var authResp context.AuthResponse
+		callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+			authResp = args[0].(context.AuthResponse)
+		})
+		ctx.On("Callbacks").Return(callbacks)
+
+		assert.False(t, provider.WriteCacheKey(ctx, nil))
+		provider.Verify(ctx)
+		callbacks.AssertNumberOfCalls(t, "OnComplete", 1)
+		return authResp
+	}
+
+	// a request failing verification doesn't make the valid one look replayed
+	assert.Equal(t, context.AuthResponseUnauthorized(), verify("request_body2"))
+	assert.Equal(t, context.AuthOK, verify("request_body1").Status)
+	assert.Equal(t, context.AuthResponseReplayed(), verify("request_body1"))
+}
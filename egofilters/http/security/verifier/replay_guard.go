@@ -0,0 +1,157 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"container/list"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+)
+
+const (
+	defaultReplayMaxClockSkew = 5 * time.Minute
+	defaultReplayMaxEntries   = 100000
+)
+
+var (
+	// ErrRequestExpired ...
+	ErrRequestExpired = errors.New("request date out of the allowed clock skew")
+	// ErrRequestReplayed ...
+	ErrRequestReplayed = errors.New("request signature seen before")
+)
+
+// replayGuard remembers the signatures of requests within the allowed clock
+// skew of their Date header. It is shared by all workers, so that a replay
+// is caught regardless of which worker handles it.
+type replayGuard struct {
+	maxClockSkew   time.Duration
+	maxEntries     int
+	getCurrentTime getCurrentTimeOpt
+
+	lock sync.Mutex
+	seen map[string]*list.Element
+	// oldest first
+	order *list.List
+}
+
+type seenSignature struct {
+	signature string
+	expiresAt time.Time
+}
+
+func newReplayGuard(settings *pb.HMACReplayProtection, getCurrentTime getCurrentTimeOpt) *replayGuard {
+	g := &replayGuard{
+		maxClockSkew:   defaultReplayMaxClockSkew,
+		maxEntries:     defaultReplayMaxEntries,
+		getCurrentTime: getCurrentTime,
+		seen:           make(map[string]*list.Element),
+		order:          list.New(),
+	}
+	if 0 != settings.MaxClockSkewSeconds {
+		g.maxClockSkew = time.Duration(settings.MaxClockSkewSeconds) * time.Second
+	}
+	if 0 != settings.MaxEntries {
+		g.maxEntries = int(settings.MaxEntries)
+	}
+	return g
+}
+
+// check rejects requests with a Date header out of the allowed clock skew
+// and signatures seen before. It returns the time the request was signed at.
+// The signature is only remembered once recorded.
+func (g *replayGuard) check(date, signature string) (time.Time, error) {
+	signedTime, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, ErrRequestExpired
+	}
+	now := g.getCurrentTime()
+	if skew := now.Sub(signedTime); skew > g.maxClockSkew || skew < -g.maxClockSkew {
+		return time.Time{}, ErrRequestExpired
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.seenBefore(now, signature) {
+		return time.Time{}, ErrRequestReplayed
+	}
+	return signedTime, nil
+}
+
+// record remembers the signature of a verified request signed at signedTime,
+// until the date gets out of the allowed clock skew. It rejects signatures
+// recorded since they were checked, by a concurrent request.
+func (g *replayGuard) record(signature string, signedTime time.Time) error {
+	now := g.getCurrentTime()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.seenBefore(now, signature) {
+		return ErrRequestReplayed
+	}
+
+	entry := &seenSignature{signature: signature, expiresAt: signedTime.Add(g.maxClockSkew)}
+	if element, ok := g.seen[signature]; ok {
+		element.Value = entry
+		g.order.MoveToBack(element)
+		return nil
+	}
+
+	g.seen[signature] = g.order.PushBack(entry)
+	for g.order.Len() > g.maxEntries {
+		g.remove(g.order.Front())
+	}
+	return nil
+}
+
+// verifyOnce verifies the request with verify unless its signature was seen
+// before, and records the signature once verified, so that requests failing
+// verification don't make the valid request look replayed.
+func (g *replayGuard) verifyOnce(ctx context.RequestContext, signature string, verify func(ctx context.RequestContext)) {
+	signedTime, err := g.check(ctx.Headers().Get("Date").Copy(), signature)
+	if err != nil {
+		ctx.Logger().Warn("[Verify] rejected replayed request.", err)
+		ctx.Callbacks().OnComplete(context.AuthResponseReplayed())
+		return
+	}
+
+	callbacks := ctx.Callbacks()
+	verify(&childRequestContext{
+		RequestContext: ctx,
+		callbacks: callbacksFunc(func(response context.AuthResponse) {
+			if response.Status == context.AuthOK {
+				if err := g.record(signature, signedTime); err != nil {
+					ctx.Logger().Warn("[Verify] rejected replayed request.", err)
+					response = context.AuthResponseReplayed()
+				}
+			}
+			callbacks.OnComplete(response)
+		}),
+		goContext:  ctx.GoContext(),
+		bodyReader: ctx.BodyReader(),
+	})
+}
+
+// seenBefore forgets the signatures out of the allowed clock skew, and
+// reports whether signature is remembered. g.lock must be held.
+func (g *replayGuard) seenBefore(now time.Time, signature string) bool {
+	for front := g.order.Front(); front != nil && !now.Before(front.Value.(*seenSignature).expiresAt); front = g.order.Front() {
+		g.remove(front)
+	}
+	_, ok := g.seen[signature]
+	return ok
+}
+
+func (g *replayGuard) remove(element *list.Element) {
+	g.order.Remove(element)
+	delete(g.seen, element.Value.(*seenSignature).signature)
+}
@@ -0,0 +1,155 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	gocontext "context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/grab/ego/ego/src/go/logger"
+	"github.com/grab/ego/ego/src/go/volatile"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	pb "github.com/grab/ego/egofilters/http/security/proto"
+
+	envoymocks "github.com/grab/ego/ego/test/go/mock/gen/envoy"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+	httpmocks "github.com/grab/ego/egofilters/mock/gen/http/security/http"
+)
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+	guard := newReplayGuard(&pb.HMACReplayProtection{MaxClockSkewSeconds: 60, MaxEntries: 2}, func() time.Time { return now })
+	check := func(date, signature string) error {
+		signedTime, err := guard.check(date, signature)
+		if err != nil {
+			return err
+		}
+		return guard.record(signature, signedTime)
+	}
+
+	assert.Nil(t, check(date, "user1:signature1"))
+	assert.Equal(t, ErrRequestReplayed, check(date, "user1:signature1"))
+	assert.Nil(t, check(date, "user1:signature2"))
+
+	// out of the allowed clock skew
+	assert.Equal(t, ErrRequestExpired, check("", "user1:signature3"))
+	assert.Equal(t, ErrRequestExpired, check(now.Add(-61*time.Second).Format(http.TimeFormat), "user1:signature3"))
+	assert.Equal(t, ErrRequestExpired, check(now.Add(61*time.Second).Format(http.TimeFormat), "user1:signature3"))
+
+	// the oldest signature is forgotten beyond max entries
+	assert.Nil(t, check(now.Add(-30*time.Second).Format(http.TimeFormat), "user1:signature3"))
+	assert.Nil(t, check(date, "user1:signature1"))
+	assert.Equal(t, ErrRequestReplayed, check(date, "user1:signature3"))
+
+	// signatures are forgotten once their date is out of the allowed clock skew
+	now = now.Add(30 * time.Second)
+	assert.Nil(t, check(now.Add(-60*time.Second).Format(http.TimeFormat), "user1:signature3"))
+	assert.Equal(t, ErrRequestReplayed, check(date, "user1:signature1"))
+
+	// signatures are remembered once recorded, the first concurrent request wins
+	signedTime, err := guard.check(date, "user1:signature4")
+	assert.Nil(t, err)
+	_, err = guard.check(date, "user1:signature4")
+	assert.Nil(t, err)
+	assert.Nil(t, guard.record("user1:signature4", signedTime))
+	assert.Equal(t, ErrRequestReplayed, guard.record("user1:signature4", signedTime))
+	assert.Equal(t, ErrRequestReplayed, check(date, "user1:signature4"))
+}
+
+func TestCreateReplayGuardDefaults(t *testing.T) {
+	guard := newReplayGuard(&pb.HMACReplayProtection{}, getCurrentTime)
+
+	assert.Equal(t, defaultReplayMaxClockSkew, guard.maxClockSkew)
+	assert.Equal(t, defaultReplayMaxEntries, guard.maxEntries)
+}
+
+func TestHMACVerifyReplayed(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	settings := &pb.CustomHMACProvider{
+		RequestValidationUrl: "https://custom-auth.example.com/xyz",
+		ReplayProtection:     &pb.HMACReplayProtection{},
+	}
+	provider, _ := createCustomHMACProvider(settings, &httpmocks.HttpClientWithCtx{}, func() time.Time { return now }, nil)
+
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("Logger").Return(logger.NewLogger("CustomHMACLogger", egomocks.NativeLogger{}))
+	headerMap := &envoymocks.RequestHeaderMap{}
+	headerMap.On("Authorization").Return(volatile.String("partner_id1:signature1"))
+	headerMap.On("Get", "Date").Return(volatile.String(now.Add(-time.Hour).Format(http.TimeFormat)))
+	ctx.On("Headers").Return(headerMap)
+
+	callbacks := &contextmocks.Callbacks{}
+	var authResp context.AuthResponse
+	callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+		authResp = args[0].(context.AuthResponse)
+	})
+	ctx.On("Callbacks").Return(callbacks)
+
+	provider.Verify(ctx)
+
+	// the validation service isn't called
+	assert.Equal(t, context.AuthResponseReplayed(), authResp)
+	assert.False(t, provider.WriteCacheKey(ctx, nil))
+}
+
+func TestHMACVerifyRecordsVerifiedRequests(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	settings := &pb.CustomHMACProvider{
+		RequestValidationUrl: "https://custom-auth.example.com/xyz",
+		ReplayProtection:     &pb.HMACReplayProtection{},
+	}
+	statusCodes := []int{http.StatusUnauthorized, http.StatusOK}
+	client := &httpmocks.HttpClientWithCtx{}
+	client.On("DoWithTracing", mock.Anything, mock.Anything, mock.Anything).Return(func(context.Context, *http.Request, string) *http.Response {
+		statusCode := statusCodes[0]
+		statusCodes = statusCodes[1:]
+		return &http.Response{StatusCode: statusCode, Body: ioutil.NopCloser(strings.NewReader(""))}
+	}, nil)
+	isValid := func(resp *http.Response) (bool, error) {
+		return http.StatusOK == resp.StatusCode, nil
+	}
+	provider, _ := createCustomHMACProvider(settings, client, func() time.Time { return now }, isValid)
+
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("GoContext").Return(gocontext.Background())
+	ctx.On("GetSecret", mock.Anything).Return("secret")
+	ctx.On("BodyReader").Return(nil)
+	ctx.On("Logger").Return(logger.NewLogger("CustomHMACLogger", egomocks.NativeLogger{}))
+	headerMap := &envoymocks.RequestHeaderMap{}
+	headerMap.On("Authorization").Return(volatile.String("partner_id1:signature1"))
+	headerMap.On("Path").Return(volatile.String("/foo"))
+	headerMap.On("Method").Return(volatile.String(http.MethodGet))
+	headerMap.On("ContentType").Return(volatile.String(""))
+	headerMap.On("Get", "Date").Return(volatile.String(now.Format(http.TimeFormat)))
+	headerMap.On("Get", mock.Anything).Return(volatile.String(""))
+	ctx.On("Headers").Return(headerMap)
+
+	callbacks := &contextmocks.Callbacks{}
+	var authResp context.AuthResponse
+	callbacks.On("OnComplete", mock.Anything).Run(func(args mock.Arguments) {
+		authResp = args[0].(context.AuthResponse)
+	})
+	ctx.On("Callbacks").Return(callbacks)
+
+	// a denied request doesn't make the signature look replayed
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthResponseUnauthorized(), authResp)
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthOK, authResp.Status)
+	provider.Verify(ctx)
+	assert.Equal(t, context.AuthResponseReplayed(), authResp)
+	client.AssertNumberOfCalls(t, "DoWithTracing", 2)
+}
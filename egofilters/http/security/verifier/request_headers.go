@@ -0,0 +1,140 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"strings"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/volatile"
+)
+
+// requestHeaders is a copy of the headers of a request, which stays valid
+// after the request is gone. It implements envoy.RequestHeaderMap.
+type requestHeaders struct {
+	entries []headerEntry
+}
+
+type headerEntry struct {
+	key   string
+	value string
+}
+
+// copyRequestHeaders copies all entries of headers, in order.
+func copyRequestHeaders(headers envoy.RequestHeaderMapReadOnly) *requestHeaders {
+	h := &requestHeaders{entries: make([]headerEntry, 0, headers.Size())}
+	headers.Iterate(func(key, value volatile.String) bool {
+		h.entries = append(h.entries, headerEntry{key: key.Copy(), value: value.Copy()})
+		return true
+	})
+	return h
+}
+
+func (h *requestHeaders) clone() *requestHeaders {
+	return &requestHeaders{entries: append([]headerEntry(nil), h.entries...)}
+}
+
+func (h *requestHeaders) Get(name string) volatile.String {
+	name = strings.ToLower(name)
+	for _, entry := range h.entries {
+		if entry.key == name {
+			return volatile.String(entry.value)
+		}
+	}
+	return ""
+}
+
+func (h *requestHeaders) GetAll(name string) []volatile.String {
+	name = strings.ToLower(name)
+	var values []volatile.String
+	for _, entry := range h.entries {
+		if entry.key == name {
+			values = append(values, volatile.String(entry.value))
+		}
+	}
+	return values
+}
+
+func (h *requestHeaders) Iterate(cb func(key, value volatile.String) bool) {
+	for _, entry := range h.entries {
+		if !cb(volatile.String(entry.key), volatile.String(entry.value)) {
+			return
+		}
+	}
+}
+
+func (h *requestHeaders) Size() uint64 {
+	return uint64(len(h.entries))
+}
+
+func (h *requestHeaders) ByteSize() uint64 {
+	var size uint64
+	for _, entry := range h.entries {
+		size += uint64(len(entry.key) + len(entry.value))
+	}
+	return size
+}
+
+func (h *requestHeaders) ContentType() volatile.String {
+	return h.Get("content-type")
+}
+
+func (h *requestHeaders) Path() volatile.String {
+	return h.Get(":path")
+}
+
+func (h *requestHeaders) Method() volatile.String {
+	return h.Get(":method")
+}
+
+func (h *requestHeaders) Authorization() volatile.String {
+	return h.Get("authorization")
+}
+
+func (h *requestHeaders) GetByPrefix(prefix string) map[string][]string {
+	result := make(map[string][]string)
+	for _, entry := range h.entries {
+		if strings.HasPrefix(entry.key, prefix) {
+			result[entry.key] = append(result[entry.key], entry.value)
+		}
+	}
+	return result
+}
+
+func (h *requestHeaders) AddCopy(name, value string) {
+	h.entries = append(h.entries, headerEntry{key: strings.ToLower(name), value: value})
+}
+
+func (h *requestHeaders) SetCopy(name, value string) {
+	h.Remove(name)
+	h.AddCopy(name, value)
+}
+
+func (h *requestHeaders) AppendCopy(name, value string) {
+	name = strings.ToLower(name)
+	for i, entry := range h.entries {
+		if entry.key == name {
+			h.entries[i].value += "," + value
+			return
+		}
+	}
+	h.AddCopy(name, value)
+}
+
+func (h *requestHeaders) Remove(name string) {
+	name = strings.ToLower(name)
+	entries := h.entries[:0]
+	for _, entry := range h.entries {
+		if entry.key != name {
+			entries = append(entries, entry)
+		}
+	}
+	h.entries = entries
+}
+
+func (h *requestHeaders) SetPath(path string) {
+	h.SetCopy(":path", path)
+}
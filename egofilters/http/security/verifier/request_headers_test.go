@@ -0,0 +1,57 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grab/ego/ego/src/go/volatile"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	original := &requestHeaders{}
+	original.AddCopy(":path", "/v1/resource")
+	original.AddCopy("X-Custom-A", "1")
+	original.AddCopy("x-custom-a", "2")
+	original.AddCopy("authorization", "Bearer token")
+
+	headers := copyRequestHeaders(original)
+	assert.Equal(t, original, headers)
+
+	assert.Equal(t, volatile.String("/v1/resource"), headers.Path())
+	assert.Equal(t, volatile.String("Bearer token"), headers.Authorization())
+	assert.Equal(t, volatile.String("1"), headers.Get("X-Custom-A"))
+	assert.Equal(t, []volatile.String{"1", "2"}, headers.GetAll("x-custom-a"))
+	assert.Equal(t, volatile.String(""), headers.Method())
+	assert.Nil(t, headers.GetAll("x-custom-b"))
+	assert.Equal(t, map[string][]string{"x-custom-a": {"1", "2"}}, headers.GetByPrefix("x-custom-"))
+	assert.Equal(t, uint64(4), headers.Size())
+	assert.Equal(t, uint64(len(":path/v1/resourcex-custom-a1x-custom-a2authorizationBearer token")), headers.ByteSize())
+
+	var keys []string
+	headers.Iterate(func(key, value volatile.String) bool {
+		keys = append(keys, string(key))
+		return len(keys) < 2
+	})
+	assert.Equal(t, []string{":path", "x-custom-a"}, keys)
+
+	clone := headers.clone()
+	clone.SetCopy("x-custom-a", "3")
+	clone.AppendCopy("x-custom-a", "4")
+	clone.AppendCopy("x-custom-b", "5")
+	clone.Remove("Authorization")
+	clone.SetPath("/v2/resource")
+	assert.Equal(t, []headerEntry{
+		{key: "x-custom-a", value: "3,4"},
+		{key: "x-custom-b", value: "5"},
+		{key: ":path", value: "/v2/resource"},
+	}, clone.entries)
+
+	// the copy is left alone
+	assert.Equal(t, original, headers)
+}
@@ -0,0 +1,280 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"bytes"
+	gocontext "context"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+)
+
+// requirementVerifier combines the results of several verifiers, which are
+// run concurrently on copies of the request. With all set, the results are
+// AND-ed, otherwise OR-ed.
+type requirementVerifier struct {
+	verifiers []Verifier
+	all       bool
+}
+
+// NewRequiresAny returns a Verifier that passes if any of verifiers passes.
+// It completes as soon as one of them passes, canceling the others. If all
+// fail, it reports the first denial, or the first error without denials. A
+// nil verifier stands for a missing provider and fails with an error.
+func NewRequiresAny(verifiers []Verifier) Verifier {
+	return &requirementVerifier{verifiers: verifiers}
+}
+
+// NewRequiresAll returns a Verifier that passes if all of verifiers pass. It
+// completes as soon as one of them denies the request, canceling the others.
+// Errors aren't decisive, since another verifier may still deny the request,
+// and the first error is reported if none does. A nil verifier stands for a
+// missing provider and fails with an error.
+func NewRequiresAll(verifiers []Verifier) Verifier {
+	return &requirementVerifier{verifiers: verifiers, all: true}
+}
+
+// NotRequired returns a Verifier that always passes. It stands for an empty
+// requirement nested in requires_any or requires_all.
+func NotRequired() Verifier {
+	return &notRequired{}
+}
+
+type notRequired struct {
+	baseProvider
+}
+
+func (v *notRequired) Verify(ctx context.RequestContext) {
+	ctx.Callbacks().OnComplete(context.AuthResponseOK())
+}
+
+func (v *requirementVerifier) WithBody() bool {
+	for _, verifier := range v.verifiers {
+		if verifier != nil && verifier.WithBody() {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *requirementVerifier) Verify(ctx context.RequestContext) {
+	// every verifier needs to read the body on its own
+	var body []byte
+	reader := ctx.BodyReader()
+	if reader != nil {
+		var err error
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			ctx.Logger().Error("[Verify] can not read the body.", err)
+			ctx.Callbacks().OnComplete(context.AuthResponseError())
+			return
+		}
+	}
+	// verifiers may still run after we completed and the request is gone
+	headers := copyRequestHeaders(ctx.Headers())
+
+	goContext, cancel := gocontext.WithCancel(ctx.GoContext())
+	defer cancel()
+
+	// every verifier completes at most once, so sending never blocks
+	results := make(chan requirementResult, len(v.verifiers))
+	for i, verifier := range v.verifiers {
+		i := i
+		if verifier == nil {
+			results <- requirementResult{i, context.AuthResponseError()}
+			continue
+		}
+
+		var once sync.Once
+		child := &childRequestContext{
+			RequestContext: ctx,
+			callbacks: callbacksFunc(func(response context.AuthResponse) {
+				once.Do(func() {
+					results <- requirementResult{i, response}
+				})
+			}),
+			goContext: goContext,
+			headers:   headers.clone(),
+		}
+		if reader != nil {
+			child.bodyReader = bytes.NewReader(body)
+		}
+		go verifier.Verify(child)
+	}
+
+	// Complete as soon as the outcome is known, i.e. on the first pass with
+	// any, and on the first denial with all. The remaining verifiers are
+	// canceled and their results dropped.
+	responses := make([]context.AuthResponse, len(v.verifiers))
+	for range v.verifiers {
+		result := <-results
+		if v.isDecisive(result.response) {
+			ctx.Callbacks().OnComplete(result.response)
+			return
+		}
+		responses[result.i] = result.response
+	}
+
+	// a denial is reported rather than an error, which might have been a
+	// denial otherwise
+	var firstError *context.AuthResponse
+	for i := range responses {
+		switch responses[i].Status {
+		case context.AuthDenied:
+			ctx.Callbacks().OnComplete(responses[i])
+			return
+		case context.AuthError:
+			if firstError == nil {
+				firstError = &responses[i]
+			}
+		}
+	}
+	if firstError != nil {
+		ctx.Callbacks().OnComplete(*firstError)
+		return
+	}
+	ctx.Callbacks().OnComplete(mergeAuthResponses(responses))
+}
+
+// isDecisive reports whether response decides the outcome regardless of the
+// other verifiers.
+func (v *requirementVerifier) isDecisive(response context.AuthResponse) bool {
+	if v.all {
+		return response.Status == context.AuthDenied
+	}
+	return response.Status == context.AuthOK
+}
+
+// requirementResult is the response of the i-th verifier of a
+// requirementVerifier.
+type requirementResult struct {
+	i        int
+	response context.AuthResponse
+}
+
+// NewRequirementSigner returns a Signer for a requirement combining the
+// providers of signers. The response is signed by the first of them requiring
+// it, i.e. by a provider that passed. Nil signers are skipped, and nil is
+// returned if there are none.
+func NewRequirementSigner(signers []Signer) Signer {
+	var nonNil []Signer
+	for _, signer := range signers {
+		if signer != nil {
+			nonNil = append(nonNil, signer)
+		}
+	}
+	if len(nonNil) == 0 {
+		return nil
+	}
+	return &requirementSigner{signers: nonNil}
+}
+
+type requirementSigner struct {
+	signers []Signer
+}
+
+func (s *requirementSigner) SigningRequired(headers envoy.ResponseHeaderMap, authResp context.AuthResponse) bool {
+	return s.find(headers, authResp) != nil
+}
+
+func (s *requirementSigner) Sign(ctx context.ResponseContext) {
+	signer := s.find(ctx.Headers(), ctx.AuthResponse())
+	if signer == nil {
+		// SigningRequired must have been checked before
+		ctx.Callbacks().OnCompleteSigning(context.SignResponse{})
+		return
+	}
+	signer.Sign(ctx)
+}
+
+func (s *requirementSigner) find(headers envoy.ResponseHeaderMap, authResp context.AuthResponse) Signer {
+	for _, signer := range s.signers {
+		if signer.SigningRequired(headers, authResp) {
+			return signer
+		}
+	}
+	return nil
+}
+
+// mergeAuthResponses merges the mutations of all passed responses, in order.
+func mergeAuthResponses(responses []context.AuthResponse) context.AuthResponse {
+	merged := context.AuthResponseOK()
+	for _, response := range responses {
+		if response.Status != context.AuthOK {
+			continue
+		}
+		for k := range response.HeadersToRemove {
+			if merged.HeadersToRemove == nil {
+				merged.HeadersToRemove = map[string]struct{}{}
+			}
+			merged.HeadersToRemove[k] = struct{}{}
+		}
+		for k, v := range response.HeadersToSet {
+			if merged.HeadersToSet == nil {
+				merged.HeadersToSet = map[string]string{}
+			}
+			merged.HeadersToSet[k] = v
+		}
+		for k, v := range response.HeadersToAppend {
+			if merged.HeadersToAppend == nil {
+				merged.HeadersToAppend = map[string]string{}
+			}
+			if prev, ok := merged.HeadersToAppend[k]; ok {
+				v = prev + "," + v
+			}
+			merged.HeadersToAppend[k] = v
+		}
+		for k, v := range response.FilterState {
+			if merged.FilterState == nil {
+				merged.FilterState = map[string]string{}
+			}
+			merged.FilterState[k] = v
+		}
+		if !response.ExpiresAt.IsZero() && (merged.ExpiresAt.IsZero() || response.ExpiresAt.Before(merged.ExpiresAt)) {
+			merged.ExpiresAt = response.ExpiresAt
+		}
+	}
+	return merged
+}
+
+type callbacksFunc func(context.AuthResponse)
+
+func (f callbacksFunc) OnComplete(response context.AuthResponse) {
+	f(response)
+}
+
+// childRequestContext is the RequestContext of a verifier combined by a
+// requirementVerifier.
+type childRequestContext struct {
+	context.RequestContext
+	callbacks  context.Callbacks
+	goContext  gocontext.Context
+	headers    envoy.RequestHeaderMap
+	bodyReader io.Reader
+}
+
+func (c *childRequestContext) Callbacks() context.Callbacks {
+	return c.callbacks
+}
+
+func (c *childRequestContext) GoContext() gocontext.Context {
+	return c.goContext
+}
+
+func (c *childRequestContext) Headers() envoy.RequestHeaderMap {
+	if c.headers == nil {
+		return c.RequestContext.Headers()
+	}
+	return c.headers
+}
+
+func (c *childRequestContext) BodyReader() io.Reader {
+	return c.bodyReader
+}
@@ -0,0 +1,333 @@
+// Copyright 2020-2021 Grabtaxi Holdings PTE LTE (GRAB), All rights reserved.
+//
+// Use of this source code is governed by the Apache License 2.0 that can be
+// found in the LICENSE file
+
+package verifier
+
+import (
+	"bytes"
+	gocontext "context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grab/ego/ego/src/go/envoy"
+	"github.com/grab/ego/ego/src/go/logger"
+	egomocks "github.com/grab/ego/ego/test/go/mock"
+
+	"github.com/grab/ego/egofilters/http/security/context"
+	contextmocks "github.com/grab/ego/egofilters/mock/gen/http/security/context"
+)
+
+// fakeVerifier completes with response, and again with again if set. If
+// block is set, it waits for the cancellation of the request instead. If hang
+// is set, it first waits for hang to be closed, ignoring the cancellation.
+type fakeVerifier struct {
+	baseProvider
+	response context.AuthResponse
+	again    *context.AuthResponse
+	block    bool
+	hang     chan struct{}
+
+	authorization string
+	body          []byte
+	canceled      bool
+	done          chan struct{}
+}
+
+func (v *fakeVerifier) Verify(ctx context.RequestContext) {
+	if v.done != nil {
+		defer close(v.done)
+	}
+	v.authorization = ctx.Headers().Authorization().Copy()
+	if reader := ctx.BodyReader(); reader != nil {
+		v.body, _ = ioutil.ReadAll(reader)
+	}
+	if v.hang != nil {
+		<-v.hang
+	}
+	if v.block {
+		<-ctx.GoContext().Done()
+		v.canceled = true
+		ctx.Callbacks().OnComplete(context.AuthResponseError())
+		return
+	}
+	ctx.Callbacks().OnComplete(v.response)
+	if v.again != nil {
+		ctx.Callbacks().OnComplete(*v.again)
+	}
+}
+
+// newRequirementTestContext returns a RequestContext completing with
+// expectedResponse, whose Authorization header is "Bearer token".
+func newRequirementTestContext(body io.Reader, expectedResponse context.AuthResponse) (*contextmocks.RequestContext, *contextmocks.Callbacks) {
+	callbacks := &contextmocks.Callbacks{}
+	callbacks.On("OnComplete", expectedResponse).Once()
+
+	headers := &requestHeaders{}
+	headers.AddCopy("authorization", "Bearer token")
+
+	ctx := &contextmocks.RequestContext{}
+	ctx.On("Callbacks").Return(callbacks)
+	ctx.On("GoContext").Return(gocontext.Background())
+	ctx.On("Headers").Return(headers)
+	ctx.On("BodyReader").Return(body)
+	ctx.On("Logger").Return(logger.NewLogger("RequirementLogger", egomocks.NativeLogger{}))
+	return ctx, callbacks
+}
+
+func authResponseOK(header, filterState string) context.AuthResponse {
+	response := context.AuthResponseOK()
+	response.HeadersToSet = map[string]string{header: "1"}
+	response.FilterState = map[string]string{filterState: "1"}
+	return response
+}
+
+func expiringAt(response context.AuthResponse, expiresAt time.Time) context.AuthResponse {
+	response.ExpiresAt = expiresAt
+	return response
+}
+
+func TestRequirementVerifier(t *testing.T) {
+	tcs := []struct {
+		name      string
+		all       bool
+		verifiers []*fakeVerifier
+		missing   bool
+
+		expectedResponse context.AuthResponse
+		expectedCanceled []bool
+	}{
+		{
+			name: "any should pass on the first success and cancel the others",
+			verifiers: []*fakeVerifier{
+				{block: true},
+				{response: authResponseOK("x-a", "a")},
+			},
+			expectedResponse: authResponseOK("x-a", "a"),
+			expectedCanceled: []bool{true, false},
+		},
+		{
+			name: "any should report the first failure if all fail",
+			verifiers: []*fakeVerifier{
+				{response: context.AuthResponseDenied(http.StatusForbidden)},
+				{response: context.AuthResponseUnauthorized()},
+			},
+			expectedResponse: context.AuthResponseDenied(http.StatusForbidden),
+			expectedCanceled: []bool{false, false},
+		},
+		{
+			name: "any should report a denial rather than an error",
+			verifiers: []*fakeVerifier{
+				{response: context.AuthResponseError()},
+				{response: context.AuthResponseDenied(http.StatusForbidden)},
+				{response: context.AuthResponseUnauthorized()},
+			},
+			expectedResponse: context.AuthResponseDenied(http.StatusForbidden),
+			expectedCanceled: []bool{false, false, false},
+		},
+		{
+			name: "all should merge the mutations of all providers",
+			all:  true,
+			verifiers: []*fakeVerifier{
+				{response: authResponseOK("x-a", "a")},
+				{response: authResponseOK("x-b", "b")},
+			},
+			expectedResponse: context.AuthResponse{
+				Status:       context.AuthOK,
+				StatusCode:   http.StatusOK,
+				HeadersToSet: map[string]string{"x-a": "1", "x-b": "1"},
+				FilterState:  map[string]string{"a": "1", "b": "1"},
+			},
+			expectedCanceled: []bool{false, false},
+		},
+		{
+			name: "all should expire with the first expiring credentials",
+			all:  true,
+			verifiers: []*fakeVerifier{
+				{response: expiringAt(context.AuthResponseOK(), time.Unix(1600000060, 0))},
+				{response: context.AuthResponseOK()},
+				{response: expiringAt(context.AuthResponseOK(), time.Unix(1600000030, 0))},
+			},
+			expectedResponse: expiringAt(context.AuthResponseOK(), time.Unix(1600000030, 0)),
+			expectedCanceled: []bool{false, false, false},
+		},
+		{
+			name: "all should fail on the first denial and cancel the others",
+			all:  true,
+			verifiers: []*fakeVerifier{
+				{block: true},
+				{response: context.AuthResponseUnauthorized()},
+			},
+			expectedResponse: context.AuthResponseUnauthorized(),
+			expectedCanceled: []bool{true, false},
+		},
+		{
+			name: "all should wait for a denial after an error",
+			all:  true,
+			verifiers: []*fakeVerifier{
+				{response: context.AuthResponseError()},
+				{response: authResponseOK("x-a", "a")},
+				{response: context.AuthResponseUnauthorized()},
+			},
+			expectedResponse: context.AuthResponseUnauthorized(),
+			expectedCanceled: []bool{false, false, false},
+		},
+		{
+			name: "all should report the first error without denials",
+			all:  true,
+			verifiers: []*fakeVerifier{
+				{response: authResponseOK("x-a", "a")},
+				{response: context.AuthResponseError()},
+			},
+			expectedResponse: context.AuthResponseError(),
+			expectedCanceled: []bool{false, false},
+		},
+		{
+			name: "all should fail for a missing provider",
+			all:  true,
+			verifiers: []*fakeVerifier{
+				{response: authResponseOK("x-a", "a")},
+			},
+			missing:          true,
+			expectedResponse: context.AuthResponseError(),
+			expectedCanceled: []bool{false},
+		},
+	}
+
+	for _, val := range tcs {
+		tc := val
+		t.Run(tc.name, func(t *testing.T) {
+			verifiers := make([]Verifier, 0, len(tc.verifiers)+1)
+			for _, v := range tc.verifiers {
+				v.done = make(chan struct{})
+				verifiers = append(verifiers, v)
+			}
+			if tc.missing {
+				verifiers = append(verifiers, nil)
+			}
+
+			var verifier Verifier
+			if tc.all {
+				verifier = NewRequiresAll(verifiers)
+			} else {
+				verifier = NewRequiresAny(verifiers)
+			}
+
+			ctx, callbacks := newRequirementTestContext(nil, tc.expectedResponse)
+
+			verifier.Verify(ctx)
+
+			callbacks.AssertExpectations(t)
+			for i, v := range tc.verifiers {
+				<-v.done
+				assert.Equal(t, tc.expectedCanceled[i], v.canceled)
+				assert.Equal(t, "Bearer token", v.authorization)
+			}
+		})
+	}
+}
+
+func TestRequirementVerifierShortCircuit(t *testing.T) {
+	hanging := &fakeVerifier{hang: make(chan struct{}), response: authResponseOK("x-a", "a"), done: make(chan struct{})}
+	passing := &fakeVerifier{response: authResponseOK("x-b", "b"), done: make(chan struct{})}
+	verifier := NewRequiresAny([]Verifier{hanging, passing})
+
+	ctx, callbacks := newRequirementTestContext(nil, authResponseOK("x-b", "b"))
+
+	// completes while the first verifier still runs, ignoring the cancellation
+	verifier.Verify(ctx)
+	callbacks.AssertExpectations(t)
+
+	// its late result is dropped
+	close(hanging.hang)
+	<-hanging.done
+	callbacks.AssertNumberOfCalls(t, "OnComplete", 1)
+}
+
+func TestRequirementVerifierCompletingTwice(t *testing.T) {
+	denied := context.AuthResponseDenied(http.StatusForbidden)
+	verifiers := []*fakeVerifier{
+		{response: authResponseOK("x-a", "a"), again: &denied, done: make(chan struct{})},
+		{response: authResponseOK("x-b", "b"), done: make(chan struct{})},
+	}
+	verifier := NewRequiresAll([]Verifier{verifiers[0], verifiers[1]})
+
+	ctx, callbacks := newRequirementTestContext(nil, context.AuthResponse{
+		Status:       context.AuthOK,
+		StatusCode:   http.StatusOK,
+		HeadersToSet: map[string]string{"x-a": "1", "x-b": "1"},
+		FilterState:  map[string]string{"a": "1", "b": "1"},
+	})
+
+	verifier.Verify(ctx)
+
+	callbacks.AssertExpectations(t)
+}
+
+func TestRequirementVerifierBody(t *testing.T) {
+	verifiers := []*fakeVerifier{
+		{response: context.AuthResponseOK(), done: make(chan struct{})},
+		{response: context.AuthResponseOK(), done: make(chan struct{})},
+	}
+	verifier := NewRequiresAll([]Verifier{verifiers[0], verifiers[1]})
+
+	ctx, callbacks := newRequirementTestContext(bytes.NewReader([]byte("body")), context.AuthResponseOK())
+
+	verifier.Verify(ctx)
+
+	callbacks.AssertExpectations(t)
+	for _, v := range verifiers {
+		assert.Equal(t, []byte("body"), v.body)
+	}
+}
+
+func TestRequirementVerifierWithBody(t *testing.T) {
+	assert.False(t, NewRequiresAny([]Verifier{NotRequired(), nil}).WithBody())
+	assert.True(t, NewRequiresAll([]Verifier{NotRequired(), &customHMACProvider{}}).WithBody())
+}
+
+// fakeSigner signs if required is set.
+type fakeSigner struct {
+	required bool
+	signed   bool
+}
+
+func (s *fakeSigner) SigningRequired(headers envoy.ResponseHeaderMap, authResp context.AuthResponse) bool {
+	return s.required
+}
+
+func (s *fakeSigner) Sign(ctx context.ResponseContext) {
+	s.signed = true
+	ctx.Callbacks().OnCompleteSigning(context.SignResponse{StatusCode: http.StatusOK})
+}
+
+func TestRequirementSigner(t *testing.T) {
+	assert.Nil(t, NewRequirementSigner([]Signer{nil, nil}))
+
+	skipped := &fakeSigner{}
+	assert.False(t, NewRequirementSigner([]Signer{skipped}).SigningRequired(nil, context.AuthResponseOK()))
+
+	signing := &fakeSigner{required: true}
+	signer := NewRequirementSigner([]Signer{nil, skipped, signing})
+	assert.True(t, signer.SigningRequired(nil, context.AuthResponseOK()))
+
+	callbacks := &contextmocks.ResponseCallbacks{}
+	callbacks.On("OnCompleteSigning", context.SignResponse{StatusCode: http.StatusOK})
+
+	ctx := &contextmocks.ResponseContext{}
+	ctx.On("Headers").Return(nil)
+	ctx.On("AuthResponse").Return(context.AuthResponseOK())
+	ctx.On("Callbacks").Return(callbacks)
+
+	signer.Sign(ctx)
+
+	callbacks.AssertExpectations(t)
+	assert.False(t, skipped.signed)
+	assert.True(t, signing.signed)
+}
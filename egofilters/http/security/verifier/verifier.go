@@ -6,6 +6,8 @@
 package verifier
 
 import (
+	"io"
+
 	"github.com/grab/ego/ego/src/go/envoy"
 
 	"github.com/grab/ego/egofilters/http/security/context"
@@ -17,6 +19,26 @@ type Verifier interface {
 	WithBody() bool
 }
 
+// Cacheable is implemented by verifiers whose results can be cached.
+type Cacheable interface {
+	// WriteCacheKey writes all parts of the request the result depends on.
+	// Results are not cached if it returns false.
+	WriteCacheKey(ctx context.RequestContext, w io.Writer) bool
+}
+
+// Streaming is implemented by verifiers reading the body only once and in
+// order, e.g. to hash it. Such verifiers are started before the whole body
+// arrived, and read it as a stream of chunks rather than from a buffer.
+type Streaming interface {
+	StreamsBody() bool
+}
+
+// StreamsBody reports whether v reads the body as a stream.
+func StreamsBody(v Verifier) bool {
+	streaming, ok := v.(Streaming)
+	return ok && streaming.StreamsBody()
+}
+
 // Signer ...
 type Signer interface {
 	// Clients have to check if SigningRequired before calling Sign.
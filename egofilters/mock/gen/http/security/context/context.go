@@ -27,3 +27,19 @@ func (_m *Context) ActiveSpan() envoy.Span {
 
 	return r0
 }
+
+// AsyncClient provides a mock function with given fields:
+func (_m *Context) AsyncClient() envoy.AsyncClient {
+	ret := _m.Called()
+
+	var r0 envoy.AsyncClient
+	if rf, ok := ret.Get(0).(func() envoy.AsyncClient); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(envoy.AsyncClient)
+		}
+	}
+
+	return r0
+}
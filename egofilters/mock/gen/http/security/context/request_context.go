@@ -37,6 +37,22 @@ func (_m *RequestContext) ActiveSpan() envoy.Span {
 	return r0
 }
 
+// AsyncClient provides a mock function with given fields:
+func (_m *RequestContext) AsyncClient() envoy.AsyncClient {
+	ret := _m.Called()
+
+	var r0 envoy.AsyncClient
+	if rf, ok := ret.Get(0).(func() envoy.AsyncClient); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(envoy.AsyncClient)
+		}
+	}
+
+	return r0
+}
+
 // BodyReader provides a mock function with given fields:
 func (_m *RequestContext) BodyReader() io.Reader {
 	ret := _m.Called()
@@ -83,6 +99,22 @@ func (_m *RequestContext) GetSecret(_a0 string) string {
 	return r0
 }
 
+// GetSecretsWithPrefix provides a mock function with given fields: prefix
+func (_m *RequestContext) GetSecretsWithPrefix(prefix string) map[string]string {
+	ret := _m.Called(prefix)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func(string) map[string]string); ok {
+		r0 = rf(prefix)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	return r0
+}
+
 // GoContext provides a mock function with given fields:
 func (_m *RequestContext) GoContext() context2.Context {
 	ret := _m.Called()
@@ -130,3 +162,19 @@ func (_m *RequestContext) Logger() logger.Logger {
 
 	return r0
 }
+
+// StreamInfo provides a mock function with given fields:
+func (_m *RequestContext) StreamInfo() envoy.StreamInfo {
+	ret := _m.Called()
+
+	var r0 envoy.StreamInfo
+	if rf, ok := ret.Get(0).(func() envoy.StreamInfo); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(envoy.StreamInfo)
+		}
+	}
+
+	return r0
+}
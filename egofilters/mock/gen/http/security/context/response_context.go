@@ -51,6 +51,22 @@ func (_m *ResponseContext) AuthResponse() context.AuthResponse {
 	return r0
 }
 
+// AsyncClient provides a mock function with given fields:
+func (_m *ResponseContext) AsyncClient() envoy.AsyncClient {
+	ret := _m.Called()
+
+	var r0 envoy.AsyncClient
+	if rf, ok := ret.Get(0).(func() envoy.AsyncClient); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(envoy.AsyncClient)
+		}
+	}
+
+	return r0
+}
+
 // BodyReader provides a mock function with given fields:
 func (_m *ResponseContext) BodyReader() io.Reader {
 	ret := _m.Called()
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 )
 
 func main() {
@@ -37,5 +38,21 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, `{"signature": "%s"}`, signedSignature)
 	})
+	http.HandleFunc("/introspect", func(w http.ResponseWriter, r *http.Request) {
+		// fake OAuth2 token introspection (RFC 7662), the only active token is
+		// "egodemo-token"
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "INTROSPECTION_CLIENT_ID" || clientSecret != "INTROSPECTION_CLIENT_SECRET" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostFormValue("token") != "egodemo-token" {
+			fmt.Fprint(w, `{"active": false}`)
+			return
+		}
+		fmt.Fprintf(w, `{"active": true, "scope": "read write", "aud": "egodemo", "sub": "demo-user", "exp": %d}`,
+			time.Now().Add(time.Hour).Unix())
+	})
 	http.ListenAndServe(":8889", nil)
 }